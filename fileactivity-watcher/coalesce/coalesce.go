@@ -0,0 +1,307 @@
+package coalesce
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// Key selects the record fields that identify repeats of the same event.
+type Key uint8
+
+const (
+	KeyFile Key = 1 << iota
+	KeyPID
+	KeyOp
+	KeyProcess
+	KeyContainer
+
+	DefaultKey = KeyFile | KeyPID | KeyOp
+)
+
+const (
+	flushInterval = 500 * time.Millisecond
+
+	// maxGroups caps the open records. Once it is reached, the oldest record
+	// is emitted early to make room for a new one.
+	maxGroups = 10000
+	// maxEvents caps the events remembered for Touch. Events beyond it are
+	// still coalesced, but have to go through Add.
+	maxEvents = 4 * maxGroups
+)
+
+// keyNames lists the configuration names of the key fields.
+var keyNames = []struct { //nolint:gochecknoglobals
+	key  Key
+	name string
+}{
+	{KeyFile, "file"},
+	{KeyPID, "pid"},
+	{KeyOp, "op"},
+	{KeyProcess, "process"},
+	{KeyContainer, "container"},
+}
+
+// ParseKey combines the named fields into a key. Names are case-insensitive.
+func ParseKey(names []string) (Key, error) {
+	var key Key
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		field, ok := parseKeyField(name)
+		if !ok {
+			return 0, fmt.Errorf("unknown coalesce key field %q", name)
+		}
+
+		key |= field
+	}
+
+	return key, nil
+}
+
+func parseKeyField(name string) (Key, bool) {
+	for _, entry := range keyNames {
+		if entry.name == name {
+			return entry.key, true
+		}
+	}
+
+	return 0, false
+}
+
+func (k Key) String() string {
+	fields := []string{}
+
+	for _, entry := range keyNames {
+		if k&entry.key != 0 {
+			fields = append(fields, entry.name)
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// Settings control how repeats of an event are coalesced.
+// Events are not coalesced when Window is zero.
+type Settings struct {
+	Key    Key
+	Window time.Duration
+}
+
+// Coalescer accumulates repeated events into a single record, which is emitted
+// once the window that started with the first event has closed.
+type Coalescer struct {
+	emit func(types.Record)
+
+	mu     sync.Mutex
+	groups map[string]*group
+	order  *list.List // Open groups, oldest first
+	events map[types.Event]*group
+
+	done      chan struct{}
+	finished  chan struct{}
+	closeOnce sync.Once
+}
+
+type group struct {
+	id      string
+	record  types.Record
+	window  time.Duration
+	events  []types.Event
+	element *list.Element
+}
+
+// New creates a coalescer that passes finished records to emit.
+func New(emit func(types.Record)) *Coalescer {
+	coalescer := &Coalescer{
+		emit:     emit,
+		groups:   make(map[string]*group),
+		order:    list.New(),
+		events:   make(map[types.Event]*group),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	go coalescer.run()
+
+	return coalescer
+}
+
+func (c *Coalescer) run() {
+	defer close(c.finished)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.flush(now, false)
+		}
+	}
+}
+
+// Touch counts a repeat of an event that already belongs to an open record,
+// so that it does not need to be resolved again. It reports whether the
+// event was counted; if not, the event must be passed to Add.
+func (c *Coalescer) Touch(event types.Event, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.events[event]
+	if !ok {
+		return false
+	}
+
+	group.record.Count++
+	group.record.LastSeen = now
+
+	return true
+}
+
+// Add adds the record for an event, either to the open record with the same
+// key or as a new record.
+func (c *Coalescer) Add(event types.Event, record types.Record, settings Settings) {
+	if settings.Window <= 0 {
+		c.emit(record)
+
+		return
+	}
+
+	id := groupID(record, settings.Key)
+
+	var evicted *group
+
+	c.mu.Lock()
+
+	existing, ok := c.groups[id]
+	if !ok {
+		if len(c.groups) >= maxGroups {
+			if oldest, isGroup := c.order.Front().Value.(*group); isGroup {
+				evicted = oldest
+				c.remove(evicted)
+			}
+		}
+
+		existing = &group{id: id, record: record, window: settings.Window}
+		existing.element = c.order.PushBack(existing)
+		c.groups[id] = existing
+	} else {
+		existing.record.Count += record.Count
+		existing.record.Op |= record.Op
+		existing.record.LastSeen = record.LastSeen
+	}
+
+	if _, indexed := c.events[event]; !indexed && len(c.events) < maxEvents {
+		c.events[event] = existing
+		existing.events = append(existing.events, event)
+	}
+
+	c.mu.Unlock()
+
+	if evicted != nil {
+		c.emit(evicted.record)
+	}
+}
+
+// Close stops the coalescer and emits all open records. Nothing is emitted
+// once Close has returned.
+func (c *Coalescer) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.finished
+		c.flush(time.Now(), true)
+	})
+}
+
+// remove forgets a group and its events. It must be called with mu held.
+func (c *Coalescer) remove(group *group) {
+	delete(c.groups, group.id)
+	c.order.Remove(group.element)
+
+	for _, event := range group.events {
+		delete(c.events, event)
+	}
+}
+
+// flush emits the records whose window has closed, or all records if all is set.
+func (c *Coalescer) flush(now time.Time, all bool) {
+	c.mu.Lock()
+
+	finished := []*group{}
+
+	for _, group := range c.groups {
+		if !all && now.Sub(group.record.FirstSeen) < group.window {
+			continue
+		}
+
+		finished = append(finished, group)
+
+		c.remove(group)
+	}
+
+	c.mu.Unlock()
+
+	slices.SortFunc(finished, func(a, b *group) int {
+		return a.record.FirstSeen.Compare(b.record.FirstSeen)
+	})
+
+	for _, group := range finished {
+		c.emit(group.record)
+	}
+}
+
+// groupID joins the key fields of a record. The flag is always part of the
+// ID, so that flagged events are never hidden in another record.
+func groupID(record types.Record, key Key) string {
+	parts := []string{record.Flag}
+
+	if key&KeyFile != 0 {
+		parts = append(parts, record.File)
+	}
+
+	if key&KeyPID != 0 {
+		parts = append(parts, strconv.Itoa(record.PID))
+	}
+
+	if key&KeyOp != 0 {
+		parts = append(parts, record.Op.String())
+	}
+
+	if key&KeyProcess != 0 {
+		parts = append(parts, record.ProcessPath)
+	}
+
+	if key&KeyContainer != 0 {
+		parts = append(parts, record.Container)
+	}
+
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,323 @@
+package coalesce
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	records []types.Record
+}
+
+func (r *recorder) emit(record types.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, record)
+}
+
+func (r *recorder) get() []types.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]types.Record{}, r.records...)
+}
+
+func newRecord(event types.Event, now time.Time) types.Record {
+	return types.Record{
+		Op:        event.Op,
+		File:      event.File,
+		PID:       event.PID,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		expected Key
+		wantErr  bool
+	}{
+		{"default", []string{"file", "pid", "op"}, DefaultKey, false},
+		{"ignore pid", []string{"file", "op"}, KeyFile | KeyOp, false},
+		{"case and spaces", []string{" File ", "CONTAINER"}, KeyFile | KeyContainer, false},
+		{"process", []string{"process"}, KeyProcess, false},
+		{"unknown field", []string{"file", "inode"}, 0, true},
+		{"empty", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKey(tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if key != tt.expected {
+				t.Errorf("ParseKey() = %v, expected %v", key, tt.expected)
+			}
+		})
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	if DefaultKey.String() != "file,pid,op" {
+		t.Errorf("Expected file,pid,op, got %q", DefaultKey.String())
+	}
+}
+
+func TestCoalescer_CountsRepeats(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+
+	start := time.Now()
+	event := types.Event{File: "/mnt/disk1/Media/movie.mkv", PID: 100, Op: types.OpRead}
+	settings := Settings{Key: DefaultKey, Window: time.Hour}
+
+	if coalescer.Touch(event, start) {
+		t.Fatal("Expected the first event not to be part of an open record")
+	}
+
+	coalescer.Add(event, newRecord(event, start), settings)
+
+	for i := 1; i < 5000; i++ {
+		if !coalescer.Touch(event, start.Add(time.Duration(i)*time.Millisecond)) {
+			t.Fatalf("Expected repeat %d to be counted", i)
+		}
+	}
+
+	if len(output.get()) != 0 {
+		t.Fatal("Expected no records before the window closes")
+	}
+
+	coalescer.Close()
+
+	records := output.get()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record after close, got %d", len(records))
+	}
+
+	record := records[0]
+	if record.Count != 5000 {
+		t.Errorf("Expected count 5000, got %d", record.Count)
+	}
+
+	if !record.FirstSeen.Equal(start) {
+		t.Errorf("Expected first_seen %v, got %v", start, record.FirstSeen)
+	}
+
+	if !record.LastSeen.Equal(start.Add(4999 * time.Millisecond)) {
+		t.Errorf("Expected last_seen 4.999s after start, got %v", record.LastSeen.Sub(start))
+	}
+}
+
+func TestCoalescer_Key(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+
+	start := time.Now()
+	settings := Settings{Key: KeyFile, Window: time.Hour}
+
+	events := []types.Event{
+		{File: "/mnt/disk1/a.txt", PID: 100, Op: types.OpOpen},
+		{File: "/mnt/disk1/a.txt", PID: 200, Op: types.OpRead},
+		{File: "/mnt/disk1/b.txt", PID: 100, Op: types.OpRead},
+	}
+
+	for i, event := range events {
+		coalescer.Add(event, newRecord(event, start.Add(time.Duration(i)*time.Second)), settings)
+	}
+
+	// The second PID was added to the open record, so its repeats are counted too
+	if !coalescer.Touch(events[1], start.Add(5*time.Second)) {
+		t.Error("Expected a repeat from the second PID to be counted")
+	}
+
+	coalescer.Close()
+
+	records := output.get()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+
+	merged := records[0]
+	if merged.File != "/mnt/disk1/a.txt" || merged.Count != 3 {
+		t.Errorf("Expected 3 events for a.txt, got %d for %s", merged.Count, merged.File)
+	}
+
+	if merged.Op != types.OpOpen|types.OpRead {
+		t.Errorf("Expected merged ops OPEN|READ, got %v", merged.Op)
+	}
+
+	if merged.PID != 100 {
+		t.Errorf("Expected the first PID to be kept, got %d", merged.PID)
+	}
+
+	if records[1].File != "/mnt/disk1/b.txt" || records[1].Count != 1 {
+		t.Errorf("Expected a single event for b.txt, got %+v", records[1])
+	}
+}
+
+func TestCoalescer_FlagsAreSeparate(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+
+	now := time.Now()
+	event := types.Event{File: "/mnt/disk1/a.txt", PID: 100, Op: types.OpRemove}
+
+	flagged := newRecord(event, now)
+	flagged.Flag = "deletes"
+
+	coalescer.Add(event, newRecord(event, now), Settings{Key: KeyFile, Window: time.Hour})
+	coalescer.Add(event, flagged, Settings{Key: KeyFile, Window: time.Hour})
+	coalescer.Close()
+
+	if records := output.get(); len(records) != 2 {
+		t.Errorf("Expected flagged events in their own record, got %d records", len(records))
+	}
+}
+
+func TestCoalescer_WindowCloses(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+	defer coalescer.Close()
+
+	start := time.Now()
+	short := types.Event{File: "/mnt/disk1/short.txt", PID: 1, Op: types.OpWrite}
+	long := types.Event{File: "/mnt/disk1/long.txt", PID: 1, Op: types.OpWrite}
+
+	coalescer.Add(short, newRecord(short, start), Settings{Key: DefaultKey, Window: time.Second})
+	coalescer.Add(long, newRecord(long, start), Settings{Key: DefaultKey, Window: time.Minute})
+
+	coalescer.flush(start.Add(500*time.Millisecond), false)
+
+	if len(output.get()) != 0 {
+		t.Fatal("Expected no records before the first window closes")
+	}
+
+	coalescer.flush(start.Add(time.Second), false)
+
+	records := output.get()
+	if len(records) != 1 || records[0].File != short.File {
+		t.Fatalf("Expected only short.txt to be flushed, got %+v", records)
+	}
+
+	// Once flushed, the event starts a new record
+	if coalescer.Touch(short, start.Add(2*time.Second)) {
+		t.Error("Expected the flushed event to start a new record")
+	}
+}
+
+func TestCoalescer_ZeroWindow(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+	defer coalescer.Close()
+
+	event := types.Event{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite}
+	coalescer.Add(event, newRecord(event, time.Now()), Settings{Key: DefaultKey})
+
+	if len(output.get()) != 1 {
+		t.Error("Expected a zero window to write the record immediately")
+	}
+
+	if coalescer.Touch(event, time.Now()) {
+		t.Error("Expected uncoalesced events not to be tracked")
+	}
+}
+
+func TestCoalescer_FlushesInBackground(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+	defer coalescer.Close()
+
+	event := types.Event{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite}
+	settings := Settings{Key: DefaultKey, Window: time.Millisecond}
+	coalescer.Add(event, newRecord(event, time.Now()), settings)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(output.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if len(output.get()) != 1 {
+		t.Error("Expected the record to be flushed after its window")
+	}
+}
+
+func TestCoalescer_EvictsOldestWhenFull(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+	defer coalescer.Close()
+
+	settings := Settings{Key: DefaultKey, Window: time.Hour}
+	now := time.Now()
+
+	for i := range maxGroups + 1 {
+		event := types.Event{File: "/mnt/disk1/" + strconv.Itoa(i), PID: 1, Op: types.OpWrite}
+		coalescer.Add(event, newRecord(event, now.Add(time.Duration(i))), settings)
+	}
+
+	records := output.get()
+	if len(records) != 1 || records[0].File != "/mnt/disk1/0" {
+		t.Fatalf("Expected the oldest record to be emitted early, got %d records", len(records))
+	}
+
+	coalescer.mu.Lock()
+	groups := len(coalescer.groups)
+	coalescer.mu.Unlock()
+
+	if groups != maxGroups {
+		t.Errorf("Expected %d open records, got %d", maxGroups, groups)
+	}
+}
+
+func TestCoalescer_NoEmitAfterClose(t *testing.T) {
+	output := &recorder{}
+	coalescer := New(output.emit)
+
+	settings := Settings{Key: DefaultKey, Window: time.Millisecond}
+	now := time.Now()
+
+	for i := range 100 {
+		event := types.Event{File: "/mnt/disk1/" + strconv.Itoa(i), PID: 1, Op: types.OpWrite}
+		coalescer.Add(event, newRecord(event, now), settings)
+	}
+
+	time.Sleep(flushInterval)
+	coalescer.Close()
+
+	emitted := len(output.get())
+
+	time.Sleep(2 * flushInterval)
+
+	if len(output.get()) != emitted || emitted != 100 {
+		t.Errorf("Expected all 100 records by Close and none after, got %d then %d",
+			emitted, len(output.get()))
+	}
+}
@@ -26,15 +26,101 @@ import (
 )
 
 type ActivityConfig struct {
-	Enable            bool     `json:"enable,omitempty"`
-	UnassignedDevices bool     `json:"unassigned_devices,omitempty"`
-	Cache             bool     `json:"cache,omitempty"`
-	SSD               bool     `json:"ssd,omitempty"`
-	DisplayEvents     int      `json:"display_events,omitempty"`
-	Exclusions        []string `json:"exclusions,omitempty"`
-	MaxRecords        int      `json:"max_records,omitempty"`
-	DedupeWindow      int      `json:"dedupe_window,omitempty"`
-	ActivityPath      string   `json:"activity_path,omitempty"`
+	Enable            bool            `json:"enable,omitempty"`
+	UnassignedDevices bool            `json:"unassigned_devices,omitempty"`
+	Cache             bool            `json:"cache,omitempty"`
+	SSD               bool            `json:"ssd,omitempty"`
+	DisplayEvents     int             `json:"display_events,omitempty"`
+	Exclusions        []string        `json:"exclusions,omitempty"`
+	ExclusionFile     string          `json:"exclusion_file,omitempty"`
+	MaxRecords        int             `json:"max_records,omitempty"`
+	RotatePeriod      string          `json:"rotate_period,omitempty"`
+	MaxFileMB         int             `json:"max_file_mb,omitempty"`
+	Generations       int             `json:"generations,omitempty"`
+	RetentionDays     int             `json:"retention_days,omitempty"`
+	CompressRotated   bool            `json:"compress_rotated,omitempty"`
+	MaxRotatedMB      int             `json:"max_rotated_mb,omitempty"`
+	DedupeWindow      int             `json:"dedupe_window,omitempty"`
+	DedupeMemoryMB    int             `json:"dedupe_memory_mb,omitempty"`
+	ActivityPath      string          `json:"activity_path,omitempty"`
+	OutputFormat      string          `json:"output_format,omitempty"`
+	FlushRecords      int             `json:"flush_records,omitempty"`
+	FlushIntervalMS   int             `json:"flush_interval_ms,omitempty"`
+	FsyncIntervalMS   int             `json:"fsync_interval_ms,omitempty"`
+	StatusPath        string          `json:"status_path,omitempty"`
+	ContainerFields   []string        `json:"container_fields,omitempty"`
+	LoopImages        bool            `json:"loop_images,omitempty"`
+	Events            []string        `json:"events,omitempty"`
+	Coalesce          bool            `json:"coalesce,omitempty"`
+	CoalesceKey       []string        `json:"coalesce_key,omitempty"`
+	RateLimits        []RateLimit     `json:"rate_limits,omitempty"`
+	RateLimitInterval int             `json:"rate_limit_interval,omitempty"`
+	Profiles          map[string]bool `json:"profiles,omitempty"`
+
+	Rules []Rule `json:"rules,omitempty"`
+
+	Sinks []Sink `json:"sinks,omitempty"`
+}
+
+// Sink is an output that receives the activity records kept by the filters.
+// Type selects the implementation, such as "file", and Options holds its
+// type-specific settings. Records are queued for each sink, up to QueueSize,
+// and dropped when the sink falls behind.
+// Rules are evaluated like the filter rules, but only decide what this sink
+// receives: "drop" skips the record, "keep" and "flag" deliver it, and records
+// no rule matches get DefaultAction ("keep" unless set to "drop"). With
+// FlaggedOnly set, the sink only receives records that a filter rule flagged.
+type Sink struct {
+	Name          string          `json:"name,omitempty"`
+	Type          string          `json:"type"`
+	QueueSize     int             `json:"queue_size,omitempty"`
+	Rules         []Rule          `json:"rules,omitempty"`
+	DefaultAction string          `json:"default_action,omitempty"`
+	FlaggedOnly   bool            `json:"flagged_only,omitempty"`
+	Options       json.RawMessage `json:"options,omitempty"`
+}
+
+// Rule decides what happens to events that match all of its criteria.
+// Path and Process are regular expressions that may match anywhere, like the
+// exclusions. ProcessName, Source, Container and Image are regular expressions that
+// must match the whole value; Source is the job that started the process, such as
+// "cron" or "plugin:<plugin>". Glob is matched against the full path, Label is either
+// "key" or "key=value", and Time is a local time-of-day range such as "22:00-06:00".
+// Action is one of "drop", "keep" or "flag".
+// Keep and flag rules override the exclusions if they only match on the path,
+// glob, ops, disk, share or time and come before any rule that needs process or
+// container details. Excluded events are never resolved, so rules on the process
+// or container can't bring them back.
+// When coalescing is enabled, CoalesceKey and CoalesceWindow (in seconds) override
+// the global coalesce key and the dedupe window for the events the rule keeps.
+type Rule struct {
+	Name        string   `json:"name,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	Glob        string   `json:"glob,omitempty"`
+	Ops         []string `json:"ops,omitempty"`
+	Process     string   `json:"process,omitempty"`
+	ProcessName string   `json:"process_name,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Container   string   `json:"container,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	Label       string   `json:"label,omitempty"`
+	Disk        string   `json:"disk,omitempty"`
+	Share       string   `json:"share,omitempty"`
+	UID         *int     `json:"uid,omitempty"`
+	Time        string   `json:"time,omitempty"`
+	Action      string   `json:"action"`
+
+	CoalesceKey    []string `json:"coalesce_key,omitempty"`
+	CoalesceWindow *int     `json:"coalesce_window,omitempty"`
+}
+
+// RateLimit caps the events kept for each process, container or disk.
+// Key is one of "process", "container" or "disk", Rate is in events per
+// second and Burst defaults to Rate.
+type RateLimit struct {
+	Key   string  `json:"key"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst,omitempty"`
 }
 
 func LoadConfig() ActivityConfig {
@@ -45,9 +131,31 @@ func LoadConfig() ActivityConfig {
 		SSD:               false,
 		DisplayEvents:     1000,
 		Exclusions:        []string{`(?i)appdata`, `(?i)docker`, `(?i)system`, `(?i)syslogs`},
+		ExclusionFile:     "/boot/config/plugins/file.activity/exclusions.ignore",
 		MaxRecords:        20000,
+		RotatePeriod:      "",
+		MaxFileMB:         0,
+		Generations:       1,
+		RetentionDays:     0,
+		CompressRotated:   false,
+		MaxRotatedMB:      0,
 		DedupeWindow:      1,
+		DedupeMemoryMB:    16,
 		ActivityPath:      "/var/log/file.activity/data.log",
+		OutputFormat:      "csv",
+		FlushRecords:      100,
+		FlushIntervalMS:   1000,
+		FsyncIntervalMS:   0,
+		StatusPath:        "/var/log/file.activity/status.json",
+		ContainerFields:   []string{},
+		LoopImages:        false,
+		Events:            []string{"create", "remove", "write", "open", "read", "rename", "chmod"},
+		Coalesce:          false,
+		CoalesceKey:       []string{"file", "pid", "op"},
+		RateLimits:        []RateLimit{},
+		RateLimitInterval: 60,
+		Profiles:          map[string]bool{},
+		Sinks:             []Sink{{Name: "activity", Type: "file"}},
 	}
 
 	file, err := os.ReadFile("/boot/config/plugins/file.activity/config.json")
@@ -69,12 +177,33 @@ func LoadConfig() ActivityConfig {
 
 	log.Info().
 		Interface("Exclusions", appConfig.Exclusions).
+		Str("ExclusionFile", appConfig.ExclusionFile).
 		Bool("Enable", appConfig.Enable).
 		Bool("UnassignedDevices", appConfig.UnassignedDevices).
 		Bool("Cache", appConfig.Cache).
 		Bool("SSD", appConfig.SSD).
+		Bool("LoopImages", appConfig.LoopImages).
+		Bool("Coalesce", appConfig.Coalesce).
+		Strs("CoalesceKey", appConfig.CoalesceKey).
 		Int("DisplayEvents", appConfig.DisplayEvents).
 		Int("MaxRecords", appConfig.MaxRecords).
+		Str("RotatePeriod", appConfig.RotatePeriod).
+		Int("MaxFileMB", appConfig.MaxFileMB).
+		Int("Generations", appConfig.Generations).
+		Int("RetentionDays", appConfig.RetentionDays).
+		Bool("CompressRotated", appConfig.CompressRotated).
+		Int("MaxRotatedMB", appConfig.MaxRotatedMB).
+		Str("OutputFormat", appConfig.OutputFormat).
+		Int("FlushRecords", appConfig.FlushRecords).
+		Int("FlushIntervalMS", appConfig.FlushIntervalMS).
+		Int("FsyncIntervalMS", appConfig.FsyncIntervalMS).
+		Int("DedupeMemoryMB", appConfig.DedupeMemoryMB).
+		Strs("ContainerFields", appConfig.ContainerFields).
+		Strs("Events", appConfig.Events).
+		Int("Rules", len(appConfig.Rules)).
+		Int("RateLimits", len(appConfig.RateLimits)).
+		Int("Sinks", len(appConfig.Sinks)).
+		Interface("Profiles", appConfig.Profiles).
 		Msg("File Activity Watcher Configuration")
 
 	return appConfig
@@ -36,6 +36,16 @@ func TestLoadConfig_DefaultWhenNoFile(t *testing.T) {
 		t.Errorf("Expected MaxRecords to be 20000, got %d", config.MaxRecords)
 	}
 
+	if config.Generations != 1 || config.CompressRotated || config.MaxRotatedMB != 0 {
+		t.Errorf("Expected one uncompressed rotated file without a size cap, got %d, %v, %dMB",
+			config.Generations, config.CompressRotated, config.MaxRotatedMB)
+	}
+
+	if config.RotatePeriod != "" || config.MaxFileMB != 0 || config.RetentionDays != 0 {
+		t.Errorf("Expected rotation on the record count only, got %q, %dMB, %d days",
+			config.RotatePeriod, config.MaxFileMB, config.RetentionDays)
+	}
+
 	if config.DedupeWindow != 1 {
 		t.Errorf("Expected DedupeWindow to be 1, got %d", config.DedupeWindow)
 	}
@@ -47,6 +57,15 @@ func TestLoadConfig_DefaultWhenNoFile(t *testing.T) {
 		)
 	}
 
+	if config.FlushRecords != 100 || config.FlushIntervalMS != 1000 || config.FsyncIntervalMS != 0 {
+		t.Errorf("Expected flushes every 100 records or second without fsync, got %d, %dms, %dms",
+			config.FlushRecords, config.FlushIntervalMS, config.FsyncIntervalMS)
+	}
+
+	if config.OutputFormat != "csv" {
+		t.Errorf("Expected OutputFormat to be csv, got %s", config.OutputFormat)
+	}
+
 	if !config.UnassignedDevices {
 		t.Error("Expected UnassignedDevices to be true by default")
 	}
@@ -63,7 +82,44 @@ func TestLoadConfig_DefaultWhenNoFile(t *testing.T) {
 		t.Error("Expected Enable to be false by default")
 	}
 
+	if config.LoopImages {
+		t.Error("Expected LoopImages to be false by default")
+	}
+
 	if len(config.Exclusions) != 4 {
 		t.Errorf("Expected 4 default exclusions, got %d", len(config.Exclusions))
 	}
+
+	if config.ExclusionFile != "/boot/config/plugins/file.activity/exclusions.ignore" {
+		t.Errorf("Expected the exclusion file next to config.json, got %s", config.ExclusionFile)
+	}
+
+	if len(config.ContainerFields) != 0 {
+		t.Errorf("Expected no default container fields, got %d", len(config.ContainerFields))
+	}
+
+	if config.Coalesce {
+		t.Error("Expected Coalesce to be false by default")
+	}
+
+	if len(config.CoalesceKey) != 3 {
+		t.Errorf("Expected file, pid and op as the coalesce key, got %v", config.CoalesceKey)
+	}
+
+	if len(config.RateLimits) != 0 || config.RateLimitInterval != 60 {
+		t.Errorf("Expected no rate limits with a 60s interval, got %v every %ds",
+			config.RateLimits, config.RateLimitInterval)
+	}
+
+	if len(config.Profiles) != 0 {
+		t.Errorf("Expected no profiles enabled by default, got %v", config.Profiles)
+	}
+
+	if len(config.Events) != 7 {
+		t.Errorf("Expected all 7 event types by default, got %v", config.Events)
+	}
+
+	if len(config.Sinks) != 1 || config.Sinks[0].Type != "file" {
+		t.Errorf("Expected only the activity file sink by default, got %+v", config.Sinks)
+	}
 }
@@ -0,0 +1,178 @@
+package disks
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LoopImage is a loop device backed by an image file, such as docker.img.
+type LoopImage struct {
+	Device      string
+	BackingFile string
+	Mountpoint  string
+}
+
+// GetLoopImages returns the mountpoints of loop-mounted images that live on a
+// watched disk, mapped to the path of their backing image on that disk.
+func (d *Disks) GetLoopImages(watchFolders map[string]int) map[string]string {
+	loopImages := make(map[string]string)
+
+	if !d.appConfig.LoopImages {
+		log.Info().Msg("Loop image monitoring is disabled")
+
+		return loopImages
+	}
+
+	for _, image := range findLoopImages("/sys/block", "/proc/self/mountinfo") {
+		backingFile := resolveBackingFile(image.BackingFile, watchFolders)
+		if backingFile == "" {
+			log.Debug().
+				Str("device", image.Device).
+				Str("backing_file", image.BackingFile).
+				Msg("Skipping loop image that is not on a watched disk")
+
+			continue
+		}
+
+		log.Info().
+			Str("device", image.Device).
+			Str("backing_file", backingFile).
+			Str("mountpoint", image.Mountpoint).
+			Msg("Watching loop image")
+
+		loopImages[image.Mountpoint] = backingFile
+	}
+
+	return loopImages
+}
+
+// findLoopImages lists the loop devices under sysBlock that have a backing file
+// and are mounted according to mountInfoPath.
+func findLoopImages(sysBlock, mountInfoPath string) []LoopImage {
+	backingFiles, err := filepath.Glob(filepath.Join(sysBlock, "loop*", "loop", "backing_file"))
+	if err != nil {
+		return nil
+	}
+
+	mountpoints := readDeviceMountpoints(mountInfoPath)
+	images := make([]LoopImage, 0, len(backingFiles))
+
+	for _, backingFilePath := range backingFiles {
+		data, err := os.ReadFile(backingFilePath)
+		if err != nil {
+			continue
+		}
+
+		device := "/dev/" + filepath.Base(filepath.Dir(filepath.Dir(backingFilePath)))
+
+		mountpoint, mounted := mountpoints[device]
+		if !mounted {
+			continue
+		}
+
+		images = append(images, LoopImage{
+			Device:      device,
+			BackingFile: strings.TrimSuffix(strings.TrimSpace(string(data)), " (deleted)"),
+			Mountpoint:  mountpoint,
+		})
+	}
+
+	return images
+}
+
+// readDeviceMountpoints maps each mounted block device to its shortest mountpoint.
+// Bind mounts and btrfs subvolumes make a device appear more than once.
+func readDeviceMountpoints(mountInfoPath string) map[string]string {
+	mountpoints := make(map[string]string)
+
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error reading mount information")
+
+		return mountpoints
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 36 35 98:0 / /var/lib/docker rw,noatime shared:1 - btrfs /dev/loop2 rw
+		fields, rest, found := strings.Cut(scanner.Text(), " - ")
+		if !found {
+			continue
+		}
+
+		mountFields := strings.Fields(fields)
+		sourceFields := strings.Fields(rest)
+
+		if len(mountFields) < 5 || len(sourceFields) < 2 {
+			continue
+		}
+
+		device := sourceFields[1]
+		mountpoint := unescapeMountField(mountFields[4])
+
+		if existing, exists := mountpoints[device]; !exists || len(mountpoint) < len(existing) {
+			mountpoints[device] = mountpoint
+		}
+	}
+
+	return mountpoints
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space, ...) used in mountinfo.
+func unescapeMountField(field string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).
+		Replace(field)
+}
+
+// resolveBackingFile returns the path of an image file on a watched disk.
+// Images configured through a user share (/mnt/user/system/docker/docker.img)
+// are looked up on each watched disk. An empty string is returned if the image
+// is not on a watched disk.
+func resolveBackingFile(backingFile string, watchFolders map[string]int) string {
+	for folder := range watchFolders {
+		if strings.HasPrefix(backingFile, folder+"/") {
+			return backingFile
+		}
+	}
+
+	for _, share := range []string{"/mnt/user/", "/mnt/user0/"} {
+		rest, ok := strings.CutPrefix(backingFile, share)
+		if !ok {
+			continue
+		}
+
+		for folder := range watchFolders {
+			candidate := filepath.Join(folder, rest)
+
+			_, err := os.Stat(candidate)
+			if err == nil {
+				return candidate
+			}
+		}
+	}
+
+	return ""
+}
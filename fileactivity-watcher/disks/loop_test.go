@@ -0,0 +1,161 @@
+package disks
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+)
+
+const testMountInfo = `22 1 0:21 / / rw,relatime - rootfs rootfs rw
+36 22 8:17 / /mnt/disk1 rw,noatime - xfs /dev/md1p1 rw,attr2
+37 22 8:33 / /mnt/disk2 rw,noatime - xfs /dev/md2p1 rw,attr2
+38 22 0:45 / /mnt/user rw,noatime - fuse.shfs shfs rw
+51 22 7:2 / /var/lib/docker rw,noatime - btrfs /dev/loop2 rw,space_cache=v2
+52 51 7:2 /btrfs/subvolumes/abc /var/lib/docker/btrfs/subvolumes/abc rw - btrfs /dev/loop2 rw
+53 22 7:3 / /etc/libvirt rw,noatime - btrfs /dev/loop3 rw
+54 22 7:4 / /mnt/my\040images rw - xfs /dev/loop4 rw
+`
+
+func writeLoopDevice(t *testing.T, sysBlock, name, backingFile string) {
+	t.Helper()
+
+	dir := filepath.Join(sysBlock, name, "loop")
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		t.Fatalf("Failed to create loop dir: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "backing_file"), []byte(backingFile+"\n"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to write backing file: %v", err)
+	}
+}
+
+func TestFindLoopImages(t *testing.T) {
+	tmpDir := t.TempDir()
+	sysBlock := filepath.Join(tmpDir, "block")
+	mountInfoPath := filepath.Join(tmpDir, "mountinfo")
+
+	writeLoopDevice(t, sysBlock, "loop2", "/mnt/disk1/system/docker/docker.img")
+	writeLoopDevice(t, sysBlock, "loop3", "/mnt/user/system/libvirt/libvirt.img")
+	writeLoopDevice(t, sysBlock, "loop4", "/mnt/disk2/images/data.img (deleted)")
+	writeLoopDevice(t, sysBlock, "loop5", "/mnt/disk2/images/unmounted.img")
+
+	// Loop devices without a backing file have no loop/backing_file entry
+	err := os.MkdirAll(filepath.Join(sysBlock, "loop0"), 0o755)
+	if err != nil {
+		t.Fatalf("Failed to create loop0: %v", err)
+	}
+
+	err = os.WriteFile(mountInfoPath, []byte(testMountInfo), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to write mountinfo: %v", err)
+	}
+
+	images := findLoopImages(sysBlock, mountInfoPath)
+
+	expected := map[string]LoopImage{
+		"/dev/loop2": {"/dev/loop2", "/mnt/disk1/system/docker/docker.img", "/var/lib/docker"},
+		"/dev/loop3": {"/dev/loop3", "/mnt/user/system/libvirt/libvirt.img", "/etc/libvirt"},
+		"/dev/loop4": {"/dev/loop4", "/mnt/disk2/images/data.img", "/mnt/my images"},
+	}
+
+	if len(images) != len(expected) {
+		t.Fatalf("Expected %d loop images, got %d: %+v", len(expected), len(images), images)
+	}
+
+	for _, image := range images {
+		if image != expected[image.Device] {
+			t.Errorf("Unexpected loop image %+v, expected %+v", image, expected[image.Device])
+		}
+	}
+}
+
+func TestResolveBackingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	disk1 := filepath.Join(tmpDir, "disk1")
+	disk2 := filepath.Join(tmpDir, "disk2")
+
+	err := os.MkdirAll(filepath.Join(disk2, "system", "libvirt"), 0o755)
+	if err != nil {
+		t.Fatalf("Failed to create disk dirs: %v", err)
+	}
+
+	err = os.WriteFile(filepath.Join(disk2, "system", "libvirt", "libvirt.img"), nil, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+
+	watchFolders := map[string]int{disk1: 1, disk2: 1}
+
+	tests := []struct {
+		name        string
+		backingFile string
+		expected    string
+	}{
+		{
+			"on watched disk",
+			disk1 + "/system/docker/docker.img",
+			disk1 + "/system/docker/docker.img",
+		},
+		{
+			"user share resolved to disk",
+			"/mnt/user/system/libvirt/libvirt.img",
+			disk2 + "/system/libvirt/libvirt.img",
+		},
+		{"user share not on watched disk", "/mnt/user/system/other.img", ""},
+		{"outside watched disks", "/boot/images/x.img", ""},
+		{"prefix is not a path boundary", disk1 + "0/x.img", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resolveBackingFile(tt.backingFile, watchFolders)
+			if result != tt.expected {
+				t.Errorf(
+					"resolveBackingFile(%q) = %q, expected %q",
+					tt.backingFile,
+					result,
+					tt.expected,
+				)
+			}
+		})
+	}
+}
+
+func TestGetLoopImages_Disabled(t *testing.T) {
+	disks := &Disks{appConfig: config.ActivityConfig{LoopImages: false}}
+
+	images := disks.GetLoopImages(map[string]int{"/mnt/disk1": 1})
+	if len(images) != 0 {
+		t.Errorf("Expected no loop images when disabled, got %d", len(images))
+	}
+}
+
+func TestReadDeviceMountpoints_MissingFile(t *testing.T) {
+	mountpoints := readDeviceMountpoints("/this/path/does/not/exist")
+	if len(mountpoints) != 0 {
+		t.Errorf("Expected no mountpoints for missing file, got %d", len(mountpoints))
+	}
+}
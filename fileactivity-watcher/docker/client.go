@@ -20,65 +20,329 @@ package docker
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/moby/moby/api/types/events"
 	"github.com/moby/moby/client"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	unknownContainerTTL    = 30 * time.Second
+	maxUnknownContainers   = 1024
+	minEventStreamBackoff  = time.Second
+	maxEventStreamBackoff  = time.Minute
+	containerLookupTimeout = 5 * time.Second
+)
+
+// dockerAPI is the subset of the Docker client used by the cache.
+type dockerAPI interface {
+	Events(ctx context.Context, options client.EventsListOptions) client.EventsResult
+	ContainerInspect(
+		ctx context.Context,
+		containerID string,
+		options client.ContainerInspectOptions,
+	) (client.ContainerInspectResult, error)
+	ContainerList(
+		ctx context.Context,
+		options client.ContainerListOptions,
+	) (client.ContainerListResult, error)
+}
+
 type Client struct {
-	containerCache      map[string]string
+	containerCache      map[string]types.Container
+	unknownContainers   map[string]time.Time
 	containerCacheMutex sync.RWMutex
-	dockerClient        *client.Client
+	dockerClient        dockerAPI
 }
 
 func New() *Client {
-	containerCache := make(map[string]string)
+	c := &Client{
+		containerCache:    make(map[string]types.Container),
+		unknownContainers: make(map[string]time.Time),
+	}
 
 	// Initialize Docker client
-	var err error
-
 	dockerClient, err := client.New()
 	if err != nil {
 		log.Warn().Err(err).Msg("Failed to create Docker client")
+
+		return c
+	}
+
+	c.dockerClient = dockerClient
+
+	return c
+}
+
+// Watch keeps the container cache current by following the Docker event stream.
+// The stream is re-established with exponential backoff if dockerd goes away.
+func (c *Client) Watch(ctx context.Context) {
+	if c.dockerClient == nil {
+		return
+	}
+
+	go func() {
+		backoff := minEventStreamBackoff
+
+		for {
+			connected := time.Now()
+
+			err := c.followEvents(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// A stream that stayed up for a while was healthy, so start over with a short delay
+			if time.Since(connected) > maxEventStreamBackoff {
+				backoff = minEventStreamBackoff
+			}
+
+			log.Warn().Err(err).Dur("retry_in", backoff).Msg("Docker event stream disconnected")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = nextBackoff(backoff)
+		}
+	}()
+}
+
+func nextBackoff(current time.Duration) time.Duration {
+	return min(current*2, maxEventStreamBackoff)
+}
+
+func (c *Client) followEvents(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	filters := make(client.Filters).
+		Add("type", string(events.ContainerEventType)).
+		Add("event",
+			string(events.ActionStart),
+			string(events.ActionDie),
+			string(events.ActionRename),
+			string(events.ActionDestroy),
+		)
+
+	stream := c.dockerClient.Events(ctx, client.EventsListOptions{Filters: filters})
+
+	// Resync after subscribing so that nothing happening in between is missed
+	c.refreshContainerCache(ctx)
+
+	log.Info().Msg("Following Docker container events")
+
+	for {
+		select {
+		case message := <-stream.Messages:
+			c.handleEvent(ctx, message)
+		case err := <-stream.Err:
+			return err
+		}
 	}
+}
+
+func (c *Client) handleEvent(ctx context.Context, message events.Message) {
+	if message.Type != events.ContainerEventType || message.Actor.ID == "" {
+		return
+	}
+
+	containerID := message.Actor.ID
+	name := strings.TrimPrefix(message.Actor.Attributes["name"], "/")
+
+	var ctr types.Container
+
+	// Looking up the template reads the flash drive, so the container is built
+	// before taking the write lock. Labels don't change for the life of a container.
+	if (message.Action == events.ActionStart || message.Action == events.ActionRename) &&
+		name != "" {
+		c.containerCacheMutex.RLock()
+		labels := c.containerCache[containerID].Labels
+		c.containerCacheMutex.RUnlock()
+
+		ctr = containerFromAttributes(containerID, message.Actor.Attributes, labels)
+	}
+
+	c.containerCacheMutex.Lock()
+	defer c.containerCacheMutex.Unlock()
+
+	switch message.Action {
+	case events.ActionStart, events.ActionRename:
+		if name == "" {
+			return
+		}
+
+		ctr.Mounts = c.containerCache[containerID].Mounts
 
-	return &Client{
-		containerCache: containerCache,
-		dockerClient:   dockerClient,
+		c.containerCache[containerID] = ctr
+		delete(c.unknownContainers, containerID)
+
+		// Event attributes don't include the mount list, so fetch the full details
+		// without holding up the event stream if dockerd is slow to answer
+		if message.Action == events.ActionStart && c.dockerClient != nil {
+			go c.updateContainer(ctx, containerID)
+		}
+	case events.ActionDie:
+		// A stopped container keeps its name until it is destroyed, and its
+		// processes may still be flushing files while they exit
+	case events.ActionDestroy:
+		delete(c.containerCache, containerID)
+	default:
+		return
 	}
+
+	log.Debug().
+		Str("container_id", containerID).
+		Str("name", name).
+		Str("action", string(message.Action)).
+		Msg("Docker container event")
 }
 
 func (c *Client) GetContainerNameByID(containerID string, ctx context.Context) string {
+	return c.GetContainerByID(containerID, ctx).Name
+}
+
+// GetContainerByID returns the cached metadata for a container.
+// An empty container is returned if the container is unknown.
+func (c *Client) GetContainerByID(containerID string, ctx context.Context) types.Container {
 	if c.dockerClient == nil {
-		return ""
+		return types.Container{}
 	}
 
 	// Check cache first (read lock)
 	c.containerCacheMutex.RLock()
 
-	if name, exists := c.containerCache[containerID]; exists {
+	if ctr, exists := c.containerCache[containerID]; exists {
 		c.containerCacheMutex.RUnlock()
 
-		return name
+		return ctr
+	}
+
+	if expires, exists := c.unknownContainers[containerID]; exists && time.Now().Before(expires) {
+		c.containerCacheMutex.RUnlock()
+
+		return types.Container{}
 	}
 
 	c.containerCacheMutex.RUnlock()
 
-	// Not in cache, refresh container list
-	c.refreshContainerCache(ctx)
+	// Not in cache, look up just this container
+	return c.lookupContainer(ctx, containerID)
+}
 
-	// Check cache again after refresh (read lock)
-	c.containerCacheMutex.RLock()
-	defer c.containerCacheMutex.RUnlock()
+// lookupContainer inspects a single container that is missing from the cache.
+// Containers that dockerd reports as missing are remembered for a while so that
+// repeated events from the same process don't each trigger a request to dockerd.
+// Other failures, such as timeouts, are not cached and the next event retries.
+func (c *Client) lookupContainer(ctx context.Context, containerID string) types.Container {
+	ctr, err := c.inspectContainer(ctx, containerID)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("container_id", containerID).
+			Msg("Failed to inspect Docker container")
+
+		if cerrdefs.IsNotFound(err) {
+			c.containerCacheMutex.Lock()
+			c.addUnknownContainer(containerID, time.Now())
+			c.containerCacheMutex.Unlock()
+		}
+
+		return types.Container{}
+	}
+
+	c.containerCacheMutex.Lock()
+	defer c.containerCacheMutex.Unlock()
+
+	c.containerCache[containerID] = ctr
+
+	return ctr
+}
+
+// inspectContainer fetches a single container from dockerd.
+func (c *Client) inspectContainer(
+	ctx context.Context,
+	containerID string,
+) (types.Container, error) {
+	ctx, cancel := context.WithTimeout(ctx, containerLookupTimeout)
+	defer cancel()
 
-	if name, exists := c.containerCache[containerID]; exists {
-		return name
+	result, err := c.dockerClient.ContainerInspect(
+		ctx,
+		containerID,
+		client.ContainerInspectOptions{},
+	)
+	if err != nil {
+		return types.Container{}, fmt.Errorf("inspect container %s: %w", containerID, err)
+	}
+
+	return containerFromInspect(result.Container), nil
+}
+
+// updateContainer replaces a cached container with its inspect data.
+// Nothing is stored if the container was destroyed while the inspect was running.
+func (c *Client) updateContainer(ctx context.Context, containerID string) {
+	ctr, err := c.inspectContainer(ctx, containerID)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("container_id", containerID).
+			Msg("Failed to inspect started Docker container")
+
+		return
+	}
+
+	c.containerCacheMutex.Lock()
+	defer c.containerCacheMutex.Unlock()
+
+	if _, exists := c.containerCache[containerID]; !exists {
+		return
+	}
+
+	c.containerCache[containerID] = ctr
+}
+
+// addUnknownContainer must be called with containerCacheMutex held.
+// Expired entries are pruned once the cache is full; if none have expired,
+// the entry closest to expiry is evicted to keep the cache bounded.
+func (c *Client) addUnknownContainer(containerID string, now time.Time) {
+	if c.unknownContainers == nil {
+		c.unknownContainers = make(map[string]time.Time)
+	}
+
+	if _, exists := c.unknownContainers[containerID]; !exists &&
+		len(c.unknownContainers) >= maxUnknownContainers {
+		var (
+			oldestID      string
+			oldestExpires time.Time
+		)
+
+		for id, expires := range c.unknownContainers {
+			if now.After(expires) {
+				delete(c.unknownContainers, id)
+
+				continue
+			}
+
+			if oldestID == "" || expires.Before(oldestExpires) {
+				oldestID, oldestExpires = id, expires
+			}
+		}
+
+		if len(c.unknownContainers) >= maxUnknownContainers {
+			delete(c.unknownContainers, oldestID)
+		}
 	}
 
-	return ""
+	c.unknownContainers[containerID] = now.Add(unknownContainerTTL)
 }
 
 func (c *Client) refreshContainerCache(ctx context.Context) {
@@ -87,12 +351,12 @@ func (c *Client) refreshContainerCache(ctx context.Context) {
 	}
 
 	// Create new cache
-	newCache := make(map[string]string)
+	newCache := make(map[string]types.Container)
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, containerLookupTimeout)
 	defer cancel()
 
-	result, err := c.dockerClient.ContainerList(ctx, client.ContainerListOptions{})
+	result, err := c.dockerClient.ContainerList(ctx, client.ContainerListOptions{All: true})
 	if err != nil {
 		log.Debug().Err(err).Msg("Failed to list Docker containers")
 
@@ -104,11 +368,12 @@ func (c *Client) refreshContainerCache(ctx context.Context) {
 			continue
 		}
 
-		newCache[ctr.ID] = strings.TrimPrefix(ctr.Names[0], "/")
+		newCache[ctr.ID] = containerFromSummary(ctr)
 	}
 
 	c.containerCacheMutex.Lock()
 	c.containerCache = newCache
+	c.unknownContainers = make(map[string]time.Time)
 	c.containerCacheMutex.Unlock()
 
 	log.Debug().Int("cached_containers", len(newCache)).Msg("Refreshed container cache")
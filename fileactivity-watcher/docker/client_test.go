@@ -20,9 +20,16 @@ package docker
 
 import (
 	"context"
-	"maps"
+	"errors"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/events"
 	dockerclient "github.com/moby/moby/client"
 )
 
@@ -44,7 +51,7 @@ func TestNew(t *testing.T) {
 
 func TestGetContainerNameByID_WithoutDockerClient(t *testing.T) {
 	client := &Client{
-		containerCache: make(map[string]string),
+		containerCache: make(map[string]types.Container),
 		dockerClient:   nil,
 	}
 
@@ -59,9 +66,9 @@ func TestGetContainerNameByID_FromCache(t *testing.T) {
 	// Note: We need dockerClient to be non-nil, otherwise GetContainerNameByID returns "" immediately
 	// But we don't need it to be functional for cache hits
 	client := &Client{
-		containerCache: map[string]string{
-			"abc123": "test-container",
-			"def456": "another-container",
+		containerCache: map[string]types.Container{
+			"abc123": {Name: "test-container"},
+			"def456": {Name: "another-container"},
 		},
 		dockerClient: &dockerclient.Client{}, // Non-nil to allow cache lookups
 	}
@@ -86,8 +93,8 @@ func TestGetContainerNameByID_FromCache(t *testing.T) {
 func TestGetContainerNameByID_WithNilDockerClient(t *testing.T) {
 	// When dockerClient is nil, GetContainerNameByID should always return empty string
 	client := &Client{
-		containerCache: map[string]string{
-			"abc123": "test-container",
+		containerCache: map[string]types.Container{
+			"abc123": {Name: "test-container"},
 		},
 		dockerClient: nil,
 	}
@@ -101,8 +108,8 @@ func TestGetContainerNameByID_WithNilDockerClient(t *testing.T) {
 func TestContainerCache_ConcurrentAccess(t *testing.T) {
 	client := New()
 
-	client.containerCache["container1"] = "name1"
-	client.containerCache["container2"] = "name2"
+	client.containerCache["container1"] = types.Container{Name: "name1"}
+	client.containerCache["container2"] = types.Container{Name: "name2"}
 
 	done := make(chan bool)
 
@@ -134,7 +141,7 @@ func TestClient_Initialization(t *testing.T) {
 	testName := "test-container-name"
 
 	client.containerCacheMutex.Lock()
-	client.containerCache[testID] = testName
+	client.containerCache[testID] = types.Container{ID: testID, Name: testName}
 	client.containerCacheMutex.Unlock()
 
 	result := client.GetContainerNameByID(testID, context.Background())
@@ -145,8 +152,8 @@ func TestClient_Initialization(t *testing.T) {
 
 func TestRefreshContainerCache_WithoutDockerClient(t *testing.T) {
 	client := &Client{
-		containerCache: map[string]string{
-			"old-id": "old-container",
+		containerCache: map[string]types.Container{
+			"old-id": {Name: "old-container"},
 		},
 		dockerClient: nil,
 	}
@@ -162,7 +169,7 @@ func TestRefreshContainerCache_WithoutDockerClient(t *testing.T) {
 	}
 
 	// Verify the original item is still there
-	if name, exists := client.containerCache["old-id"]; !exists || name != "old-container" {
+	if ctr, exists := client.containerCache["old-id"]; !exists || ctr.Name != "old-container" {
 		t.Error("Expected original cache entry to remain unchanged")
 	}
 }
@@ -187,7 +194,9 @@ func TestClient_CachePopulation(t *testing.T) {
 
 	client.containerCacheMutex.Lock()
 
-	maps.Copy(client.containerCache, testCases)
+	for id, name := range testCases {
+		client.containerCache[id] = types.Container{ID: id, Name: name}
+	}
 
 	client.containerCacheMutex.Unlock()
 
@@ -198,3 +207,324 @@ func TestClient_CachePopulation(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleEvent(t *testing.T) {
+	ctx := context.Background()
+	plexMounts := []types.Mount{{Source: "/mnt/user/Media", Destination: "/data"}}
+
+	client := &Client{
+		containerCache: map[string]types.Container{
+			"abc123": {
+				Name:   "plex",
+				Mounts: plexMounts,
+				Labels: map[string]string{composeProjectLabel: "media"},
+			},
+		},
+		unknownContainers: map[string]time.Time{
+			"def456": time.Now().Add(time.Minute),
+		},
+	}
+
+	containerEvent := func(action events.Action, id, name string) events.Message {
+		return events.Message{
+			Type:   events.ContainerEventType,
+			Action: action,
+			Actor: events.Actor{
+				ID:         id,
+				Attributes: map[string]string{"name": name},
+			},
+		}
+	}
+
+	// Start adds the container and clears any negative cache entry
+	client.handleEvent(ctx, containerEvent(events.ActionStart, "def456", "sonarr"))
+
+	if name := client.containerCache["def456"].Name; name != "sonarr" {
+		t.Errorf("Expected started container to be cached as %q, got %q", "sonarr", name)
+	}
+
+	if _, exists := client.unknownContainers["def456"]; exists {
+		t.Error("Expected started container to be removed from the unknown cache")
+	}
+
+	// Rename updates the cached name
+	client.handleEvent(ctx, containerEvent(events.ActionRename, "abc123", "/plex-new"))
+
+	if name := client.containerCache["abc123"].Name; name != "plex-new" {
+		t.Errorf("Expected renamed container to be cached as %q, got %q", "plex-new", name)
+	}
+
+	if len(client.containerCache["abc123"].Mounts) != len(plexMounts) {
+		t.Error("Expected renamed container to keep its mounts")
+	}
+
+	if project := client.containerCache["abc123"].ComposeProject; project != "media" {
+		t.Errorf("Expected renamed container to keep its labels, got project %q", project)
+	}
+
+	// Die keeps the name, as the container still exists
+	client.handleEvent(ctx, containerEvent(events.ActionDie, "abc123", "plex-new"))
+
+	if _, exists := client.containerCache["abc123"]; !exists {
+		t.Error("Expected stopped container to remain cached")
+	}
+
+	// Destroy removes the container
+	client.handleEvent(ctx, containerEvent(events.ActionDestroy, "abc123", "plex-new"))
+
+	if _, exists := client.containerCache["abc123"]; exists {
+		t.Error("Expected destroyed container to be removed from the cache")
+	}
+
+	// Non-container events are ignored
+	client.handleEvent(ctx, events.Message{
+		Type:   events.NetworkEventType,
+		Action: events.ActionStart,
+		Actor:  events.Actor{ID: "net1", Attributes: map[string]string{"name": "bridge"}},
+	})
+
+	if _, exists := client.containerCache["net1"]; exists {
+		t.Error("Expected non-container event to be ignored")
+	}
+}
+
+func TestHandleEvent_TemplateOutsideLock(t *testing.T) {
+	client := &Client{
+		containerCache: map[string]types.Container{
+			"abc123": {Name: "plex", Labels: map[string]string{unraidManagedLabel: "dockerman"}},
+		},
+	}
+
+	original := statTemplate
+
+	t.Cleanup(func() { statTemplate = original })
+
+	looked := false
+	statTemplate = func(name string) (os.FileInfo, error) {
+		looked = true
+
+		if !client.containerCacheMutex.TryLock() {
+			t.Error("Expected the template lookup to run without the cache lock")
+		} else {
+			client.containerCacheMutex.Unlock()
+		}
+
+		return nil, os.ErrNotExist
+	}
+
+	client.handleEvent(context.Background(), events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionRename,
+		Actor:  events.Actor{ID: "abc123", Attributes: map[string]string{"name": "plex-new"}},
+	})
+
+	if !looked {
+		t.Error("Expected the template of the managed container to be looked up")
+	}
+
+	if name := client.containerCache["abc123"].Name; name != "plex-new" {
+		t.Errorf("Expected renamed container to be cached as %q, got %q", "plex-new", name)
+	}
+}
+
+func TestGetContainerNameByID_NegativeCache(t *testing.T) {
+	client := &Client{
+		containerCache: make(map[string]types.Container),
+		unknownContainers: map[string]time.Time{
+			"stopped": time.Now().Add(time.Minute),
+		},
+		dockerClient: &dockerclient.Client{}, // Never reached for negative cache hits
+	}
+
+	result := client.GetContainerNameByID("stopped", context.Background())
+	if result != "" {
+		t.Errorf("Expected empty string for negatively cached container, got %q", result)
+	}
+}
+
+// fakeDocker answers inspect requests with a fixed result and counts them.
+type fakeDocker struct {
+	dockerclient.Client
+
+	inspectResult container.InspectResponse
+	inspectErr    error
+	inspects      int
+}
+
+func (f *fakeDocker) ContainerInspect(
+	_ context.Context,
+	_ string,
+	_ dockerclient.ContainerInspectOptions,
+) (dockerclient.ContainerInspectResult, error) {
+	f.inspects++
+
+	return dockerclient.ContainerInspectResult{Container: f.inspectResult}, f.inspectErr
+}
+
+func TestGetContainerNameByID_CachesUnknown(t *testing.T) {
+	tests := []struct {
+		name       string
+		inspectErr error
+		cached     bool
+	}{
+		{
+			name:       "not found",
+			inspectErr: cerrdefs.ErrNotFound.WithMessage("No such container: missing"),
+			cached:     true,
+		},
+		{
+			name:       "timeout",
+			inspectErr: fmt.Errorf("request: %w", context.DeadlineExceeded),
+			cached:     false,
+		},
+		{
+			name:       "daemon error",
+			inspectErr: errors.New("internal server error"),
+			cached:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDocker{inspectErr: tt.inspectErr}
+			client := &Client{
+				containerCache:    make(map[string]types.Container),
+				unknownContainers: make(map[string]time.Time),
+				dockerClient:      fake,
+			}
+
+			_ = client.GetContainerNameByID("missing", context.Background())
+			_ = client.GetContainerNameByID("missing", context.Background())
+
+			expires, exists := client.unknownContainers["missing"]
+			if exists != tt.cached {
+				t.Fatalf("Expected cached=%v, got %v", tt.cached, exists)
+			}
+
+			if exists && time.Until(expires) > unknownContainerTTL {
+				t.Errorf("Expected negative cache entry to expire within %v", unknownContainerTTL)
+			}
+
+			expectedInspects := 2
+			if tt.cached {
+				expectedInspects = 1
+			}
+
+			if fake.inspects != expectedInspects {
+				t.Errorf("Expected %d inspects, got %d", expectedInspects, fake.inspects)
+			}
+		})
+	}
+}
+
+func TestUpdateContainer(t *testing.T) {
+	fake := &fakeDocker{
+		inspectResult: container.InspectResponse{
+			ID:     "abc123",
+			Name:   "/plex",
+			Config: &container.Config{Image: "plexinc/pms-docker"},
+			Mounts: []container.MountPoint{{Source: "/mnt/user/Media", Destination: "/data"}},
+		},
+	}
+
+	client := &Client{
+		containerCache: map[string]types.Container{
+			"abc123": {ID: "abc123", Name: "plex"},
+		},
+		dockerClient: fake,
+	}
+
+	client.updateContainer(context.Background(), "abc123")
+
+	if mounts := client.containerCache["abc123"].Mounts; len(mounts) != 1 {
+		t.Errorf("Expected inspect data to add the mounts, got %v", mounts)
+	}
+
+	// A container destroyed while the inspect was running is not brought back
+	delete(client.containerCache, "abc123")
+	client.updateContainer(context.Background(), "abc123")
+
+	if _, exists := client.containerCache["abc123"]; exists {
+		t.Error("Expected destroyed container to stay out of the cache")
+	}
+}
+
+func TestAddUnknownContainer_PrunesExpired(t *testing.T) {
+	client := &Client{
+		unknownContainers: make(map[string]time.Time),
+	}
+
+	now := time.Now()
+
+	for i := range maxUnknownContainers {
+		client.unknownContainers[string(rune(i))] = now.Add(-time.Second)
+	}
+
+	client.addUnknownContainer("fresh", now)
+
+	if len(client.unknownContainers) != 1 {
+		t.Errorf(
+			"Expected expired entries to be pruned, got %d entries",
+			len(client.unknownContainers),
+		)
+	}
+}
+
+func TestAddUnknownContainer_EvictsOldest(t *testing.T) {
+	client := &Client{
+		unknownContainers: make(map[string]time.Time),
+	}
+
+	now := time.Now()
+
+	for i := range maxUnknownContainers {
+		expires := now.Add(time.Duration(i+1) * time.Second)
+		client.unknownContainers[fmt.Sprintf("live%d", i)] = expires
+	}
+
+	client.addUnknownContainer("fresh", now)
+
+	if len(client.unknownContainers) != maxUnknownContainers {
+		t.Errorf(
+			"Expected cache to stay at %d entries, got %d",
+			maxUnknownContainers,
+			len(client.unknownContainers),
+		)
+	}
+
+	if _, exists := client.unknownContainers["live0"]; exists {
+		t.Error("Expected the entry closest to expiry to be evicted")
+	}
+
+	if _, exists := client.unknownContainers["fresh"]; !exists {
+		t.Error("Expected the new entry to be cached")
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	tests := []struct {
+		current  time.Duration
+		expected time.Duration
+	}{
+		{time.Second, 2 * time.Second},
+		{16 * time.Second, 32 * time.Second},
+		{45 * time.Second, maxEventStreamBackoff},
+		{maxEventStreamBackoff, maxEventStreamBackoff},
+	}
+
+	for _, tt := range tests {
+		result := nextBackoff(tt.current)
+		if result != tt.expected {
+			t.Errorf("nextBackoff(%v) = %v, expected %v", tt.current, result, tt.expected)
+		}
+	}
+}
+
+func TestWatch_WithoutDockerClient(t *testing.T) {
+	client := &Client{
+		containerCache: make(map[string]types.Container),
+	}
+
+	// Should return immediately without starting a stream
+	client.Watch(context.Background())
+}
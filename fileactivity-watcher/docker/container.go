@@ -0,0 +1,132 @@
+package docker
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/moby/moby/api/types/container"
+)
+
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	unraidManagedLabel  = "net.unraid.docker.managed"
+)
+
+// templateDir holds the user templates created by the Unraid Docker manager.
+var templateDir = "/boot/config/plugins/dockerMan/templates-user" //nolint:gochecknoglobals
+
+// statTemplate checks for a template file. Tests replace it to observe lookups.
+var statTemplate = os.Stat //nolint:gochecknoglobals
+
+func containerFromSummary(summary container.Summary) types.Container {
+	name := ""
+	if len(summary.Names) > 0 {
+		name = strings.TrimPrefix(summary.Names[0], "/")
+	}
+
+	ctr := newContainer(summary.ID, name, summary.Image, summary.Labels)
+	ctr.Mounts = convertMounts(summary.Mounts)
+
+	return ctr
+}
+
+func containerFromInspect(inspect container.InspectResponse) types.Container {
+	image := inspect.Image
+	labels := map[string]string{}
+
+	if inspect.Config != nil {
+		image = inspect.Config.Image
+		labels = inspect.Config.Labels
+	}
+
+	ctr := newContainer(inspect.ID, strings.TrimPrefix(inspect.Name, "/"), image, labels)
+	ctr.Mounts = convertMounts(inspect.Mounts)
+
+	return ctr
+}
+
+func convertMounts(mountPoints []container.MountPoint) []types.Mount {
+	mounts := make([]types.Mount, 0, len(mountPoints))
+
+	for _, mountPoint := range mountPoints {
+		// tmpfs mounts have no host side
+		if mountPoint.Source == "" || mountPoint.Destination == "" {
+			continue
+		}
+
+		mounts = append(mounts, types.Mount{
+			Source:      filepath.Clean(mountPoint.Source),
+			Destination: filepath.Clean(mountPoint.Destination),
+		})
+	}
+
+	return mounts
+}
+
+// containerFromAttributes builds a container from the name and image in the
+// actor attributes of a container event. The attributes mix the container labels
+// with action specific keys such as exitCode or execDuration, so the labels are
+// taken from earlier inspect or list data instead.
+func containerFromAttributes(
+	containerID string,
+	attributes map[string]string,
+	labels map[string]string,
+) types.Container {
+	return newContainer(
+		containerID,
+		strings.TrimPrefix(attributes["name"], "/"),
+		attributes["image"],
+		labels,
+	)
+}
+
+func newContainer(id, name, image string, labels map[string]string) types.Container {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	return types.Container{
+		ID:             id,
+		Name:           name,
+		Image:          image,
+		ComposeProject: labels[composeProjectLabel],
+		Template:       findTemplate(name, labels),
+		Labels:         labels,
+	}
+}
+
+// findTemplate returns the Unraid template a managed container was created from.
+func findTemplate(name string, labels map[string]string) string {
+	if name == "" || labels[unraidManagedLabel] == "" {
+		return ""
+	}
+
+	templatePath := filepath.Join(templateDir, "my-"+name+".xml")
+
+	_, err := statTemplate(templatePath)
+	if err != nil {
+		return ""
+	}
+
+	return templatePath
+}
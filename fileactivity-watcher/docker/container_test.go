@@ -0,0 +1,197 @@
+package docker
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/moby/api/types/container"
+)
+
+func TestContainerFromSummary(t *testing.T) {
+	summary := container.Summary{
+		ID:    "abc123",
+		Names: []string{"/sonarr"},
+		Image: "lscr.io/linuxserver/sonarr:latest",
+		Labels: map[string]string{
+			composeProjectLabel: "arr",
+			"maintainer":        "linuxserver.io",
+		},
+	}
+
+	ctr := containerFromSummary(summary)
+
+	if ctr.ID != "abc123" {
+		t.Errorf("Expected ID to be 'abc123', got %s", ctr.ID)
+	}
+
+	if ctr.Name != "sonarr" {
+		t.Errorf("Expected Name to be 'sonarr', got %s", ctr.Name)
+	}
+
+	if ctr.Image != "lscr.io/linuxserver/sonarr:latest" {
+		t.Errorf("Expected Image to be set, got %s", ctr.Image)
+	}
+
+	if ctr.ComposeProject != "arr" {
+		t.Errorf("Expected ComposeProject to be 'arr', got %s", ctr.ComposeProject)
+	}
+
+	if ctr.Labels["maintainer"] != "linuxserver.io" {
+		t.Error("Expected labels to be copied")
+	}
+}
+
+func TestContainerFromSummary_NoNames(t *testing.T) {
+	ctr := containerFromSummary(container.Summary{ID: "abc123"})
+
+	if ctr.Name != "" {
+		t.Errorf("Expected empty Name, got %s", ctr.Name)
+	}
+
+	if ctr.Labels == nil {
+		t.Error("Expected Labels to be initialized")
+	}
+}
+
+func TestContainerFromInspect(t *testing.T) {
+	inspect := container.InspectResponse{
+		ID:    "abc123",
+		Name:  "/plex",
+		Image: "sha256:deadbeef",
+		Config: &container.Config{
+			Image:  "plexinc/pms-docker",
+			Labels: map[string]string{unraidManagedLabel: "dockerman"},
+		},
+	}
+
+	ctr := containerFromInspect(inspect)
+
+	if ctr.Name != "plex" {
+		t.Errorf("Expected Name to be 'plex', got %s", ctr.Name)
+	}
+
+	// The image reference from the config is preferred over the image ID
+	if ctr.Image != "plexinc/pms-docker" {
+		t.Errorf("Expected Image to be 'plexinc/pms-docker', got %s", ctr.Image)
+	}
+
+	if ctr.Labels[unraidManagedLabel] != "dockerman" {
+		t.Error("Expected labels to be copied")
+	}
+}
+
+func TestContainerFromAttributes(t *testing.T) {
+	attributes := map[string]string{
+		"name":              "radarr",
+		"image":             "lscr.io/linuxserver/radarr",
+		"exitCode":          "0",
+		"execDuration":      "12",
+		composeProjectLabel: "arr",
+	}
+	labels := map[string]string{composeProjectLabel: "arr"}
+
+	ctr := containerFromAttributes("abc123", attributes, labels)
+
+	if ctr.Name != "radarr" {
+		t.Errorf("Expected Name to be 'radarr', got %s", ctr.Name)
+	}
+
+	if ctr.Image != "lscr.io/linuxserver/radarr" {
+		t.Errorf("Expected Image to be set, got %s", ctr.Image)
+	}
+
+	if ctr.ComposeProject != "arr" {
+		t.Errorf("Expected ComposeProject to be 'arr', got %s", ctr.ComposeProject)
+	}
+
+	for _, key := range []string{"name", "image", "exitCode", "execDuration"} {
+		if _, exists := ctr.Labels[key]; exists {
+			t.Errorf("Expected event attribute %q not to be treated as a label", key)
+		}
+	}
+
+	// Without known labels, nothing from the event is stored as a label
+	ctr = containerFromAttributes("abc123", attributes, nil)
+
+	if len(ctr.Labels) != 0 {
+		t.Errorf("Expected no labels from event attributes, got %v", ctr.Labels)
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	original := templateDir
+	templateDir = tmpDir
+
+	t.Cleanup(func() { templateDir = original })
+
+	templatePath := filepath.Join(tmpDir, "my-plex.xml")
+
+	err := os.WriteFile(templatePath, []byte("<Container/>"), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	managed := map[string]string{unraidManagedLabel: "dockerman"}
+
+	tests := []struct {
+		name     string
+		ctrName  string
+		labels   map[string]string
+		expected string
+	}{
+		{"managed with template", "plex", managed, templatePath},
+		{"managed without template", "sonarr", managed, ""},
+		{"not managed", "plex", map[string]string{}, ""},
+		{"no name", "", managed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := findTemplate(tt.ctrName, tt.labels)
+			if result != tt.expected {
+				t.Errorf("findTemplate(%q) = %q, expected %q", tt.ctrName, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertMounts(t *testing.T) {
+	mounts := convertMounts([]container.MountPoint{
+		{Source: "/mnt/user/Media/", Destination: "/data"},
+		{Source: "", Destination: "/tmp"}, // tmpfs
+		{Source: "/mnt/user/appdata/plex", Destination: "/config/"},
+	})
+
+	if len(mounts) != 2 {
+		t.Fatalf("Expected 2 mounts, got %d", len(mounts))
+	}
+
+	if mounts[0].Source != "/mnt/user/Media" || mounts[0].Destination != "/data" {
+		t.Errorf("Unexpected first mount: %+v", mounts[0])
+	}
+
+	if mounts[1].Destination != "/config" {
+		t.Errorf("Expected destination to be cleaned, got %s", mounts[1].Destination)
+	}
+}
@@ -0,0 +1,93 @@
+package docker
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// Directories under /mnt that are not individual array disks or pools.
+var nonDiskMounts = map[string]bool{ //nolint:gochecknoglobals
+	"user":      true,
+	"user0":     true,
+	"disks":     true,
+	"remotes":   true,
+	"addons":    true,
+	"rootshare": true,
+}
+
+// ContainerPath translates a host path into the path the container sees it at.
+// Containers usually map user shares (/mnt/user/<share>) while events are reported
+// against the disk the file lives on (/mnt/disk2/<share>), so the user share
+// equivalents of the host path are tried as well. The mount with the longest
+// matching source wins. An empty string is returned if no mount covers the path.
+func ContainerPath(ctr types.Container, hostPath string) string {
+	bestSource := -1
+	containerPath := ""
+
+	for _, candidate := range hostPathAliases(hostPath) {
+		for _, mount := range ctr.Mounts {
+			remainder, ok := cutPathPrefix(candidate, mount.Source)
+			if !ok || len(mount.Source) <= bestSource {
+				continue
+			}
+
+			bestSource = len(mount.Source)
+			containerPath = filepath.Join(mount.Destination, remainder)
+		}
+	}
+
+	return containerPath
+}
+
+// hostPathAliases returns the host path followed by its user share equivalents.
+func hostPathAliases(hostPath string) []string {
+	hostPath = filepath.Clean(hostPath)
+	aliases := []string{hostPath}
+
+	rest, ok := strings.CutPrefix(hostPath, "/mnt/")
+	if !ok {
+		return aliases
+	}
+
+	disk, sharePath, ok := strings.Cut(rest, "/")
+	if !ok || nonDiskMounts[disk] {
+		return aliases
+	}
+
+	return append(aliases, "/mnt/user/"+sharePath, "/mnt/user0/"+sharePath)
+}
+
+// cutPathPrefix removes prefix from path if it matches on a path component boundary.
+func cutPathPrefix(path, prefix string) (string, bool) {
+	if prefix == "/" {
+		return path, true
+	}
+
+	if path == prefix {
+		return "", true
+	}
+
+	remainder, ok := strings.CutPrefix(path, prefix+"/")
+
+	return remainder, ok
+}
@@ -0,0 +1,115 @@
+package docker
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func TestContainerPath(t *testing.T) {
+	plex := types.Container{
+		Name: "plex",
+		Mounts: []types.Mount{
+			{Source: "/mnt/user/Media", Destination: "/data"},
+			{Source: "/mnt/user/Media/Movies", Destination: "/movies"},
+			{Source: "/mnt/user/appdata/plex", Destination: "/config"},
+			{Source: "/mnt/disks/backup", Destination: "/backup"},
+			{Source: "/mnt/disk3/Scratch", Destination: "/scratch"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		hostPath string
+		expected string
+	}{
+		{"disk path via user share", "/mnt/disk2/Media/TV/x.mkv", "/data/TV/x.mkv"},
+		{"longest mount wins", "/mnt/disk2/Media/Movies/x.mkv", "/movies/x.mkv"},
+		{
+			"pool path via user share",
+			"/mnt/cache/appdata/plex/Preferences.xml",
+			"/config/Preferences.xml",
+		},
+		{"mount root", "/mnt/disk1/Media/Movies", "/movies"},
+		{"direct disk mount", "/mnt/disk3/Scratch/tmp.bin", "/scratch/tmp.bin"},
+		{"unassigned device", "/mnt/disks/backup/2026/db.tar", "/backup/2026/db.tar"},
+		{"partial component does not match", "/mnt/disk2/MediaArchive/x.mkv", ""},
+		{"not mounted", "/mnt/disk2/Documents/x.pdf", ""},
+		{"other disk for direct mount", "/mnt/disk4/Scratch/tmp.bin", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ContainerPath(plex, tt.hostPath)
+			if result != tt.expected {
+				t.Errorf("ContainerPath(%q) = %q, expected %q", tt.hostPath, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainerPath_NoMounts(t *testing.T) {
+	result := ContainerPath(types.Container{Name: "plex"}, "/mnt/disk1/Media/x.mkv")
+	if result != "" {
+		t.Errorf("Expected empty path for container without mounts, got %q", result)
+	}
+}
+
+func TestHostPathAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostPath string
+		expected []string
+	}{
+		{
+			"array disk",
+			"/mnt/disk1/Media/x.mkv",
+			[]string{"/mnt/disk1/Media/x.mkv", "/mnt/user/Media/x.mkv", "/mnt/user0/Media/x.mkv"},
+		},
+		{
+			"pool",
+			"/mnt/cache/appdata/x",
+			[]string{"/mnt/cache/appdata/x", "/mnt/user/appdata/x", "/mnt/user0/appdata/x"},
+		},
+		{"unassigned device", "/mnt/disks/usb/x", []string{"/mnt/disks/usb/x"}},
+		{"user share", "/mnt/user/Media/x", []string{"/mnt/user/Media/x"}},
+		{"outside mnt", "/var/lib/docker/x", []string{"/var/lib/docker/x"}},
+		{"disk root", "/mnt/disk1", []string{"/mnt/disk1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := hostPathAliases(tt.hostPath)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("hostPathAliases(%q) = %v, expected %v", tt.hostPath, result, tt.expected)
+			}
+
+			for i := range result {
+				if result[i] != tt.expected[i] {
+					t.Errorf("hostPathAliases(%q)[%d] = %q, expected %q",
+						tt.hostPath, i, result[i], tt.expected[i])
+
+					break
+				}
+			}
+		})
+	}
+}
@@ -19,71 +19,204 @@ package filter
 */
 
 import (
+	"encoding/binary"
+	"hash/maphash"
+	"sync"
 	"time"
 
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 	"github.com/rs/zerolog/log"
 )
 
-func (f *Filter) startEventDedupeCleanup() {
-	go func() {
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
+const (
+	dedupeShards = 64
 
-		for range ticker.C {
-			f.cleanupStaleEvents()
-		}
-	}()
+	// Approximate memory used by one cached event: the entry itself plus its map slot
+	dedupeEntryBytes = 96
+
+	dedupeCleanupInterval = 5 * time.Second
+
+	defaultDedupeMemoryMB = 16
+)
+
+// dedupeCache remembers recently seen events to filter repeats within the dedupe
+// window. Events are stored as 64-bit hashes in shards, each with its own lock
+// and a list ordered from least to most recently recorded. As the window is the
+// same for every event, expired entries are always at the front of the list, so
+// expiry and eviction never have to scan a whole shard.
+type dedupeCache struct {
+	seed     maphash.Seed
+	window   time.Duration
+	shardCap int
+	shards   [dedupeShards]dedupeShard
 }
 
-func (f *Filter) isDuplicateEvent(event types.Event) bool {
-	now := time.Now()
+type dedupeShard struct {
+	mu      sync.Mutex
+	entries map[uint64]*dedupeEntry
+	oldest  *dedupeEntry
+	newest  *dedupeEntry
+}
 
-	// Check if we've seen this event recently (read lock)
-	f.recentEventsMutex.RLock()
+type dedupeEntry struct {
+	key      uint64
+	lastSeen time.Time
+	prev     *dedupeEntry
+	next     *dedupeEntry
+}
 
-	if timestamp, exists := f.recentEvents[event]; exists {
-		if now.Sub(timestamp.lastSeen) < f.dedupeWindow {
-			f.recentEventsMutex.RUnlock()
-			log.Debug().
-				Str("file", event.File).
-				Str("op", event.Op).
-				Int("pid", event.PID).
-				Msg("Filtered duplicate event")
+// newDedupeCache creates a cache whose entries use at most about maxBytes of memory.
+func newDedupeCache(window time.Duration, maxBytes int) *dedupeCache {
+	cache := &dedupeCache{
+		seed:     maphash.MakeSeed(),
+		window:   window,
+		shardCap: max(maxBytes/dedupeEntryBytes/dedupeShards, 1),
+	}
 
-			return true
-		}
+	for i := range cache.shards {
+		cache.shards[i].entries = make(map[uint64]*dedupeEntry)
 	}
 
-	f.recentEventsMutex.RUnlock()
+	return cache
+}
 
-	// Not a duplicate, record this event (write lock)
-	f.recentEventsMutex.Lock()
-	defer f.recentEventsMutex.Unlock()
+// dedupeMemory returns the memory ceiling in bytes for a configured size in MiB.
+func dedupeMemory(megabytes int) int {
+	if megabytes <= 0 {
+		megabytes = defaultDedupeMemoryMB
+	}
 
-	// Double-check after acquiring write lock
-	if timestamp, exists := f.recentEvents[event]; exists {
-		if now.Sub(timestamp.lastSeen) < f.dedupeWindow {
-			return true
-		}
+	return megabytes * 1024 * 1024
+}
 
-		timestamp.lastSeen = now
-	} else {
-		f.recentEvents[event] = &eventTimestamp{lastSeen: now}
+func (c *dedupeCache) hash(event types.Event) uint64 {
+	var hash maphash.Hash
+
+	hash.SetSeed(c.seed)
+	hash.WriteString(event.File)
+	hash.WriteByte(0)
+	hash.WriteString(event.Image)
+
+	var numbers [10]byte
+
+	binary.LittleEndian.PutUint64(numbers[:8], uint64(event.PID))
+	binary.LittleEndian.PutUint16(numbers[8:], uint16(event.Op))
+	hash.Write(numbers[:])
+
+	return hash.Sum64()
+}
+
+// seen reports whether the event was recorded within the window, and records it if not.
+func (c *dedupeCache) seen(event types.Event, now time.Time) bool {
+	key := c.hash(event)
+	shard := &c.shards[key%dedupeShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.expire(now, c.window)
+
+	// Expired entries were removed above, so a cached event is a repeat within the window
+	if _, exists := shard.entries[key]; exists {
+		return true
+	}
+
+	if len(shard.entries) >= c.shardCap {
+		shard.remove(shard.oldest)
 	}
 
+	entry := &dedupeEntry{key: key, lastSeen: now}
+	shard.entries[key] = entry
+	shard.append(entry)
+
 	return false
 }
 
-func (f *Filter) cleanupStaleEvents() {
-	now := time.Now()
+// cleanup removes the expired entries from all shards, one shard at a time.
+func (c *dedupeCache) cleanup(now time.Time) {
+	for i := range c.shards {
+		shard := &c.shards[i]
+
+		shard.mu.Lock()
+		shard.expire(now, c.window)
+		shard.mu.Unlock()
+	}
+}
 
-	f.recentEventsMutex.Lock()
-	defer f.recentEventsMutex.Unlock()
+func (c *dedupeCache) len() int {
+	count := 0
 
-	for key, timestamp := range f.recentEvents {
-		if now.Sub(timestamp.lastSeen) > f.dedupeWindow*2 {
-			delete(f.recentEvents, key)
+	for i := range c.shards {
+		shard := &c.shards[i]
+
+		shard.mu.Lock()
+		count += len(shard.entries)
+		shard.mu.Unlock()
+	}
+
+	return count
+}
+
+// expire removes the entries recorded more than window ago.
+func (s *dedupeShard) expire(now time.Time, window time.Duration) {
+	for s.oldest != nil && now.Sub(s.oldest.lastSeen) >= window {
+		s.remove(s.oldest)
+	}
+}
+
+func (s *dedupeShard) append(entry *dedupeEntry) {
+	entry.prev = s.newest
+	entry.next = nil
+
+	if s.newest != nil {
+		s.newest.next = entry
+	} else {
+		s.oldest = entry
+	}
+
+	s.newest = entry
+}
+
+func (s *dedupeShard) remove(entry *dedupeEntry) {
+	if entry.prev != nil {
+		entry.prev.next = entry.next
+	} else {
+		s.oldest = entry.next
+	}
+
+	if entry.next != nil {
+		entry.next.prev = entry.prev
+	} else {
+		s.newest = entry.prev
+	}
+
+	entry.prev = nil
+	entry.next = nil
+
+	delete(s.entries, entry.key)
+}
+
+func (f *Filter) startEventDedupeCleanup() {
+	go func() {
+		ticker := time.NewTicker(dedupeCleanupInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			f.recentEvents.cleanup(now)
 		}
+	}()
+}
+
+func (f *Filter) isDuplicateEvent(event types.Event) bool {
+	if !f.recentEvents.seen(event, time.Now()) {
+		return false
 	}
+
+	log.Debug().
+		Str("file", event.File).
+		Stringer("op", event.Op).
+		Int("pid", event.PID).
+		Msg("Filtered duplicate event")
+
+	return true
 }
@@ -0,0 +1,228 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func TestDedupeCache_Window(t *testing.T) {
+	cache := newDedupeCache(time.Second, dedupeMemory(1))
+
+	start := time.Now()
+	event := types.Event{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite}
+
+	if cache.seen(event, start) {
+		t.Fatal("First occurrence should not be a duplicate")
+	}
+
+	if !cache.seen(event, start.Add(999*time.Millisecond)) {
+		t.Error("Repeat within the window should be a duplicate")
+	}
+
+	// The window starts at the first occurrence and is not extended by repeats
+	if cache.seen(event, start.Add(time.Second)) {
+		t.Error("Repeat after the window should not be a duplicate")
+	}
+
+	if !cache.seen(event, start.Add(1500*time.Millisecond)) {
+		t.Error("Repeat within the new window should be a duplicate")
+	}
+}
+
+func TestDedupeCache_KeyFields(t *testing.T) {
+	cache := newDedupeCache(time.Minute, dedupeMemory(1))
+	now := time.Now()
+
+	base := types.Event{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite}
+	cache.seen(base, now)
+
+	variants := []types.Event{
+		{File: "/mnt/disk1/b.txt", PID: 1, Op: types.OpWrite},
+		{File: "/mnt/disk1/a.txt", PID: 2, Op: types.OpWrite},
+		{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpRead},
+		{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite, Image: "/mnt/disk1/x.img"},
+	}
+
+	for _, variant := range variants {
+		if cache.seen(variant, now) {
+			t.Errorf("Expected %+v not to be a duplicate of %+v", variant, base)
+		}
+	}
+}
+
+func TestDedupeCache_SizeCap(t *testing.T) {
+	cache := newDedupeCache(time.Hour, 1)
+
+	if cache.shardCap != 1 {
+		t.Fatalf("Expected the smallest cache to hold 1 entry per shard, got %d", cache.shardCap)
+	}
+
+	now := time.Now()
+
+	for i := range 10000 {
+		cache.seen(types.Event{File: "/mnt/disk1/" + strconv.Itoa(i)}, now)
+	}
+
+	if count := cache.len(); count > dedupeShards {
+		t.Errorf("Expected at most %d entries, got %d", dedupeShards, count)
+	}
+}
+
+func TestDedupeCache_EvictsOldest(t *testing.T) {
+	cache := newDedupeCache(time.Hour, dedupeMemory(1))
+	cache.shardCap = 2
+
+	now := time.Now()
+
+	// Find three events that land in the same shard
+	events := []types.Event{}
+
+	for i := 0; len(events) < 3; i++ {
+		event := types.Event{File: "/mnt/disk1/" + strconv.Itoa(i)}
+		if cache.hash(event)%dedupeShards == 0 {
+			events = append(events, event)
+		}
+	}
+
+	cache.seen(events[0], now)
+	cache.seen(events[1], now.Add(time.Second))
+	cache.seen(events[2], now.Add(2*time.Second))
+
+	if !cache.seen(events[2], now.Add(3*time.Second)) {
+		t.Error("Expected the newest event to stay cached")
+	}
+
+	if !cache.seen(events[1], now.Add(3*time.Second)) {
+		t.Error("Expected the second event to stay cached")
+	}
+
+	if cache.seen(events[0], now.Add(3*time.Second)) {
+		t.Error("Expected the oldest event to be evicted")
+	}
+}
+
+func TestDedupeCache_Cleanup(t *testing.T) {
+	cache := newDedupeCache(time.Second, dedupeMemory(1))
+	now := time.Now()
+
+	for i := range 100 {
+		offset := time.Duration(i) * 10 * time.Millisecond
+		cache.seen(types.Event{File: "/mnt/disk1/" + strconv.Itoa(i)}, now.Add(offset))
+	}
+
+	// Entries seen in the first half second have expired
+	cache.cleanup(now.Add(1495 * time.Millisecond))
+
+	if count := cache.len(); count != 50 {
+		t.Errorf("Expected 50 entries after cleanup, got %d", count)
+	}
+
+	for i := range cache.shards {
+		shard := &cache.shards[i]
+		for entry := shard.oldest; entry != nil; entry = entry.next {
+			if entry.next == nil && entry != shard.newest {
+				t.Fatalf("Shard %d list is inconsistent", i)
+			}
+		}
+	}
+}
+
+func TestDedupeCache_MemoryCeiling(t *testing.T) {
+	const megabytes = 4
+
+	cache := newDedupeCache(time.Hour, dedupeMemory(megabytes))
+
+	var before runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	now := time.Now()
+
+	for i := range 1_000_000 {
+		cache.seen(types.Event{File: "/mnt/disk1/dir/file" + strconv.Itoa(i), PID: i}, now)
+	}
+
+	var after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if count := cache.len(); count > megabytes*1024*1024/dedupeEntryBytes {
+		t.Errorf("Expected the entry count to be capped, got %d", count)
+	}
+
+	// Allow for the map growth headroom on top of the estimate
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 2*megabytes*1024*1024 {
+		t.Errorf("Expected heap growth under %d MiB, got %d bytes", 2*megabytes, grown)
+	}
+}
+
+func BenchmarkDedupe_UniquePaths(b *testing.B) {
+	cache := newDedupeCache(time.Second, dedupeMemory(defaultDedupeMemoryMB))
+	paths := make([]string, 1<<16)
+
+	for i := range paths {
+		paths[i] = "/mnt/disk1/Media/Movies/Some Movie (2024)/file" + strconv.Itoa(i) + ".mkv"
+	}
+
+	now := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := range b.N {
+		cache.seen(types.Event{File: paths[i%len(paths)], PID: i, Op: types.OpOpen}, now)
+	}
+}
+
+func BenchmarkDedupe_Repeats(b *testing.B) {
+	cache := newDedupeCache(time.Hour, dedupeMemory(defaultDedupeMemoryMB))
+	event := types.Event{File: "/mnt/disk1/Media/movie.mkv", PID: 1234, Op: types.OpRead}
+	now := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		cache.seen(event, now)
+	}
+}
+
+func BenchmarkDedupe_Parallel(b *testing.B) {
+	cache := newDedupeCache(time.Second, dedupeMemory(defaultDedupeMemoryMB))
+	now := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.seen(types.Event{File: "/mnt/disk1/file" + strconv.Itoa(i%4096), PID: i}, now)
+			i++
+		}
+	})
+}
@@ -21,9 +21,9 @@ package filter
 import (
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/coalesce"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 	"github.com/rs/zerolog/log"
@@ -32,9 +32,12 @@ import (
 type Filter struct {
 	Exclusions []*regexp.Regexp
 
-	recentEvents      map[types.Event]*eventTimestamp
-	recentEventsMutex sync.RWMutex
-	dedupeWindow      time.Duration
+	ignoreFile       *ignoreList
+	rules            []*rule
+	coalesceDefaults coalesce.Settings
+	stats            *statsRecorder
+
+	recentEvents *dedupeCache
 }
 
 func New(appConfig config.ActivityConfig) *Filter {
@@ -57,27 +60,88 @@ func New(appConfig config.ActivityConfig) *Filter {
 		exclusionFilters = append(exclusionFilters, compiledFilter)
 	}
 
+	defaults := coalesceDefaults(appConfig)
+
 	filter := &Filter{
-		Exclusions:   exclusionFilters,
-		recentEvents: make(map[types.Event]*eventTimestamp),
-		dedupeWindow: time.Duration(appConfig.DedupeWindow) * time.Second,
+		Exclusions:       exclusionFilters,
+		ignoreFile:       &ignoreList{},
+		rules:            compileRules(appConfig, defaults),
+		coalesceDefaults: defaults,
+		recentEvents: newDedupeCache(
+			time.Duration(appConfig.DedupeWindow)*time.Second,
+			dedupeMemory(appConfig.DedupeMemoryMB),
+		),
+	}
+
+	if appConfig.ExclusionFile != "" {
+		filter.ignoreFile = loadIgnoreFile(appConfig.ExclusionFile)
+	}
+
+	exclusionNames := make([]string, 0, len(filter.Exclusions)+len(filter.ignoreFile.patterns))
+	for _, exclusion := range filter.Exclusions {
+		exclusionNames = append(exclusionNames, exclusion.String())
 	}
 
+	for _, pattern := range filter.ignoreFile.patterns {
+		exclusionNames = append(exclusionNames, "file:"+pattern.line)
+	}
+
+	ruleNames := make([]string, 0, len(filter.rules))
+	for _, rule := range filter.rules {
+		ruleNames = append(ruleNames, rule.name)
+	}
+
+	filter.stats = newStatsRecorder(exclusionNames, ruleNames, time.Now())
+
 	filter.startEventDedupeCleanup()
 
 	return filter
 }
 
 func (f *Filter) IsExcluded(event types.Event) bool {
-	return f.matchesExclusionFilter(event.File) || f.isDuplicateEvent(event)
+	return f.IsPathExcluded(event) || f.isDuplicateEvent(event)
+}
+
+// IsPathExcluded reports whether the event path matches an exclusion filter.
+// It only looks at the event itself, so it can run before any enrichment.
+// A keep or flag rule overrides the exclusions, but only if no rule before it
+// needs process or container details, as excluded events are never resolved.
+// Events inside loop-mounted images are only seen when loop_images asks for
+// them, so they are never excluded: the default exclusions for docker and
+// system would otherwise hide docker.img. Rules still apply to them.
+func (f *Filter) IsPathExcluded(event types.Event) bool {
+	if event.Image != "" || f.keptByRule(event, time.Now()) {
+		return false
+	}
+
+	return f.matchesExclusionFilter(event.File)
+}
+
+// IsDuplicate reports whether the same event was seen within the dedupe window.
+func (f *Filter) IsDuplicate(event types.Event) bool {
+	duplicate := f.isDuplicateEvent(event)
+	f.stats.recordDedupe(event.File, duplicate)
+
+	return duplicate
 }
 
 func (f *Filter) matchesExclusionFilter(path string) bool {
-	for _, filter := range f.Exclusions {
+	for i, filter := range f.Exclusions {
 		if filter.MatchString(path) {
+			f.stats.recordExclusions(path, i)
+
 			return true
 		}
 	}
 
+	// Negated patterns in the exclusion file only re-include what the file excludes
+	if matched := f.ignoreFile.match(path); matched >= 0 {
+		f.stats.recordExclusions(path, len(f.Exclusions)+matched)
+
+		return true
+	}
+
+	f.stats.recordExclusions(path, -1)
+
 	return false
 }
@@ -46,8 +46,8 @@ func TestNew(t *testing.T) {
 		t.Error("Expected recentEvents to be initialized")
 	}
 
-	if filter.dedupeWindow != 2*time.Second {
-		t.Errorf("Expected dedupeWindow to be 2s, got %v", filter.dedupeWindow)
+	if filter.recentEvents.window != 2*time.Second {
+		t.Errorf("Expected dedupe window to be 2s, got %v", filter.recentEvents.window)
 	}
 }
 
@@ -98,7 +98,7 @@ func TestIsExcluded_ExclusionFilter(t *testing.T) {
 	event := types.Event{
 		File: "/mnt/cache/appdata/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	if !filter.IsExcluded(event) {
@@ -117,7 +117,7 @@ func TestIsDuplicateEvent(t *testing.T) {
 	event := types.Event{
 		File: "/mnt/disk1/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	// First occurrence should not be a duplicate
@@ -134,7 +134,7 @@ func TestIsDuplicateEvent(t *testing.T) {
 	event2 := types.Event{
 		File: "/mnt/disk1/test.txt",
 		PID:  5678,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	if filter.isDuplicateEvent(event2) {
@@ -145,7 +145,7 @@ func TestIsDuplicateEvent(t *testing.T) {
 	event3 := types.Event{
 		File: "/mnt/disk1/other.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	if filter.isDuplicateEvent(event3) {
@@ -164,7 +164,7 @@ func TestIsDuplicateEvent_AfterDedupeWindow(t *testing.T) {
 	event := types.Event{
 		File: "/mnt/disk1/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	// First occurrence
@@ -177,17 +177,8 @@ func TestIsDuplicateEvent_AfterDedupeWindow(t *testing.T) {
 		t.Error("Second occurrence should be a duplicate within window")
 	}
 
-	// Manually manipulate the timestamp to simulate time passing
-	filter.recentEventsMutex.Lock()
-
-	if timestamp, exists := filter.recentEvents[event]; exists {
-		timestamp.lastSeen = time.Now().Add(-2 * time.Second)
-	}
-
-	filter.recentEventsMutex.Unlock()
-
 	// After dedupe window, should not be a duplicate
-	if filter.isDuplicateEvent(event) {
+	if filter.recentEvents.seen(event, time.Now().Add(2*time.Second)) {
 		t.Error("Event after dedupe window should not be a duplicate")
 	}
 }
@@ -204,7 +195,7 @@ func TestIsExcluded_Combined(t *testing.T) {
 	event1 := types.Event{
 		File: "/mnt/cache/appdata/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	if !filter.IsExcluded(event1) {
@@ -215,7 +206,7 @@ func TestIsExcluded_Combined(t *testing.T) {
 	event2 := types.Event{
 		File: "/mnt/disk1/data/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   types.OpWrite,
 	}
 
 	// First occurrence should not be excluded
@@ -238,37 +229,24 @@ func TestCleanupStaleEvents(t *testing.T) {
 	filter := New(appConfig)
 
 	// Add some events
-	event1 := types.Event{File: "/test1.txt", PID: 1, Op: "WRITE"}
-	event2 := types.Event{File: "/test2.txt", PID: 2, Op: "READ"}
+	event1 := types.Event{File: "/test1.txt", PID: 1, Op: types.OpWrite}
+	event2 := types.Event{File: "/test2.txt", PID: 2, Op: types.OpRead}
 
 	filter.isDuplicateEvent(event1)
 	filter.isDuplicateEvent(event2)
 
 	// Verify events are in cache
-	filter.recentEventsMutex.RLock()
-	initialCount := len(filter.recentEvents)
-	filter.recentEventsMutex.RUnlock()
+	initialCount := filter.recentEvents.len()
 
 	if initialCount != 2 {
 		t.Errorf("Expected 2 events in cache, got %d", initialCount)
 	}
 
-	// Manually set timestamps to old values
-	filter.recentEventsMutex.Lock()
-
-	for _, timestamp := range filter.recentEvents {
-		timestamp.lastSeen = time.Now().Add(-10 * time.Second)
-	}
-
-	filter.recentEventsMutex.Unlock()
-
-	// Run cleanup
-	filter.cleanupStaleEvents()
+	// Run cleanup as if 10 seconds had passed
+	filter.recentEvents.cleanup(time.Now().Add(10 * time.Second))
 
 	// Verify stale events were removed
-	filter.recentEventsMutex.RLock()
-	finalCount := len(filter.recentEvents)
-	filter.recentEventsMutex.RUnlock()
+	finalCount := filter.recentEvents.len()
 
 	if finalCount != 0 {
 		t.Errorf("Expected 0 events after cleanup, got %d", finalCount)
@@ -292,7 +270,7 @@ func TestFilter_ConcurrentAccess(t *testing.T) {
 				event := types.Event{
 					File: "/mnt/disk1/file.txt",
 					PID:  id*100 + j,
-					Op:   "WRITE",
+					Op:   types.OpWrite,
 				}
 				_ = filter.IsExcluded(event)
 			}
@@ -307,17 +285,6 @@ func TestFilter_ConcurrentAccess(t *testing.T) {
 	}
 }
 
-func TestEventTimestamp(t *testing.T) {
-	now := time.Now()
-	timestamp := &eventTimestamp{
-		lastSeen: now,
-	}
-
-	if !timestamp.lastSeen.Equal(now) {
-		t.Error("EventTimestamp lastSeen not set correctly")
-	}
-}
-
 func TestNew_EmptyExclusions(t *testing.T) {
 	appConfig := config.ActivityConfig{
 		Exclusions:   []string{},
@@ -353,8 +320,8 @@ func TestFilter_DifferentOperations(t *testing.T) {
 	filter := New(appConfig)
 
 	// Same file and PID but different operations
-	event1 := types.Event{File: "/test.txt", PID: 1234, Op: "WRITE"}
-	event2 := types.Event{File: "/test.txt", PID: 1234, Op: "READ"}
+	event1 := types.Event{File: "/test.txt", PID: 1234, Op: types.OpWrite}
+	event2 := types.Event{File: "/test.txt", PID: 1234, Op: types.OpRead}
 
 	// First write
 	if filter.isDuplicateEvent(event1) {
@@ -371,3 +338,102 @@ func TestFilter_DifferentOperations(t *testing.T) {
 		t.Error("Second WRITE should be a duplicate")
 	}
 }
+
+func TestIsPathExcludedAndIsDuplicate(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		Exclusions:   []string{`(?i)appdata`},
+		DedupeWindow: 1,
+	}
+
+	filter := New(appConfig)
+
+	excluded := types.Event{File: "/mnt/cache/appdata/test.txt", PID: 1234, Op: types.OpWrite}
+	if !filter.IsPathExcluded(excluded) {
+		t.Error("Expected path to be excluded")
+	}
+
+	// Path checks don't record events for deduplication
+	if filter.IsDuplicate(excluded) {
+		t.Error("Path check should not populate the dedupe cache")
+	}
+
+	event := types.Event{File: "/mnt/disk1/data/test.txt", PID: 1234, Op: types.OpWrite}
+	if filter.IsPathExcluded(event) {
+		t.Error("Expected path not to be excluded")
+	}
+
+	if filter.IsDuplicate(event) {
+		t.Error("First occurrence should not be a duplicate")
+	}
+
+	if !filter.IsDuplicate(event) {
+		t.Error("Second occurrence should be a duplicate")
+	}
+}
+
+func TestIsPathExcluded_KeepRules(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		Exclusions:   []string{`(?i)appdata`, `(?i)system`},
+		DedupeWindow: 1,
+		Rules: []config.Rule{
+			{Path: "^/mnt/cache/appdata/plex/", Action: "keep"},
+			{Glob: "/mnt/cache/appdata/nextcloud/**", Action: "flag"},
+			{Path: "^/mnt/cache/appdata/tmp/", Action: "drop"},
+			{Container: "sonarr", Action: "keep"},
+			{Path: "^/mnt/cache/system/", Action: "keep"},
+		},
+	}
+
+	filter := New(appConfig)
+
+	tests := []struct {
+		path     string
+		excluded bool
+	}{
+		{"/mnt/cache/appdata/plex/db.sqlite", false},
+		{"/mnt/cache/appdata/nextcloud/data/file.txt", false},
+		{"/mnt/cache/appdata/tmp/file.txt", true},
+		{"/mnt/cache/appdata/sonarr/config.xml", true},
+		// Rules after a container rule are not consulted for excluded events
+		{"/mnt/cache/system/docker.img", true},
+		{"/mnt/disk1/Media/movie.mkv", false},
+	}
+
+	for _, tt := range tests {
+		event := types.Event{File: tt.path, PID: 1234, Op: types.OpWrite}
+		if result := filter.IsPathExcluded(event); result != tt.excluded {
+			t.Errorf("IsPathExcluded(%q) = %v, expected %v", tt.path, result, tt.excluded)
+		}
+	}
+}
+
+func TestIsPathExcluded_LoopImages(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		Exclusions:   []string{`(?i)appdata`, `(?i)docker`, `(?i)system`, `(?i)syslogs`},
+		DedupeWindow: 1,
+		Rules: []config.Rule{
+			{Path: `/vm\.img$`, Action: "drop"},
+		},
+	}
+
+	filter := New(appConfig)
+
+	event := types.Event{
+		File:  "/var/lib/docker/btrfs/subvolumes/abc/config/db.sqlite",
+		PID:   1234,
+		Op:    types.OpWrite,
+		Image: "/mnt/disk1/system/docker/docker.img",
+	}
+
+	if filter.IsPathExcluded(event) {
+		t.Error("Expected events inside a watched loop image to bypass the exclusions")
+	}
+
+	// Rules see the backing image path
+	event.Image = "/mnt/disk1/domains/vm.img"
+
+	match, _ := filter.MatchRules(Subject{Event: event})
+	if match.Action != ActionDrop {
+		t.Errorf("Expected rule to match the backing image path, got %+v", match)
+	}
+}
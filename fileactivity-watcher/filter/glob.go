@@ -0,0 +1,91 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// globToRegexp converts a glob into an anchored regular expression.
+// "*" and "?" do not cross directory separators, "**" does, and "[...]" classes
+// are supported ("[!...]" negates). Globs that don't start with "/" may match
+// at any directory level, so "*.tmp" matches /mnt/disk1/a/b.tmp.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+
+	builder.WriteString("^")
+
+	if !strings.HasPrefix(glob, "/") {
+		builder.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		char := glob[i]
+
+		switch char {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+
+				switch {
+				case i+1 < len(glob) && glob[i+1] == '/':
+					// "**/" matches zero or more directories
+					i++
+
+					builder.WriteString("(?:.*/)?")
+				default:
+					builder.WriteString(".*")
+				}
+
+				continue
+			}
+
+			builder.WriteString("[^/]*")
+		case '?':
+			builder.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in %q", glob)
+			}
+
+			class := glob[i+1 : i+1+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+
+			builder.WriteString("[" + class + "]")
+
+			i += end + 1
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+
+	builder.WriteString("$")
+
+	compiled, err := regexp.Compile(builder.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+
+	return compiled, nil
+}
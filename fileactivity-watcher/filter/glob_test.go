@@ -0,0 +1,70 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"*.tmp", "/mnt/disk1/a/b.tmp", true},
+		{"*.tmp", "/mnt/disk1/a/b.tmp.keep", false},
+		{"**/*.tmp", "/mnt/disk1/b.tmp", true},
+		{"/mnt/disk1/*.tmp", "/mnt/disk1/b.tmp", true},
+		{"/mnt/disk1/*.tmp", "/mnt/disk1/a/b.tmp", false},
+		{"/mnt/*/appdata/**", "/mnt/cache/appdata/plex/x.db", true},
+		{"/mnt/*/appdata/**", "/mnt/disk1/Backups/old-appdata-notes.txt", false},
+		{"/mnt/**/x.db", "/mnt/x.db", true},
+		{"/mnt/**/x.db", "/mnt/cache/appdata/plex/x.db", true},
+		{"file?.txt", "/mnt/disk1/file1.txt", true},
+		{"file?.txt", "/mnt/disk1/file10.txt", false},
+		{"file[0-9].txt", "/mnt/disk1/file7.txt", true},
+		{"file[!0-9].txt", "/mnt/disk1/file7.txt", false},
+		{"file[!0-9].txt", "/mnt/disk1/fileA.txt", true},
+		{"a+b(c).txt", "/mnt/disk1/a+b(c).txt", true},
+		{".DS_Store", "/mnt/disk1/Media/.DS_Store", true},
+		{".DS_Store", "/mnt/disk1/Media/x.DS_Store", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			compiled, err := globToRegexp(tt.glob)
+			if err != nil {
+				t.Fatalf("globToRegexp(%q) failed: %v", tt.glob, err)
+			}
+
+			if result := compiled.MatchString(tt.path); result != tt.expected {
+				t.Errorf("glob %q match %q = %v, expected %v",
+					tt.glob, tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGlobToRegexp_Invalid(t *testing.T) {
+	_, err := globToRegexp("file[0-9.txt")
+	if err == nil {
+		t.Error("Expected error for unterminated character class")
+	}
+}
@@ -0,0 +1,191 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ignorePattern is one line of an exclusion file.
+type ignorePattern struct {
+	line    string
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList holds the patterns of an exclusion file. The syntax follows
+// gitignore: "#" starts a comment, "!" re-includes, a trailing "/" only matches
+// directories and patterns containing a "/" are anchored to the disk root, while
+// others match at any level. Unlike git, a pattern also applies inside the
+// directories it matches and the last matching pattern always wins, so
+// "/appdata/" followed by "!keep-this/" keeps /appdata/keep-this.
+type ignoreList struct {
+	patterns []ignorePattern
+}
+
+// loadIgnoreFile reads an exclusion file. A missing file is an empty list.
+func loadIgnoreFile(filename string) *ignoreList {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn().Str("file", filename).Err(err).Msg("Failed to read exclusion file")
+		}
+
+		return &ignoreList{}
+	}
+
+	list := parseIgnore(string(data))
+
+	log.Info().
+		Str("file", filename).
+		Int("patterns", len(list.patterns)).
+		Msg("Loaded exclusion file")
+
+	return list
+}
+
+func parseIgnore(data string) *ignoreList {
+	list := &ignoreList{}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, err := compileIgnorePattern(line)
+		if err != nil {
+			log.Warn().
+				Int("line", lineNumber).
+				Str("pattern", line).
+				Err(err).
+				Msg("Failed to compile exclusion pattern")
+
+			continue
+		}
+
+		list.patterns = append(list.patterns, pattern)
+	}
+
+	return list
+}
+
+func compileIgnorePattern(line string) (ignorePattern, error) {
+	pattern := ignorePattern{line: line}
+
+	glob := line
+	if rest, ok := strings.CutPrefix(glob, "!"); ok {
+		pattern.negate = true
+		glob = rest
+	}
+
+	// A backslash escapes a leading "#" or "!"
+	glob = strings.TrimPrefix(glob, `\`)
+
+	if rest, ok := strings.CutSuffix(glob, "/"); ok {
+		pattern.dirOnly = true
+		glob = rest
+	}
+
+	if glob == "" {
+		return pattern, fmt.Errorf("empty pattern %q", line)
+	}
+
+	// Patterns with a separator are relative to the disk root
+	if strings.Contains(glob, "/") && !strings.HasPrefix(glob, "/") {
+		glob = "/" + glob
+	}
+
+	compiled, err := globToRegexp(glob)
+	if err != nil {
+		return pattern, err
+	}
+
+	pattern.regexp = compiled
+
+	return pattern, nil
+}
+
+// match returns the index of the pattern that decides whether a path is excluded,
+// or -1 if it is not excluded.
+func (l *ignoreList) match(filePath string) int {
+	if len(l.patterns) == 0 {
+		return -1
+	}
+
+	relative := diskRelative(filePath)
+	decided := -1
+
+	for i, pattern := range l.patterns {
+		if pattern.matches(relative) {
+			decided = i
+		}
+	}
+
+	if decided < 0 || l.patterns[decided].negate {
+		return -1
+	}
+
+	return decided
+}
+
+// matches checks a path and the directories it is in against the pattern.
+func (p ignorePattern) matches(relative string) bool {
+	if !p.dirOnly && p.regexp.MatchString(relative) {
+		return true
+	}
+
+	for dir := path.Dir(relative); dir != "/" && dir != "."; dir = path.Dir(dir) {
+		if p.regexp.MatchString(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diskRelative returns a path relative to the root of the disk it is on, with a
+// leading "/", such as "/appdata/plex" for /mnt/disk1/appdata/plex.
+// Paths outside of /mnt are returned as they are.
+func diskRelative(filePath string) string {
+	rest, ok := strings.CutPrefix(filePath, "/mnt/")
+	if !ok {
+		return filePath
+	}
+
+	disk, rest, found := strings.Cut(rest, "/")
+	if found && (disk == "disks" || disk == "remotes") {
+		_, rest, found = strings.Cut(rest, "/")
+	}
+
+	if !found {
+		return "/"
+	}
+
+	return "/" + rest
+}
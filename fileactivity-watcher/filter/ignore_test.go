@@ -0,0 +1,152 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+)
+
+func TestIgnoreList_Match(t *testing.T) {
+	list := parseIgnore(`# Comments and blank lines are skipped
+
+/appdata/
+!keep-this/
+**/*.tmp
+!/isos/important.tmp
+downloads/incomplete
+\#hash
+*.part   
+`)
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/mnt/disk1/appdata/plex/x.db", true},
+		{"/mnt/cache/appdata/x.db", true},
+		{"/mnt/disk1/appdata", false},
+		{"/mnt/disk1/media/appdata/x.db", false},
+		{"/mnt/disk1/appdata/keep-this/x.db", false},
+		{"/mnt/disk1/appdata/plex/keep-this/x.db", false},
+		{"/mnt/disk1/a/b/c.tmp", true},
+		{"/mnt/disk1/c.tmp", true},
+		{"/mnt/disk1/isos/important.tmp", false},
+		{"/mnt/disk2/isos/other.tmp", true},
+		{"/mnt/disk1/downloads/incomplete/file.mkv", true},
+		{"/mnt/disk1/media/downloads/incomplete/file.mkv", false},
+		{"/mnt/disk1/#hash", true},
+		{"/mnt/disk1/movie.part", true},
+		{"/mnt/disks/usb/appdata/x.db", true},
+		{"/mnt/remotes/nas_share/a.tmp", true},
+		{"/mnt/disk1/media/movie.mkv", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := list.match(tt.path) >= 0; result != tt.expected {
+				t.Errorf("match(%q) = %v, expected %v", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseIgnore_Invalid(t *testing.T) {
+	list := parseIgnore("file[0-9.txt\n!\n/\n*.tmp\n")
+
+	if len(list.patterns) != 1 || list.patterns[0].line != "*.tmp" {
+		t.Errorf("Expected only the valid pattern to be kept, got %+v", list.patterns)
+	}
+}
+
+func TestDiskRelative(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/mnt/disk1/appdata/x.db", "/appdata/x.db"},
+		{"/mnt/cache/x.db", "/x.db"},
+		{"/mnt/disks/usb/a/b", "/a/b"},
+		{"/mnt/remotes/nas/a", "/a"},
+		{"/mnt/disk1", "/"},
+		{"/mnt/disks/usb", "/"},
+		{"/var/log/syslog", "/var/log/syslog"},
+	}
+
+	for _, tt := range tests {
+		if result := diskRelative(tt.path); result != tt.expected {
+			t.Errorf("diskRelative(%q) = %q, expected %q", tt.path, result, tt.expected)
+		}
+	}
+}
+
+func TestNew_ExclusionFile(t *testing.T) {
+	exclusionFile := filepath.Join(t.TempDir(), "exclusions.ignore")
+
+	err := os.WriteFile(exclusionFile, []byte("/appdata/\n!keep-this/\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := New(config.ActivityConfig{
+		Exclusions:    []string{`\.log$`},
+		ExclusionFile: exclusionFile,
+		DedupeWindow:  1,
+	})
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/mnt/disk1/appdata/x.db", true},
+		{"/mnt/disk1/appdata/keep-this/x.db", false},
+		{"/mnt/disk1/appdata/keep-this/x.log", true},
+		{"/mnt/disk1/media/x.mkv", false},
+	}
+
+	for _, tt := range tests {
+		if result := filter.matchesExclusionFilter(tt.path); result != tt.expected {
+			t.Errorf("matchesExclusionFilter(%q) = %v, expected %v",
+				tt.path, result, tt.expected)
+		}
+	}
+
+	stats := filter.Stats()
+	if len(stats.Exclusions) != 3 || stats.Exclusions[1].Name != "file:/appdata/" {
+		t.Fatalf("Expected exclusion file patterns in the stats, got %+v", stats.Exclusions)
+	}
+
+	if stats.Exclusions[1].Matched != 1 {
+		t.Errorf("Expected 1 match for /appdata/, got %d", stats.Exclusions[1].Matched)
+	}
+}
+
+func TestNew_MissingExclusionFile(t *testing.T) {
+	filter := New(config.ActivityConfig{
+		ExclusionFile: filepath.Join(t.TempDir(), "missing.ignore"),
+		DedupeWindow:  1,
+	})
+
+	if filter.matchesExclusionFilter("/mnt/disk1/appdata/x.db") {
+		t.Error("Expected no exclusions without an exclusion file")
+	}
+}
@@ -0,0 +1,582 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/coalesce"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/profiles"
+	"github.com/rs/zerolog/log"
+)
+
+// UnknownContainer is the name used to match container processes whose
+// container could not be resolved.
+const UnknownContainer = "unknown"
+
+// Action is what happens to an event that matches a rule.
+type Action int
+
+const (
+	ActionNone Action = iota // No rule matched
+	ActionDrop
+	ActionKeep
+	ActionFlag // Keep the event and mark it with the rule name
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionNone:
+		return "none"
+	case ActionDrop:
+		return "drop"
+	case ActionKeep:
+		return "keep"
+	case ActionFlag:
+		return "flag"
+	}
+
+	return "unknown"
+}
+
+// coalesceDefaults returns the coalesce settings for events that no rule overrides.
+func coalesceDefaults(appConfig config.ActivityConfig) coalesce.Settings {
+	key, err := coalesce.ParseKey(appConfig.CoalesceKey)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid coalesce key, using file, pid and op")
+	}
+
+	if key == 0 {
+		key = coalesce.DefaultKey
+	}
+
+	return coalesce.Settings{
+		Key:    key,
+		Window: time.Duration(appConfig.DedupeWindow) * time.Second,
+	}
+}
+
+// compileCoalesce applies the coalesce overrides of a rule to the defaults.
+func compileCoalesce(
+	configRule config.Rule,
+	defaults coalesce.Settings,
+) (coalesce.Settings, error) {
+	settings := defaults
+
+	if len(configRule.CoalesceKey) > 0 {
+		key, err := coalesce.ParseKey(configRule.CoalesceKey)
+		if err != nil {
+			return settings, fmt.Errorf("invalid coalesce key: %w", err)
+		}
+
+		settings.Key = key
+	}
+
+	if configRule.CoalesceWindow != nil {
+		if *configRule.CoalesceWindow < 0 {
+			return settings, errors.New("coalesce window must not be negative")
+		}
+
+		settings.Window = time.Duration(*configRule.CoalesceWindow) * time.Second
+	}
+
+	return settings, nil
+}
+
+func parseAction(action string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case "drop":
+		return ActionDrop, nil
+	case "keep":
+		return ActionKeep, nil
+	case "flag", "keep-and-flag":
+		return ActionFlag, nil
+	}
+
+	return ActionNone, fmt.Errorf("unknown action %q", action)
+}
+
+// Subject is an event together with what is known about where it came from.
+// The process and container details are only valid once Resolved is set.
+type Subject struct {
+	Event       types.Event
+	Time        time.Time
+	Resolved    bool
+	ProcessPath string
+	UID         int
+	Source      string
+	Container   types.Container
+}
+
+// Match is the outcome of evaluating the rules for a subject.
+type Match struct {
+	Action   Action
+	Rule     string
+	Coalesce coalesce.Settings
+}
+
+type rule struct {
+	name        string
+	description string
+	action      Action
+
+	path        *regexp.Regexp
+	glob        *regexp.Regexp
+	ops         types.Op
+	process     *regexp.Regexp
+	processName *regexp.Regexp
+	source      *regexp.Regexp
+	container   *regexp.Regexp
+	image       *regexp.Regexp
+	labelKey    string
+	labelValue  string
+	matchValue  bool
+	disk        string
+	share       string
+	uid         *int
+	timeRange   *timeRange
+
+	coalesceSettings coalesce.Settings
+}
+
+type timeRange struct {
+	start int // Minutes after midnight
+	end   int
+}
+
+func compileRules(appConfig config.ActivityConfig, defaults coalesce.Settings) []*rule {
+	configRules := append([]config.Rule{}, appConfig.Rules...)
+	names := make([]string, 0, len(configRules))
+
+	for i, configRule := range appConfig.Rules {
+		name := strings.TrimSpace(configRule.Name)
+		if name == "" {
+			name = "rules[" + strconv.Itoa(i) + "]"
+		}
+
+		names = append(names, name)
+	}
+
+	// Profiles come last, so that user rules can keep events a profile would drop
+	for _, profile := range profiles.Enabled(appConfig.Profiles) {
+		log.Info().
+			Str("profile", profile.Name).
+			Int("version", profile.Version).
+			Str("description", profile.Description).
+			Msg("Enabling profile")
+
+		for i, profileRule := range profile.Rules {
+			configRules = append(configRules, profileRule)
+			names = append(names, profile.RuleName(i))
+		}
+	}
+
+	compiled := make([]*rule, 0, len(configRules))
+
+	for i, configRule := range configRules {
+		compiledRule, err := compileRule(names[i], configRule, defaults)
+		if err != nil {
+			log.Warn().Str("rule", names[i]).Err(err).Msg("Failed to compile rule")
+
+			continue
+		}
+
+		log.Info().
+			Int("order", len(compiled)+1).
+			Str("rule", compiledRule.name).
+			Str("match", compiledRule.description).
+			Str("action", compiledRule.action.String()).
+			Msg("Adding rule")
+
+		compiled = append(compiled, compiledRule)
+	}
+
+	return compiled
+}
+
+//nolint:cyclop,funlen // one block per criterion
+func compileRule(
+	name string,
+	configRule config.Rule,
+	defaults coalesce.Settings,
+) (*rule, error) {
+	action, err := parseAction(configRule.Action)
+	if err != nil {
+		return nil, err
+	}
+
+	coalesceSettings, err := compileCoalesce(configRule, defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &rule{
+		name:   name,
+		action: action,
+		disk:   strings.TrimSpace(configRule.Disk),
+		share:  strings.TrimSpace(configRule.Share),
+		uid:    configRule.UID,
+
+		coalesceSettings: coalesceSettings,
+	}
+
+	criteria := []string{}
+
+	if pattern := strings.TrimSpace(configRule.Path); pattern != "" {
+		compiled.path, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern: %w", err)
+		}
+
+		criteria = append(criteria, "path=~"+strconv.Quote(pattern))
+	}
+
+	if pattern := strings.TrimSpace(configRule.Glob); pattern != "" {
+		compiled.glob, err = globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		criteria = append(criteria, "glob="+strconv.Quote(pattern))
+	}
+
+	if len(configRule.Ops) > 0 {
+		compiled.ops, err = types.ParseOps(configRule.Ops)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ops: %w", err)
+		}
+
+		criteria = append(criteria, "ops="+strings.Join(configRule.Ops, ","))
+	}
+
+	if pattern := strings.TrimSpace(configRule.Process); pattern != "" {
+		compiled.process, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process pattern: %w", err)
+		}
+
+		criteria = append(criteria, "process=~"+strconv.Quote(pattern))
+	}
+
+	if pattern := strings.TrimSpace(configRule.ProcessName); pattern != "" {
+		compiled.processName, err = compileAnchored(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid process name pattern: %w", err)
+		}
+
+		criteria = append(criteria, "process_name="+strconv.Quote(pattern))
+	}
+
+	if pattern := strings.TrimSpace(configRule.Source); pattern != "" {
+		compiled.source, err = compileAnchored(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source pattern: %w", err)
+		}
+
+		criteria = append(criteria, "source="+strconv.Quote(pattern))
+	}
+
+	if pattern := strings.TrimSpace(configRule.Container); pattern != "" {
+		compiled.container, err = compileAnchored(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid container pattern: %w", err)
+		}
+
+		criteria = append(criteria, "container="+strconv.Quote(pattern))
+	}
+
+	if pattern := strings.TrimSpace(configRule.Image); pattern != "" {
+		compiled.image, err = compileAnchored(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image pattern: %w", err)
+		}
+
+		criteria = append(criteria, "image="+strconv.Quote(pattern))
+	}
+
+	if label := strings.TrimSpace(configRule.Label); label != "" {
+		compiled.labelKey, compiled.labelValue, compiled.matchValue = strings.Cut(label, "=")
+		criteria = append(criteria, "label="+strconv.Quote(label))
+	}
+
+	if compiled.disk != "" {
+		criteria = append(criteria, "disk="+compiled.disk)
+	}
+
+	if compiled.share != "" {
+		criteria = append(criteria, "share="+compiled.share)
+	}
+
+	if compiled.uid != nil {
+		criteria = append(criteria, "uid="+strconv.Itoa(*compiled.uid))
+	}
+
+	if value := strings.TrimSpace(configRule.Time); value != "" {
+		compiled.timeRange, err = parseTimeRange(value)
+		if err != nil {
+			return nil, err
+		}
+
+		criteria = append(criteria, "time="+value)
+	}
+
+	if len(criteria) == 0 {
+		return nil, errors.New("rule has no criteria")
+	}
+
+	compiled.description = strings.Join(criteria, " ")
+
+	return compiled, nil
+}
+
+// compileAnchored compiles a pattern that has to match the whole value.
+func compileAnchored(pattern string) (*regexp.Regexp, error) {
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("compile %q: %w", pattern, err)
+	}
+
+	return compiled, nil
+}
+
+// parseTimeRange parses "HH:MM-HH:MM". Ranges may wrap around midnight.
+func parseTimeRange(value string) (*timeRange, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return nil, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", value)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(startValue))
+	if err != nil {
+		return nil, fmt.Errorf("invalid time range start %q: %w", startValue, err)
+	}
+
+	end, err := time.Parse("15:04", strings.TrimSpace(endValue))
+	if err != nil {
+		return nil, fmt.Errorf("invalid time range end %q: %w", endValue, err)
+	}
+
+	return &timeRange{
+		start: start.Hour()*60 + start.Minute(),
+		end:   end.Hour()*60 + end.Minute(),
+	}, nil
+}
+
+func (t *timeRange) contains(moment time.Time) bool {
+	minutes := moment.Hour()*60 + moment.Minute()
+
+	if t.start <= t.end {
+		return minutes >= t.start && minutes < t.end
+	}
+
+	return minutes >= t.start || minutes < t.end
+}
+
+// needsDetails reports whether the rule looks at the process or container,
+// which are only known after the event has been resolved.
+func (r *rule) needsDetails() bool {
+	return r.process != nil || r.processName != nil || r.source != nil || r.uid != nil ||
+		r.container != nil || r.image != nil || r.labelKey != ""
+}
+
+//nolint:cyclop // one check per criterion
+func (r *rule) matches(subject Subject) bool {
+	event := subject.Event
+
+	if r.path != nil && !r.path.MatchString(event.Path()) {
+		return false
+	}
+
+	if r.glob != nil && !r.glob.MatchString(event.Path()) {
+		return false
+	}
+
+	if r.ops != 0 && !event.Op.Has(r.ops) {
+		return false
+	}
+
+	if r.disk != "" && EventDisk(event) != r.disk {
+		return false
+	}
+
+	if r.share != "" && EventShare(event) != r.share {
+		return false
+	}
+
+	if r.timeRange != nil && !r.timeRange.contains(subject.Time) {
+		return false
+	}
+
+	if r.process != nil && !r.process.MatchString(subject.ProcessPath) {
+		return false
+	}
+
+	if r.processName != nil && !r.processName.MatchString(filepath.Base(subject.ProcessPath)) {
+		return false
+	}
+
+	if r.source != nil && !r.source.MatchString(subject.Source) {
+		return false
+	}
+
+	if r.uid != nil && subject.UID != *r.uid {
+		return false
+	}
+
+	return r.matchesContainer(subject.Container)
+}
+
+// matchesContainer checks the container criteria. Container criteria only match
+// events that came from a container; unresolved containers are named "unknown".
+func (r *rule) matchesContainer(container types.Container) bool {
+	if r.container == nil && r.image == nil && r.labelKey == "" {
+		return true
+	}
+
+	if container.ID == "" {
+		return false
+	}
+
+	name := container.Name
+	if name == "" {
+		name = UnknownContainer
+	}
+
+	if r.container != nil && !r.container.MatchString(name) {
+		return false
+	}
+
+	if r.image != nil && !r.image.MatchString(container.Image) {
+		return false
+	}
+
+	if r.labelKey != "" {
+		value, exists := container.Labels[r.labelKey]
+		if !exists || (r.matchValue && value != r.labelValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchRules evaluates the rules in order and returns the first match.
+// For a subject that has not been resolved yet, evaluation stops at the first
+// rule that needs process or container details and false is returned, meaning
+// the subject has to be resolved and evaluated again. This lets rules that only
+// look at the path drop events before any resolution work is done.
+func (f *Filter) MatchRules(subject Subject) (Match, bool) {
+	for i, rule := range f.rules {
+		if !subject.Resolved && rule.needsDetails() {
+			return Match{}, false
+		}
+
+		if rule.matches(subject) {
+			if rule.action == ActionDrop {
+				log.Debug().
+					Str("rule", rule.name).
+					Str("file", subject.Event.File).
+					Msg("Rule dropped event")
+			}
+
+			f.stats.recordRules(subject.Event.File, i, rule.action)
+
+			return Match{
+				Action:   rule.action,
+				Rule:     rule.name,
+				Coalesce: rule.coalesceSettings,
+			}, true
+		}
+	}
+
+	f.stats.recordRules(subject.Event.File, -1, ActionNone)
+
+	return Match{Action: ActionNone, Coalesce: f.coalesceDefaults}, true
+}
+
+// keptByRule reports whether the first rule that matches an unresolved event
+// keeps or flags it. Rule statistics are left to MatchRules.
+func (f *Filter) keptByRule(event types.Event, now time.Time) bool {
+	subject := Subject{Event: event, Time: now}
+
+	for _, rule := range f.rules {
+		if rule.needsDetails() {
+			return false
+		}
+
+		if rule.matches(subject) {
+			return rule.action == ActionKeep || rule.action == ActionFlag
+		}
+	}
+
+	return false
+}
+
+// EventDisk returns the disk an event happened on. Events inside loop-mounted
+// images belong to the disk that holds the image.
+func EventDisk(event types.Event) string {
+	return diskName(event.Path())
+}
+
+// EventShare returns the user share an event happened in.
+func EventShare(event types.Event) string {
+	return shareName(event.Path())
+}
+
+// diskName returns the disk a path lives on, e.g. "disk5" for /mnt/disk5/Media
+// or the device name for unassigned devices mounted under /mnt/disks.
+func diskName(path string) string {
+	rest, ok := strings.CutPrefix(path, "/mnt/")
+	if !ok {
+		return ""
+	}
+
+	disk, rest, _ := strings.Cut(rest, "/")
+	if disk == "disks" || disk == "remotes" {
+		disk, _, _ = strings.Cut(rest, "/")
+	}
+
+	return disk
+}
+
+// shareName returns the user share a path on an array disk or pool belongs to,
+// e.g. "Media" for /mnt/disk5/Media/Movies. Unassigned devices have no shares.
+func shareName(path string) string {
+	rest, ok := strings.CutPrefix(path, "/mnt/")
+	if !ok {
+		return ""
+	}
+
+	disk, rest, found := strings.Cut(rest, "/")
+	if !found || disk == "disks" || disk == "remotes" {
+		return ""
+	}
+
+	share, _, _ := strings.Cut(rest, "/")
+
+	return share
+}
@@ -0,0 +1,565 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/coalesce"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/profiles"
+)
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestMatchRules(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		DedupeWindow: 1,
+		Rules: []config.Rule{
+			{
+				Name:   "keep photos removals",
+				Share:  "Photos",
+				Ops:    []string{"remove"},
+				Action: "flag",
+			},
+			{Name: "tmp files", Glob: "**/*.tmp", Action: "drop"},
+			{Name: "mover", ProcessName: "mover|shfs", Action: "drop"},
+			{Name: "nightly backup", Container: "duplicati", Disk: "disk5", Action: "drop"},
+			{Name: "unknown containers", Container: UnknownContainer, Action: "keep"},
+			{Name: "nobody at night", UID: intPtr(99), Time: "22:00-06:00", Action: "drop"},
+			{
+				Name:    "plex reads",
+				Process: "Plex Media Server$",
+				Ops:     []string{"READ"},
+				Action:  "drop",
+			},
+			{Name: "arr stack", Label: "com.docker.compose.project=arr", Action: "drop"},
+			{Name: "appdata", Path: `^/mnt/[^/]+/appdata/`, Action: "drop"},
+		},
+	}
+
+	filter := New(appConfig)
+
+	night := time.Date(2026, 10, 18, 23, 30, 0, 0, time.Local)
+	day := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		action   Action
+		rule     string
+		resolved bool
+	}{
+		{
+			name: "flag removal from share",
+			subject: Subject{
+				Event: types.Event{File: "/mnt/disk2/Photos/2026/a.jpg", Op: types.OpRemove},
+				Time:  day,
+			},
+			action:   ActionFlag,
+			rule:     "keep photos removals",
+			resolved: true,
+		},
+		{
+			name: "glob drops before resolution",
+			subject: Subject{
+				Event: types.Event{File: "/mnt/disk1/Downloads/a/part.tmp", Op: types.OpWrite},
+				Time:  day,
+			},
+			action:   ActionDrop,
+			rule:     "tmp files",
+			resolved: true,
+		},
+		{
+			name: "process rule needs resolution",
+			subject: Subject{
+				Event: types.Event{File: "/mnt/disk1/Media/x.mkv", Op: types.OpWrite},
+				Time:  day,
+			},
+			action:   ActionNone,
+			resolved: false,
+		},
+		{
+			name: "process name",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/Media/x.mkv", Op: types.OpCreate},
+				Time:        day,
+				Resolved:    true,
+				ProcessPath: "/usr/local/sbin/mover",
+			},
+			action:   ActionDrop,
+			rule:     "mover",
+			resolved: true,
+		},
+		{
+			name: "container on disk",
+			subject: Subject{
+				Event:     types.Event{File: "/mnt/disk5/Backups/x.zip", Op: types.OpWrite},
+				Time:      day,
+				Resolved:  true,
+				Container: types.Container{ID: "1", Name: "duplicati"},
+			},
+			action:   ActionDrop,
+			rule:     "nightly backup",
+			resolved: true,
+		},
+		{
+			name: "unresolved container kept",
+			subject: Subject{
+				Event:     types.Event{File: "/mnt/disk1/appdata/x", Op: types.OpWrite},
+				Time:      day,
+				Resolved:  true,
+				Container: types.Container{ID: "2"},
+			},
+			action:   ActionKeep,
+			rule:     "unknown containers",
+			resolved: true,
+		},
+		{
+			name: "uid at night",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/disk1/x", Op: types.OpWrite},
+				Time:     night,
+				Resolved: true,
+				UID:      99,
+			},
+			action:   ActionDrop,
+			rule:     "nobody at night",
+			resolved: true,
+		},
+		{
+			name: "uid during the day",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/disk1/x", Op: types.OpWrite},
+				Time:     day,
+				Resolved: true,
+				UID:      99,
+			},
+			action:   ActionNone,
+			resolved: true,
+		},
+		{
+			name: "process with op set",
+			subject: Subject{
+				Event: types.Event{
+					File: "/mnt/disk1/Media/x.mkv",
+					Op:   types.OpOpen | types.OpRead,
+				},
+				Time:        day,
+				Resolved:    true,
+				ProcessPath: "/usr/lib/plexmediaserver/Plex Media Server",
+			},
+			action:   ActionDrop,
+			rule:     "plex reads",
+			resolved: true,
+		},
+		{
+			name: "label",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/disk1/TV/x.mkv", Op: types.OpWrite},
+				Time:     day,
+				Resolved: true,
+				Container: types.Container{
+					ID:     "3",
+					Name:   "sonarr",
+					Labels: map[string]string{"com.docker.compose.project": "arr"},
+				},
+			},
+			action:   ActionDrop,
+			rule:     "arr stack",
+			resolved: true,
+		},
+		{
+			name: "container criteria skip host processes",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/disk5/Backups/x.zip", Op: types.OpWrite},
+				Time:     day,
+				Resolved: true,
+			},
+			action:   ActionNone,
+			resolved: true,
+		},
+		{
+			name: "path regex",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/cache/appdata/plex/x.db", Op: types.OpWrite},
+				Time:     day,
+				Resolved: true,
+			},
+			action:   ActionDrop,
+			rule:     "appdata",
+			resolved: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, resolved := filter.MatchRules(tt.subject)
+			if resolved != tt.resolved {
+				t.Fatalf("MatchRules() decided = %v, expected %v", resolved, tt.resolved)
+			}
+
+			if match.Action != tt.action || match.Rule != tt.rule {
+				t.Errorf("MatchRules() = %+v, expected %v by %q", match, tt.action, tt.rule)
+			}
+		})
+	}
+}
+
+func TestMatchRules_NoRules(t *testing.T) {
+	filter := New(config.ActivityConfig{DedupeWindow: 1})
+
+	match, decided := filter.MatchRules(Subject{Event: types.Event{File: "/mnt/disk1/x"}})
+	if !decided || match.Action != ActionNone {
+		t.Errorf("Expected no match without rules, got %+v (decided %v)", match, decided)
+	}
+}
+
+func TestMatchRules_ContainerCriteria(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		DedupeWindow: 1,
+		Rules: []config.Rule{
+			{Name: "plex", Container: "plex", Action: "keep"},
+			{Container: "plex", Action: "drop"},
+			{Image: "lscr.io/linuxserver/.*", Action: "drop"},
+			{Container: "helper", Action: "keep"},
+		},
+	}
+
+	filter := New(appConfig)
+
+	subject := Subject{
+		Event:    types.Event{File: "/mnt/disk1/x", Op: types.OpWrite},
+		Resolved: true,
+		Container: types.Container{
+			ID:    "1",
+			Name:  "sonarr",
+			Image: "lscr.io/linuxserver/sonarr",
+		},
+	}
+
+	match, _ := filter.MatchRules(subject)
+	if match.Action != ActionDrop || match.Rule != "rules[2]" {
+		t.Errorf("Expected drop by rules[2], got %+v", match)
+	}
+
+	subject.Container = types.Container{ID: "2", Name: "plex"}
+
+	match, _ = filter.MatchRules(subject)
+	if match.Action != ActionKeep || match.Rule != "plex" {
+		t.Errorf("Expected keep by plex, got %+v", match)
+	}
+}
+
+func TestCompileRules_Invalid(t *testing.T) {
+	rules := compileRules(config.ActivityConfig{
+		Rules: []config.Rule{
+			{Path: "x", Action: "ignore"},    // unknown action
+			{Path: "(", Action: "drop"},      // invalid regex
+			{Glob: "[abc", Action: "drop"},   // invalid glob
+			{Container: "(", Action: "drop"}, // invalid container
+			{Ops: []string{"close"}, Action: "drop"},
+			{Path: "x", CoalesceKey: []string{"inode"}, Action: "keep"},
+			{Path: "x", CoalesceWindow: intPtr(-1), Action: "keep"},
+			{Time: "late", Action: "drop"},                  // invalid time
+			{Time: "25:00-06:00", Action: "drop"},           // invalid hour
+			{Action: "drop"},                                // no criteria
+			{Path: "x", Action: " Keep-And-Flag "},          // valid
+			{Ops: []string{"write"}, Action: "keep"},        // valid
+			{UID: intPtr(0), Action: "drop"},                // valid
+			{Label: "com.example", Action: "flag"},          // valid
+			{Share: "Media", Disk: "disk1", Action: "drop"}, // valid
+		},
+	}, coalesce.Settings{})
+
+	if len(rules) != 5 {
+		t.Errorf("Expected 5 valid rules, got %d", len(rules))
+	}
+}
+
+func TestCompileRule_Description(t *testing.T) {
+	compiled, err := compileRule("test", config.Rule{
+		Path:   `\.nfo$`,
+		Ops:    []string{"WRITE", "CREATE"},
+		Disk:   "disk1",
+		Action: "drop",
+	}, coalesce.Settings{})
+	if err != nil {
+		t.Fatalf("Expected rule to compile, got %v", err)
+	}
+
+	expected := `path=~"\\.nfo$" ops=WRITE,CREATE disk=disk1`
+	if compiled.description != expected {
+		t.Errorf("Expected description %q, got %q", expected, compiled.description)
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		hour     int
+		minute   int
+		expected bool
+	}{
+		{"inside daytime range", "08:00-17:00", 12, 0, true},
+		{"start is inclusive", "08:00-17:00", 8, 0, true},
+		{"end is exclusive", "08:00-17:00", 17, 0, false},
+		{"outside daytime range", "08:00-17:00", 20, 0, false},
+		{"wrapping before midnight", "22:00-06:00", 23, 15, true},
+		{"wrapping after midnight", "22:00-06:00", 2, 0, true},
+		{"outside wrapping range", "22:00-06:00", 12, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeRange, err := parseTimeRange(tt.value)
+			if err != nil {
+				t.Fatalf("parseTimeRange(%q) failed: %v", tt.value, err)
+			}
+
+			moment := time.Date(2026, 1, 1, tt.hour, tt.minute, 0, 0, time.Local)
+			if result := timeRange.contains(moment); result != tt.expected {
+				t.Errorf("%q contains %02d:%02d = %v, expected %v",
+					tt.value, tt.hour, tt.minute, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAction_String(t *testing.T) {
+	actions := map[Action]string{
+		ActionNone: "none",
+		ActionDrop: "drop",
+		ActionKeep: "keep",
+		ActionFlag: "flag",
+	}
+
+	for action, expected := range actions {
+		if action.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, action.String())
+		}
+	}
+}
+
+func TestEventDisk(t *testing.T) {
+	event := types.Event{File: "/var/lib/docker/btrfs/subvolumes/abc/x"}
+	if disk := EventDisk(event); disk != "" {
+		t.Errorf("Expected no disk for path outside /mnt, got %q", disk)
+	}
+
+	event.Image = "/mnt/disk1/system/docker/docker.img"
+	if disk := EventDisk(event); disk != "disk1" {
+		t.Errorf("Expected loop image events to belong to disk1, got %q", disk)
+	}
+
+	if share := EventShare(event); share != "system" {
+		t.Errorf("Expected loop image events to belong to the system share, got %q", share)
+	}
+}
+
+func TestDiskAndShareName(t *testing.T) {
+	tests := []struct {
+		path  string
+		disk  string
+		share string
+	}{
+		{"/mnt/disk5/Backups/x.zip", "disk5", "Backups"},
+		{"/mnt/cache/appdata/x", "cache", "appdata"},
+		{"/mnt/disks/usb_backup/x", "usb_backup", ""},
+		{"/mnt/remotes/nas_share/x", "nas_share", ""},
+		{"/mnt/disk1", "disk1", ""},
+		{"/var/log/syslog", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if disk := diskName(tt.path); disk != tt.disk {
+				t.Errorf("diskName(%q) = %q, expected %q", tt.path, disk, tt.disk)
+			}
+
+			if share := shareName(tt.path); share != tt.share {
+				t.Errorf("shareName(%q) = %q, expected %q", tt.path, share, tt.share)
+			}
+		})
+	}
+}
+
+func TestMatchRules_Coalesce(t *testing.T) {
+	appConfig := config.ActivityConfig{
+		DedupeWindow: 2,
+		CoalesceKey:  []string{"file", "op"},
+		Rules: []config.Rule{
+			{
+				Name:           "plex",
+				Glob:           "/mnt/*/Media/**",
+				CoalesceKey:    []string{"file"},
+				CoalesceWindow: intPtr(60),
+				Action:         "keep",
+			},
+			{Name: "documents", Share: "Documents", CoalesceWindow: intPtr(0), Action: "keep"},
+		},
+	}
+
+	filter := New(appConfig)
+
+	tests := []struct {
+		file     string
+		expected coalesce.Settings
+	}{
+		{
+			file:     "/mnt/disk1/Media/movie.mkv",
+			expected: coalesce.Settings{Key: coalesce.KeyFile, Window: time.Minute},
+		},
+		{
+			file:     "/mnt/disk1/Documents/a.txt",
+			expected: coalesce.Settings{Key: coalesce.KeyFile | coalesce.KeyOp, Window: 0},
+		},
+		{
+			file: "/mnt/disk1/Other/a.txt",
+			expected: coalesce.Settings{
+				Key:    coalesce.KeyFile | coalesce.KeyOp,
+				Window: 2 * time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			match, _ := filter.MatchRules(Subject{Event: types.Event{File: tt.file}})
+			if match.Coalesce != tt.expected {
+				t.Errorf("MatchRules() coalesce = %+v, expected %+v", match.Coalesce, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompileRules_Profiles(t *testing.T) {
+	settings := map[string]bool{}
+	total := 0
+
+	all, err := profiles.Load()
+	if err != nil {
+		t.Fatalf("Failed to load profiles: %v", err)
+	}
+
+	for _, profile := range all {
+		settings[profile.Name] = true
+		total += len(profile.Rules)
+	}
+
+	rules := compileRules(config.ActivityConfig{
+		Rules:    []config.Rule{{Name: "keep mover logs", Glob: "*.log", Action: "keep"}},
+		Profiles: settings,
+	}, coalesce.Settings{})
+
+	// Every profile rule must compile, and user rules come first
+	if len(rules) != total+1 {
+		t.Fatalf("Expected %d rules, got %d", total+1, len(rules))
+	}
+
+	if rules[0].name != "keep mover logs" {
+		t.Errorf("Expected user rules before profile rules, got %s first", rules[0].name)
+	}
+}
+
+func TestMatchRules_Profiles(t *testing.T) {
+	filter := New(config.ActivityConfig{
+		DedupeWindow: 1,
+		Profiles:     map[string]bool{"mover": true, "cache_dirs": true, "shfs": true},
+	})
+
+	tests := []struct {
+		name    string
+		subject Subject
+		rule    string
+	}{
+		{
+			// The mover script runs under bash and leaves the files to the move binary
+			name: "mover",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/Media/x.mkv", Op: types.OpCreate},
+				Resolved:    true,
+				ProcessPath: "/usr/libexec/unraid/move",
+				Source:      "cron",
+			},
+			rule: "mover@v1/mover",
+		},
+		{
+			name: "other scheduled script",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/Media/x.mkv", Op: types.OpCreate},
+				Resolved:    true,
+				ProcessPath: "/bin/bash",
+				Source:      "cron",
+			},
+			rule: "",
+		},
+		{
+			name: "mover tuning script",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/Media/x.mkv", Op: types.OpCreate},
+				Resolved:    true,
+				ProcessPath: "/bin/bash",
+				Source:      "plugin:ca.mover.tuning",
+			},
+			rule: "mover@v1/mover tuning",
+		},
+		{
+			name: "cache_dirs script",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/Media", Op: types.OpOpen},
+				Resolved:    true,
+				ProcessPath: "/bin/bash",
+				Source:      "plugin:dynamix.cache.dirs",
+			},
+			rule: "cache_dirs@v1/directory scans",
+		},
+		{
+			name: "fuse hidden file",
+			subject: Subject{
+				Event:    types.Event{File: "/mnt/disk1/a/.fuse_hidden0000001", Op: types.OpWrite},
+				Resolved: true,
+			},
+			rule: "shfs@v1/fuse hidden files",
+		},
+		{
+			name: "user write through shfs",
+			subject: Subject{
+				Event:       types.Event{File: "/mnt/disk1/a/b.txt", Op: types.OpWrite},
+				Resolved:    true,
+				ProcessPath: "/usr/local/bin/shfs",
+			},
+			rule: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _ := filter.MatchRules(tt.subject)
+			if match.Rule != tt.rule {
+				t.Errorf("MatchRules() rule = %q, expected %q", match.Rule, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/coalesce"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// RuleSet is a list of rules evaluated on records that were already kept, such as
+// the filter of an output. Unlike the filter rules, records no rule matches get
+// the default action.
+type RuleSet struct {
+	rules         []*rule
+	defaultAction Action
+}
+
+// NewRuleSet compiles rules with prefix, such as "sinks.<name>". Unnamed rules
+// are named after their position, as in "sinks.<name>.rules[1]". Rules that fail
+// to compile are skipped. The default action is either "keep",
+// the default, or "drop".
+func NewRuleSet(prefix string, configRules []config.Rule, defaultAction string) *RuleSet {
+	set := &RuleSet{defaultAction: ActionKeep}
+
+	if strings.TrimSpace(defaultAction) != "" {
+		action, err := parseAction(defaultAction)
+		if err != nil || action == ActionFlag {
+			log.Warn().
+				Str("rules", prefix).
+				Str("default_action", defaultAction).
+				Msg("Invalid default action, keeping records no rule matches")
+		} else {
+			set.defaultAction = action
+		}
+	}
+
+	for i, configRule := range configRules {
+		name := strings.TrimSpace(configRule.Name)
+		if name == "" {
+			name = prefix + ".rules[" + strconv.Itoa(i) + "]"
+		}
+
+		compiledRule, err := compileRule(name, configRule, coalesce.Settings{})
+		if err != nil {
+			log.Warn().Str("rule", name).Err(err).Msg("Failed to compile rule")
+
+			continue
+		}
+
+		set.rules = append(set.rules, compiledRule)
+	}
+
+	return set
+}
+
+// Match returns the first rule that matches a record, or the default action.
+func (s *RuleSet) Match(record types.Record) Match {
+	if s == nil {
+		return Match{Action: ActionKeep}
+	}
+
+	subject := RecordSubject(record)
+
+	for _, rule := range s.rules {
+		if rule.matches(subject) {
+			return Match{Action: rule.action, Rule: rule.name}
+		}
+	}
+
+	return Match{Action: s.defaultAction}
+}
+
+// RecordSubject returns the subject a record was made from, as far as the record
+// tells. Records only name their container, so image and label criteria never
+// match them, and their UID is unknown.
+func RecordSubject(record types.Record) Subject {
+	subject := Subject{
+		Event: types.Event{
+			File: record.File,
+			PID:  record.PID,
+			Op:   record.Op,
+		},
+		Time:        record.FirstSeen,
+		Resolved:    true,
+		ProcessPath: record.ProcessPath,
+		UID:         -1,
+		Source:      record.Source,
+	}
+
+	if record.Container != "" {
+		subject.Container = types.Container{ID: record.Container, Name: record.Container}
+	}
+
+	return subject
+}
@@ -0,0 +1,113 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func TestRuleSet(t *testing.T) {
+	rules := []config.Rule{
+		{Name: "photos removals", Share: "Photos", Ops: []string{"remove"}, Action: "keep"},
+		{Name: "plex", Container: "plex", Action: "keep"},
+		{Name: "tmp files", Glob: "**/*.tmp", Action: "drop"},
+		{Name: "invalid", Path: "(", Action: "keep"},
+	}
+
+	record := func(file string, op types.Op, container string) types.Record {
+		return types.Record{
+			Op:        op,
+			File:      file,
+			Container: container,
+			FirstSeen: time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local),
+		}
+	}
+
+	tests := []struct {
+		name          string
+		defaultAction string
+		record        types.Record
+		action        Action
+		rule          string
+	}{
+		{
+			name:   "share and op",
+			record: record("/mnt/disk1/Photos/a.jpg", types.OpRemove, ""),
+			action: ActionKeep,
+			rule:   "photos removals",
+		},
+		{
+			name:   "container by name",
+			record: record("/mnt/disk1/Media/a.mkv", types.OpRead, "plex"),
+			action: ActionKeep,
+			rule:   "plex",
+		},
+		{
+			name:   "drop",
+			record: record("/mnt/disk1/Media/a.tmp", types.OpWrite, ""),
+			action: ActionDrop,
+			rule:   "tmp files",
+		},
+		{
+			name:   "default keep",
+			record: record("/mnt/disk1/Media/a.mkv", types.OpRead, ""),
+			action: ActionKeep,
+		},
+		{
+			name:          "default drop",
+			defaultAction: "drop",
+			record:        record("/mnt/disk1/Media/a.mkv", types.OpRead, ""),
+			action:        ActionDrop,
+		},
+		{
+			name:          "invalid default",
+			defaultAction: "flag",
+			record:        record("/mnt/disk1/Media/a.mkv", types.OpRead, ""),
+			action:        ActionKeep,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewRuleSet("sinks.test", rules, tt.defaultAction)
+
+			if len(set.rules) != 3 {
+				t.Fatalf("Expected the invalid rule to be skipped, got %d rules", len(set.rules))
+			}
+
+			match := set.Match(tt.record)
+			if match.Action != tt.action || match.Rule != tt.rule {
+				t.Errorf("Match() = %v %q, expected %v %q",
+					match.Action, match.Rule, tt.action, tt.rule)
+			}
+		})
+	}
+}
+
+func TestRuleSet_Nil(t *testing.T) {
+	var set *RuleSet
+
+	if match := set.Match(types.Record{File: "/mnt/disk1/a"}); match.Action != ActionKeep {
+		t.Errorf("Expected a nil rule set to keep records, got %v", match.Action)
+	}
+}
@@ -0,0 +1,235 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// Counter holds the statistics of one filter stage, such as an exclusion or a rule.
+// Seen counts the events the stage looked at, Matched the events it matched and
+// Dropped the matched events that were not written.
+type Counter struct {
+	Name      string    `json:"name"`
+	Seen      uint64    `json:"seen"`
+	Matched   uint64    `json:"matched"`
+	Dropped   uint64    `json:"dropped"`
+	LastPath  string    `json:"last_path,omitempty"`
+	LastMatch time.Time `json:"last_match,omitzero"`
+}
+
+func (c *Counter) record(path string, matched, dropped bool, now time.Time) {
+	c.Seen++
+
+	if !matched {
+		return
+	}
+
+	c.Matched++
+	c.LastPath = path
+	c.LastMatch = now
+
+	if dropped {
+		c.Dropped++
+	}
+}
+
+func (c *Counter) reset() {
+	*c = Counter{Name: c.Name}
+}
+
+// Stats are the filter statistics since Since. They are reset at local midnight,
+// so that they always describe the current day.
+type Stats struct {
+	Since      time.Time `json:"since"`
+	Exclusions []Counter `json:"exclusions"`
+	Rules      []Counter `json:"rules"`
+	Dedupe     Counter   `json:"dedupe"`
+	RateLimit  Counter   `json:"rate_limit"`
+}
+
+type statsRecorder struct {
+	mu      sync.Mutex
+	current Stats
+}
+
+func newStatsRecorder(exclusions, rules []string, now time.Time) *statsRecorder {
+	current := Stats{
+		Since:      now,
+		Exclusions: make([]Counter, len(exclusions)),
+		Rules:      make([]Counter, len(rules)),
+		Dedupe:     Counter{Name: "dedupe"},
+		RateLimit:  Counter{Name: "rate_limit"},
+	}
+
+	for i, name := range exclusions {
+		current.Exclusions[i].Name = name
+	}
+
+	for i, name := range rules {
+		current.Rules[i].Name = name
+	}
+
+	return &statsRecorder{current: current}
+}
+
+// lock locks the recorder, resetting the statistics first if the day has changed.
+func (s *statsRecorder) lock(now time.Time) {
+	s.mu.Lock()
+
+	year, month, day := s.current.Since.Date()
+	if now.Year() == year && now.Month() == month && now.Day() == day {
+		return
+	}
+
+	s.current.Since = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	for i := range s.current.Exclusions {
+		s.current.Exclusions[i].reset()
+	}
+
+	for i := range s.current.Rules {
+		s.current.Rules[i].reset()
+	}
+
+	s.current.Dedupe.reset()
+	s.current.RateLimit.reset()
+}
+
+// recordOrdered records an evaluation that stopped at matched, or that ran
+// through all counters if matched is -1.
+func (s *statsRecorder) recordOrdered(
+	counters []Counter,
+	path string,
+	matched int,
+	dropped bool,
+	now time.Time,
+) {
+	for i := range counters {
+		if matched >= 0 && i > matched {
+			break
+		}
+
+		counters[i].record(path, i == matched, dropped, now)
+	}
+}
+
+func (s *statsRecorder) recordExclusions(path string, matched int) {
+	now := time.Now()
+
+	s.lock(now)
+	defer s.mu.Unlock()
+
+	s.recordOrdered(s.current.Exclusions, path, matched, true, now)
+}
+
+func (s *statsRecorder) recordRules(path string, matched int, action Action) {
+	now := time.Now()
+
+	s.lock(now)
+	defer s.mu.Unlock()
+
+	s.recordOrdered(s.current.Rules, path, matched, action == ActionDrop, now)
+}
+
+func (s *statsRecorder) recordDedupe(path string, duplicate bool) {
+	now := time.Now()
+
+	s.lock(now)
+	defer s.mu.Unlock()
+
+	s.current.Dedupe.record(path, duplicate, duplicate, now)
+}
+
+func (s *statsRecorder) recordRateLimit(path string, suppressed bool) {
+	now := time.Now()
+
+	s.lock(now)
+	defer s.mu.Unlock()
+
+	s.current.RateLimit.record(path, suppressed, suppressed, now)
+}
+
+func (s *statsRecorder) snapshot() Stats {
+	s.lock(time.Now())
+	defer s.mu.Unlock()
+
+	snapshot := s.current
+	snapshot.Exclusions = slices.Clone(s.current.Exclusions)
+	snapshot.Rules = slices.Clone(s.current.Rules)
+
+	return snapshot
+}
+
+// Stats returns the filter statistics for the current day.
+func (f *Filter) Stats() Stats {
+	return f.stats.snapshot()
+}
+
+// RecordRateLimit counts an event checked against the rate limits.
+func (f *Filter) RecordRateLimit(event types.Event, suppressed bool) {
+	f.stats.recordRateLimit(event.File, suppressed)
+}
+
+// WriteFile writes the statistics as JSON. The file is replaced atomically,
+// so readers never see a partial file.
+func (s Stats) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding statistics: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating statistics file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if err != nil {
+		temp.Close()
+
+		return fmt.Errorf("error writing statistics file: %w", err)
+	}
+
+	err = temp.Close()
+	if err != nil {
+		return fmt.Errorf("error closing statistics file: %w", err)
+	}
+
+	err = os.Chmod(temp.Name(), 0o644)
+	if err != nil {
+		return fmt.Errorf("error setting statistics file mode: %w", err)
+	}
+
+	err = os.Rename(temp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("error replacing statistics file: %w", err)
+	}
+
+	return nil
+}
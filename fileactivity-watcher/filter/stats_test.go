@@ -0,0 +1,201 @@
+package filter
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func TestStats_Exclusions(t *testing.T) {
+	filter := New(config.ActivityConfig{
+		Exclusions:   []string{`(?i)appdata`, `(?i)system`},
+		DedupeWindow: 1,
+	})
+
+	paths := []string{
+		"/mnt/cache/appdata/plex/x.db",
+		"/mnt/cache/system/docker.img",
+		"/mnt/cache/system/libvirt.img",
+		"/mnt/disk1/Media/x.mkv",
+	}
+
+	for _, path := range paths {
+		filter.IsPathExcluded(types.Event{File: path})
+	}
+
+	stats := filter.Stats()
+
+	appdata := stats.Exclusions[0]
+	if appdata.Name != "(?i)appdata" || appdata.Seen != 4 || appdata.Dropped != 1 {
+		t.Errorf("Unexpected appdata counter %+v", appdata)
+	}
+
+	// Only the events the first exclusion did not match reach the second
+	system := stats.Exclusions[1]
+	if system.Seen != 3 || system.Matched != 2 || system.Dropped != 2 {
+		t.Errorf("Unexpected system counter %+v", system)
+	}
+
+	if system.LastPath != "/mnt/cache/system/libvirt.img" {
+		t.Errorf("Expected last path libvirt.img, got %s", system.LastPath)
+	}
+
+	if system.LastMatch.IsZero() {
+		t.Error("Expected the last match time to be set")
+	}
+}
+
+func TestStats_Rules(t *testing.T) {
+	filter := New(config.ActivityConfig{
+		DedupeWindow: 1,
+		Rules: []config.Rule{
+			{Name: "tmp", Glob: "*.tmp", Action: "drop"},
+			{Name: "mover", ProcessName: "mover", Action: "drop"},
+			{Name: "photos", Share: "Photos", Action: "flag"},
+		},
+	})
+
+	tmp := Subject{Event: types.Event{File: "/mnt/disk1/a.tmp"}}
+	filter.MatchRules(tmp)
+
+	// Undecided evaluations are not counted, only the evaluation after resolution
+	photo := Subject{Event: types.Event{File: "/mnt/disk1/Photos/a.jpg"}}
+	filter.MatchRules(photo)
+
+	photo.Resolved = true
+	filter.MatchRules(photo)
+
+	other := Subject{Event: types.Event{File: "/mnt/disk1/Other/a.txt"}, Resolved: true}
+	filter.MatchRules(other)
+
+	stats := filter.Stats()
+
+	expected := []Counter{
+		{Name: "tmp", Seen: 3, Matched: 1, Dropped: 1},
+		{Name: "mover", Seen: 2},
+		{Name: "photos", Seen: 2, Matched: 1},
+	}
+
+	for i, counter := range stats.Rules {
+		if counter.Name != expected[i].Name || counter.Seen != expected[i].Seen ||
+			counter.Matched != expected[i].Matched || counter.Dropped != expected[i].Dropped {
+			t.Errorf("Rule %d counter = %+v, expected %+v", i, counter, expected[i])
+		}
+	}
+
+	if stats.Rules[2].LastPath != "/mnt/disk1/Photos/a.jpg" {
+		t.Errorf("Expected photos last path, got %q", stats.Rules[2].LastPath)
+	}
+}
+
+func TestStats_DedupeAndRateLimit(t *testing.T) {
+	filter := New(config.ActivityConfig{DedupeWindow: 60})
+
+	event := types.Event{File: "/mnt/disk1/a.txt", PID: 1, Op: types.OpWrite}
+	filter.IsDuplicate(event)
+	filter.IsDuplicate(event)
+	filter.IsDuplicate(event)
+
+	filter.RecordRateLimit(event, false)
+	filter.RecordRateLimit(event, true)
+
+	stats := filter.Stats()
+
+	if stats.Dedupe.Seen != 3 || stats.Dedupe.Dropped != 2 {
+		t.Errorf("Unexpected dedupe counter %+v", stats.Dedupe)
+	}
+
+	if stats.RateLimit.Seen != 2 || stats.RateLimit.Dropped != 1 {
+		t.Errorf("Unexpected rate limit counter %+v", stats.RateLimit)
+	}
+}
+
+func TestStats_ResetAtMidnight(t *testing.T) {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	recorder := newStatsRecorder([]string{"exclusion"}, []string{"rule"}, yesterday)
+
+	recorder.current.Exclusions[0].Dropped = 10
+	recorder.current.Rules[0].Seen = 5
+	recorder.current.Dedupe.Dropped = 3
+
+	stats := recorder.snapshot()
+
+	if stats.Exclusions[0].Dropped != 0 || stats.Rules[0].Seen != 0 || stats.Dedupe.Dropped != 0 {
+		t.Errorf("Expected counters to be reset for a new day, got %+v", stats)
+	}
+
+	if stats.Exclusions[0].Name != "exclusion" || stats.Rules[0].Name != "rule" {
+		t.Error("Expected counter names to survive the reset")
+	}
+
+	if stats.Since.Hour() != 0 || stats.Since.Day() != time.Now().Day() {
+		t.Errorf("Expected since to be midnight today, got %v", stats.Since)
+	}
+}
+
+func TestStats_SnapshotIsCopy(t *testing.T) {
+	recorder := newStatsRecorder([]string{"exclusion"}, nil, time.Now())
+
+	stats := recorder.snapshot()
+	stats.Exclusions[0].Seen = 100
+
+	if recorder.current.Exclusions[0].Seen != 0 {
+		t.Error("Expected the snapshot not to share counters with the recorder")
+	}
+}
+
+func TestStats_WriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	filter := New(config.ActivityConfig{Exclusions: []string{`(?i)system`}, DedupeWindow: 1})
+	filter.IsPathExcluded(types.Event{File: "/mnt/cache/system/docker.img"})
+
+	err := filter.Stats().WriteFile(path)
+	if err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read status file: %v", err)
+	}
+
+	var stats Stats
+
+	err = json.Unmarshal(data, &stats)
+	if err != nil {
+		t.Fatalf("Failed to parse status file: %v", err)
+	}
+
+	if len(stats.Exclusions) != 1 || stats.Exclusions[0].Dropped != 1 {
+		t.Errorf("Unexpected exclusions in status file: %+v", stats.Exclusions)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the status file to remain, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,367 @@
+// Package mqtt is a minimal MQTT 3.1.1 client, enough to publish messages.
+package mqtt
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// ErrClosed is returned for operations on a closed or broken connection.
+var ErrClosed = errors.New("MQTT connection closed")
+
+// connackErrors are the reasons a broker refuses a connection.
+var connackErrors = map[byte]string{ //nolint:gochecknoglobals
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Options configure a connection. Address is host:port; with TLS set the
+// connection is encrypted. KeepAlive defaults to one minute and Timeout, which
+// bounds connecting and waiting for acknowledgements, to ten seconds.
+type Options struct {
+	Address      string
+	TLS          *tls.Config
+	ClientID     string
+	Username     string
+	Password     string
+	KeepAlive    time.Duration
+	Timeout      time.Duration
+	CleanSession bool
+	Will         *Message
+}
+
+// Client is a minimal MQTT 3.1.1 client that only publishes. It is safe for
+// concurrent use.
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan struct{}
+	err     error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	group     sync.WaitGroup
+}
+
+// Dial connects to a broker.
+func Dial(options Options) (*Client, error) {
+	if options.KeepAlive <= 0 {
+		options.KeepAlive = time.Minute
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: options.Timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if options.TLS != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", options.Address, options.TLS)
+	} else {
+		conn, err = dialer.Dial("tcp", options.Address)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to MQTT broker: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	err = handshake(conn, reader, options)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, err
+	}
+
+	client := &Client{
+		conn:    conn,
+		timeout: options.Timeout,
+		pending: map[uint16]chan struct{}{},
+		done:    make(chan struct{}),
+	}
+
+	client.group.Add(2)
+
+	go client.read(reader, options.KeepAlive)
+	go client.ping(options.KeepAlive)
+
+	return client, nil
+}
+
+// handshake sends CONNECT and waits for the broker to accept it.
+func handshake(conn net.Conn, reader *bufio.Reader, options Options) error {
+	err := conn.SetDeadline(time.Now().Add(options.Timeout))
+	if err != nil {
+		return fmt.Errorf("error connecting to MQTT broker: %w", err)
+	}
+
+	err = WritePacket(conn, EncodeConnect(Connect{
+		ClientID:     options.ClientID,
+		Username:     options.Username,
+		Password:     options.Password,
+		KeepAlive:    uint16(options.KeepAlive / time.Second),
+		CleanSession: options.CleanSession,
+		Will:         options.Will,
+	}))
+	if err != nil {
+		return err
+	}
+
+	packet, err := ReadPacket(reader)
+	if err != nil {
+		return err
+	}
+
+	if packet.Type != TypeConnack || len(packet.Body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", packet.Type)
+	}
+
+	if code := packet.Body[1]; code != 0 {
+		reason, ok := connackErrors[code]
+		if !ok {
+			reason = fmt.Sprintf("return code %d", code)
+		}
+
+		return fmt.Errorf("MQTT broker refused the connection: %s", reason)
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return fmt.Errorf("error connecting to MQTT broker: %w", err)
+	}
+
+	return nil
+}
+
+// Done is closed when the connection is closed or lost.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, or nil while it is open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Publish sends a message. With QoS 1 or 2 it waits until the broker has
+// acknowledged it.
+func (c *Client) Publish(message Message) error {
+	if message.QoS > 2 {
+		return fmt.Errorf("invalid QoS %d", message.QoS)
+	}
+
+	if message.QoS == 0 {
+		return c.write(EncodePublish(message, 0))
+	}
+
+	c.mu.Lock()
+
+	if c.err != nil {
+		c.mu.Unlock()
+
+		return c.err
+	}
+
+	// Packet IDs must not be zero
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+
+	packetID := c.nextID
+	acked := make(chan struct{})
+	c.pending[packetID] = acked
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, packetID)
+		c.mu.Unlock()
+	}()
+
+	err := c.write(EncodePublish(message, packetID))
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-acked:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-timer.C:
+		return fmt.Errorf("no acknowledgement for %s within %s", message.Topic, c.timeout)
+	}
+}
+
+func (c *Client) write(packet Packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+
+	err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if err == nil {
+		err = WritePacket(c.conn, packet)
+	}
+
+	if err != nil {
+		c.fail(err)
+
+		return fmt.Errorf("error publishing to MQTT broker: %w", err)
+	}
+
+	return nil
+}
+
+// read handles the packets from the broker. The broker answers every ping, so
+// a connection that stays silent for longer than the keep-alive is gone.
+func (c *Client) read(reader *bufio.Reader, keepAlive time.Duration) {
+	defer c.group.Done()
+
+	for {
+		err := c.conn.SetReadDeadline(time.Now().Add(keepAlive + c.timeout))
+		if err != nil {
+			c.fail(err)
+
+			return
+		}
+
+		packet, err := ReadPacket(reader)
+		if err != nil {
+			c.fail(err)
+
+			return
+		}
+
+		switch packet.Type {
+		case TypePuback, TypePubcomp:
+			c.acknowledge(packet)
+		case TypePubrec:
+			packetID, err := AckID(packet)
+			if err == nil {
+				err = c.write(EncodeAck(TypePubrel, packetID))
+			}
+
+			if err != nil {
+				c.fail(err)
+
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) acknowledge(packet Packet) {
+	packetID, err := AckID(packet)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	acked, ok := c.pending[packetID]
+	if ok {
+		close(acked)
+		delete(c.pending, packetID)
+	}
+}
+
+func (c *Client) ping(keepAlive time.Duration) {
+	defer c.group.Done()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			err := c.write(Packet{Type: TypePingreq})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fail closes the connection, recording the first error.
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = fmt.Errorf("%w: %w", ErrClosed, err)
+		c.mu.Unlock()
+
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// Close disconnects cleanly, so the broker does not publish the will.
+func (c *Client) Close() error {
+	err := c.write(Packet{Type: TypeDisconnect})
+
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.err = ErrClosed
+		c.mu.Unlock()
+
+		close(c.done)
+		_ = c.conn.Close()
+	})
+
+	c.group.Wait()
+
+	return err
+}
@@ -0,0 +1,230 @@
+package mqtt_test
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt/mqtttest"
+)
+
+func newBroker(t *testing.T, tlsConfig *tls.Config) *mqtttest.Broker {
+	t.Helper()
+
+	broker, err := mqtttest.NewBroker(tlsConfig)
+	if err != nil {
+		t.Skipf("Cannot listen: %v", err)
+	}
+
+	t.Cleanup(broker.Close)
+
+	return broker
+}
+
+func receive(t *testing.T, broker *mqtttest.Broker) mqtt.Message {
+	t.Helper()
+
+	select {
+	case message := <-broker.Messages():
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+
+	return mqtt.Message{}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	connect := mqtt.Connect{
+		ClientID:     "tower",
+		Username:     "user",
+		Password:     "secret",
+		KeepAlive:    30,
+		CleanSession: true,
+		Will: &mqtt.Message{
+			Topic:   "status",
+			Payload: []byte("offline"),
+			QoS:     1,
+			Retain:  true,
+		},
+	}
+
+	// A payload long enough for a multi-byte remaining length
+	message := mqtt.Message{Topic: "a/b", Payload: bytes.Repeat([]byte("x"), 20000), QoS: 2}
+
+	var buffer bytes.Buffer
+
+	for _, packet := range []mqtt.Packet{
+		mqtt.EncodeConnect(connect),
+		mqtt.EncodePublish(message, 7),
+	} {
+		err := mqtt.WritePacket(&buffer, packet)
+		if err != nil {
+			t.Fatalf("WritePacket() error: %v", err)
+		}
+	}
+
+	reader := bufio.NewReader(&buffer)
+
+	packet, err := mqtt.ReadPacket(reader)
+	if err != nil {
+		t.Fatalf("ReadPacket() error: %v", err)
+	}
+
+	parsed, err := mqtt.ParseConnect(packet)
+	if err != nil {
+		t.Fatalf("ParseConnect() error: %v", err)
+	}
+
+	if parsed.ClientID != "tower" || parsed.Username != "user" || parsed.Password != "secret" ||
+		parsed.KeepAlive != 30 || !parsed.CleanSession || parsed.Will == nil ||
+		parsed.Will.Topic != "status" || string(parsed.Will.Payload) != "offline" ||
+		parsed.Will.QoS != 1 || !parsed.Will.Retain {
+		t.Errorf("ParseConnect() = %+v, will %+v", parsed, parsed.Will)
+	}
+
+	packet, err = mqtt.ReadPacket(reader)
+	if err != nil {
+		t.Fatalf("ReadPacket() error: %v", err)
+	}
+
+	published, packetID, err := mqtt.ParsePublish(packet)
+	if err != nil {
+		t.Fatalf("ParsePublish() error: %v", err)
+	}
+
+	if published.Topic != "a/b" || published.QoS != 2 || packetID != 7 ||
+		!bytes.Equal(published.Payload, message.Payload) {
+		t.Errorf("ParsePublish() = %s QoS %d ID %d with %d bytes",
+			published.Topic, published.QoS, packetID, len(published.Payload))
+	}
+}
+
+func TestClient_Publish(t *testing.T) {
+	broker := newBroker(t, nil)
+
+	client, err := mqtt.Dial(mqtt.Options{
+		Address:  broker.Addr(),
+		ClientID: "tower",
+		Username: "user",
+		Password: "secret",
+		Will:     &mqtt.Message{Topic: "status", Payload: []byte("offline"), Retain: true},
+	})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+
+	for qos := range byte(3) {
+		err = client.Publish(mqtt.Message{Topic: "events", Payload: []byte{'0' + qos}, QoS: qos})
+		if err != nil {
+			t.Fatalf("Publish() with QoS %d error: %v", qos, err)
+		}
+
+		message := receive(t, broker)
+		if message.Topic != "events" || message.QoS != qos || message.Payload[0] != '0'+qos {
+			t.Errorf("Unexpected message %+v", message)
+		}
+	}
+
+	connects := broker.Connects()
+	if len(connects) != 1 || connects[0].Username != "user" || connects[0].Will == nil {
+		t.Errorf("Unexpected connects %+v", connects)
+	}
+
+	err = client.Close()
+	if err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+
+	err = client.Publish(mqtt.Message{Topic: "events"})
+	if !errors.Is(err, mqtt.ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close(), got %v", err)
+	}
+}
+
+func TestClient_TLS(t *testing.T) {
+	// Borrow the test certificate of httptest
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	broker := newBroker(t, server.TLS)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	client, err := mqtt.Dial(mqtt.Options{
+		Address: broker.Addr(),
+		TLS:     &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+	})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Publish(mqtt.Message{Topic: "events", Payload: []byte("tls"), QoS: 1})
+	if err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	if message := receive(t, broker); string(message.Payload) != "tls" {
+		t.Errorf("Unexpected message %+v", message)
+	}
+}
+
+func TestClient_Refused(t *testing.T) {
+	broker := newBroker(t, nil)
+	broker.Refuse(4)
+
+	_, err := mqtt.Dial(mqtt.Options{Address: broker.Addr(), Timeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "bad user name or password") {
+		t.Errorf("Expected the connection to be refused, got %v", err)
+	}
+}
+
+func TestClient_ConnectionLost(t *testing.T) {
+	broker := newBroker(t, nil)
+
+	client, err := mqtt.Dial(mqtt.Options{Address: broker.Addr()})
+	if err != nil {
+		t.Fatalf("Dial() error: %v", err)
+	}
+	defer client.Close()
+
+	broker.Disconnect()
+
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lost connection not noticed")
+	}
+
+	err = client.Publish(mqtt.Message{Topic: "events", QoS: 1})
+	if !errors.Is(err, mqtt.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
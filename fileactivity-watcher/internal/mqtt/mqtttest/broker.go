@@ -0,0 +1,212 @@
+// Package mqtttest provides an in-process MQTT broker stand-in for tests. It
+// accepts every client, acknowledges what they publish and records it, but
+// routes nothing.
+package mqtttest
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt"
+)
+
+// Broker is the broker stand-in.
+type Broker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	connects []mqtt.Connect
+	conns    map[net.Conn]struct{}
+	refuse   byte
+	messages chan mqtt.Message
+	group    sync.WaitGroup
+}
+
+// NewBroker starts a broker on a local port. With a TLS config, clients must
+// connect over TLS.
+func NewBroker(tlsConfig *tls.Config) (*Broker, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	broker := &Broker{
+		listener: listener,
+		conns:    map[net.Conn]struct{}{},
+		messages: make(chan mqtt.Message, 1000),
+	}
+
+	broker.group.Add(1)
+
+	go broker.accept()
+
+	return broker, nil
+}
+
+// Addr returns the address clients connect to.
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Messages returns the published messages, in order.
+func (b *Broker) Messages() <-chan mqtt.Message {
+	return b.messages
+}
+
+// Connects returns the CONNECT packets received so far.
+func (b *Broker) Connects() []mqtt.Connect {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]mqtt.Connect{}, b.connects...)
+}
+
+// Refuse makes the broker refuse new connections with a CONNACK return code,
+// or accept them again with 0.
+func (b *Broker) Refuse(code byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refuse = code
+}
+
+// Disconnect drops every client connection, as a broker restart would.
+func (b *Broker) Disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.conns {
+		_ = conn.Close()
+	}
+}
+
+// Close stops the broker.
+func (b *Broker) Close() {
+	_ = b.listener.Close()
+	b.Disconnect()
+	b.group.Wait()
+}
+
+func (b *Broker) accept() {
+	defer b.group.Done()
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		b.mu.Lock()
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+
+		b.group.Add(1)
+
+		go b.serve(conn)
+	}
+}
+
+func (b *Broker) serve(conn net.Conn) {
+	defer b.group.Done()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, conn)
+		b.mu.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	packet, err := mqtt.ReadPacket(reader)
+	if err != nil || packet.Type != mqtt.TypeConnect {
+		return
+	}
+
+	connect, err := mqtt.ParseConnect(packet)
+	if err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.connects = append(b.connects, connect)
+	refuse := b.refuse
+	b.mu.Unlock()
+
+	err = mqtt.WritePacket(conn, mqtt.Packet{Type: mqtt.TypeConnack, Body: []byte{0, refuse}})
+	if err != nil || refuse != 0 {
+		return
+	}
+
+	for {
+		packet, err := mqtt.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+
+		var reply *mqtt.Packet
+
+		switch packet.Type {
+		case mqtt.TypePublish:
+			message, packetID, err := mqtt.ParsePublish(packet)
+			if err != nil {
+				return
+			}
+
+			b.messages <- message
+
+			switch message.QoS {
+			case 1:
+				ack := mqtt.EncodeAck(mqtt.TypePuback, packetID)
+				reply = &ack
+			case 2:
+				ack := mqtt.EncodeAck(mqtt.TypePubrec, packetID)
+				reply = &ack
+			}
+		case mqtt.TypePubrel:
+			packetID, err := mqtt.AckID(packet)
+			if err != nil {
+				return
+			}
+
+			ack := mqtt.EncodeAck(mqtt.TypePubcomp, packetID)
+			reply = &ack
+		case mqtt.TypePingreq:
+			reply = &mqtt.Packet{Type: mqtt.TypePingresp}
+		case mqtt.TypeDisconnect:
+			return
+		}
+
+		if reply != nil {
+			err = mqtt.WritePacket(conn, *reply)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
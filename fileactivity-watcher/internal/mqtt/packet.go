@@ -0,0 +1,345 @@
+package mqtt
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Packet types of MQTT 3.1.1.
+const (
+	TypeConnect    byte = 1
+	TypeConnack    byte = 2
+	TypePublish    byte = 3
+	TypePuback     byte = 4
+	TypePubrec     byte = 5
+	TypePubrel     byte = 6
+	TypePubcomp    byte = 7
+	TypePingreq    byte = 12
+	TypePingresp   byte = 13
+	TypeDisconnect byte = 14
+)
+
+const (
+	protocolName  = "MQTT"
+	protocolLevel = 4
+
+	// maxRemainingLength is the largest packet body MQTT can encode.
+	maxRemainingLength = 268435455
+
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+	willQoSShift     = 3
+
+	publishRetain   = 0x01
+	publishQoSShift = 1
+	qosMask         = 0x03
+)
+
+var errMalformed = errors.New("malformed MQTT packet")
+
+// Packet is an MQTT control packet: the type and flags of the fixed header and
+// everything after the remaining length.
+type Packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// Message is an application message.
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Connect is the content of a CONNECT packet.
+type Connect struct {
+	ClientID     string
+	Username     string
+	Password     string
+	KeepAlive    uint16
+	CleanSession bool
+	Will         *Message
+}
+
+// ReadPacket reads the next packet.
+func ReadPacket(reader *bufio.Reader) (Packet, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return Packet{}, fmt.Errorf("error reading packet: %w", err)
+	}
+
+	length := 0
+
+	for shift := 0; ; shift += 7 {
+		if shift > 21 {
+			return Packet{}, errMalformed
+		}
+
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return Packet{}, fmt.Errorf("error reading packet: %w", err)
+		}
+
+		length |= int(digit&0x7f) << shift
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	packet := Packet{Type: header >> 4, Flags: header & 0x0f, Body: make([]byte, length)}
+
+	_, err = io.ReadFull(reader, packet.Body)
+	if err != nil {
+		return Packet{}, fmt.Errorf("error reading packet: %w", err)
+	}
+
+	return packet, nil
+}
+
+// WritePacket writes a packet in a single write.
+func WritePacket(writer io.Writer, packet Packet) error {
+	length := len(packet.Body)
+	if length > maxRemainingLength {
+		return fmt.Errorf("packet of %d bytes is too large", length)
+	}
+
+	data := make([]byte, 0, length+5)
+	data = append(data, packet.Type<<4|packet.Flags)
+
+	for {
+		digit := byte(length & 0x7f)
+
+		length >>= 7
+		if length > 0 {
+			digit |= 0x80
+		}
+
+		data = append(data, digit)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	_, err := writer.Write(append(data, packet.Body...))
+	if err != nil {
+		return fmt.Errorf("error writing packet: %w", err)
+	}
+
+	return nil
+}
+
+func appendString(data []byte, value string) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+
+	return append(data, value...)
+}
+
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, errMalformed
+	}
+
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+length {
+		return "", nil, errMalformed
+	}
+
+	return string(data[2 : 2+length]), data[2+length:], nil
+}
+
+// EncodeConnect returns the CONNECT packet for a connection.
+func EncodeConnect(connect Connect) Packet {
+	var flags byte
+
+	if connect.CleanSession {
+		flags |= flagCleanSession
+	}
+
+	if connect.Will != nil {
+		flags |= flagWill | connect.Will.QoS<<willQoSShift
+
+		if connect.Will.Retain {
+			flags |= flagWillRetain
+		}
+	}
+
+	if connect.Username != "" {
+		flags |= flagUsername
+	}
+
+	if connect.Password != "" {
+		flags |= flagPassword
+	}
+
+	body := appendString(nil, protocolName)
+	body = append(body, protocolLevel, flags)
+	body = binary.BigEndian.AppendUint16(body, connect.KeepAlive)
+	body = appendString(body, connect.ClientID)
+
+	if connect.Will != nil {
+		body = appendString(body, connect.Will.Topic)
+		body = appendString(body, string(connect.Will.Payload))
+	}
+
+	if connect.Username != "" {
+		body = appendString(body, connect.Username)
+	}
+
+	if connect.Password != "" {
+		body = appendString(body, connect.Password)
+	}
+
+	return Packet{Type: TypeConnect, Body: body}
+}
+
+// ParseConnect returns the content of a CONNECT packet.
+func ParseConnect(packet Packet) (Connect, error) {
+	var connect Connect
+
+	name, rest, err := readString(packet.Body)
+	if err != nil || name != protocolName || len(rest) < 4 || rest[0] != protocolLevel {
+		return connect, errMalformed
+	}
+
+	flags := rest[1]
+	connect.KeepAlive = binary.BigEndian.Uint16(rest[2:])
+	connect.CleanSession = flags&flagCleanSession != 0
+
+	connect.ClientID, rest, err = readString(rest[4:])
+	if err != nil {
+		return connect, err
+	}
+
+	if flags&flagWill != 0 {
+		will := &Message{QoS: flags >> willQoSShift & qosMask, Retain: flags&flagWillRetain != 0}
+
+		var payload string
+
+		will.Topic, rest, err = readString(rest)
+		if err == nil {
+			payload, rest, err = readString(rest)
+		}
+
+		if err != nil {
+			return connect, err
+		}
+
+		will.Payload = []byte(payload)
+		connect.Will = will
+	}
+
+	if flags&flagUsername != 0 {
+		connect.Username, rest, err = readString(rest)
+		if err != nil {
+			return connect, err
+		}
+	}
+
+	if flags&flagPassword != 0 {
+		connect.Password, _, err = readString(rest)
+		if err != nil {
+			return connect, err
+		}
+	}
+
+	return connect, nil
+}
+
+// EncodePublish returns the PUBLISH packet for a message. The packet ID is only
+// used with QoS 1 and 2.
+func EncodePublish(message Message, packetID uint16) Packet {
+	flags := message.QoS << publishQoSShift
+	if message.Retain {
+		flags |= publishRetain
+	}
+
+	body := appendString(nil, message.Topic)
+	if message.QoS > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+
+	return Packet{Type: TypePublish, Flags: flags, Body: append(body, message.Payload...)}
+}
+
+// ParsePublish returns the message and packet ID of a PUBLISH packet.
+func ParsePublish(packet Packet) (Message, uint16, error) {
+	message := Message{
+		QoS:    packet.Flags >> publishQoSShift & qosMask,
+		Retain: packet.Flags&publishRetain != 0,
+	}
+
+	topic, rest, err := readString(packet.Body)
+	if err != nil {
+		return message, 0, err
+	}
+
+	message.Topic = topic
+
+	var packetID uint16
+
+	if message.QoS > 0 {
+		if len(rest) < 2 {
+			return message, 0, errMalformed
+		}
+
+		packetID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+
+	message.Payload = rest
+
+	return message, packetID, nil
+}
+
+// EncodeAck returns an acknowledgement packet (PUBACK, PUBREC, PUBREL or
+// PUBCOMP) for a packet ID.
+func EncodeAck(packetType byte, packetID uint16) Packet {
+	var flags byte
+
+	// PUBREL is the only acknowledgement with reserved flags set
+	if packetType == TypePubrel {
+		flags = 0x02
+	}
+
+	return Packet{
+		Type:  packetType,
+		Flags: flags,
+		Body:  binary.BigEndian.AppendUint16(nil, packetID),
+	}
+}
+
+// AckID returns the packet ID of an acknowledgement packet.
+func AckID(packet Packet) (uint16, error) {
+	if len(packet.Body) != 2 {
+		return 0, errMalformed
+	}
+
+	return binary.BigEndian.Uint16(packet.Body), nil
+}
@@ -0,0 +1,100 @@
+package types
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import "net/url"
+
+// SchemaVersion is the version of JSONRecord. It is only raised for changes that
+// break readers; new optional fields keep the current version.
+const SchemaVersion = 1
+
+// JSONRecord is a record in the JSON Lines activity log. Fields that do not apply
+// to a record are omitted.
+type JSONRecord struct {
+	Version   int            `json:"v"`
+	Time      string         `json:"time"`
+	Ops       []string       `json:"ops"`
+	Path      string         `json:"path"`
+	Disk      string         `json:"disk,omitempty"`
+	PID       int            `json:"pid"`
+	Process   string         `json:"process,omitempty"`
+	Container *JSONContainer `json:"container,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	ImagePath string         `json:"image_path,omitempty"`
+	Flag      string         `json:"flag,omitempty"`
+	Count     int            `json:"count"`
+	FirstSeen string         `json:"first_seen"`
+	LastSeen  string         `json:"last_seen"`
+	Message   string         `json:"message,omitempty"`
+}
+
+// JSONContainer is the container that caused the events of a JSONRecord.
+// Fields holds the configured container fields, such as "compose_project".
+type JSONContainer struct {
+	Name   string            `json:"name"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Path   string            `json:"path,omitempty"`
+}
+
+// JSON returns the record as it is written to the JSON Lines activity log.
+func (r Record) JSON() JSONRecord {
+	record := JSONRecord{
+		Version:   SchemaVersion,
+		Time:      r.FirstSeen.Format(TimeFormat),
+		Ops:       r.Op.Names(),
+		Path:      r.File,
+		Disk:      r.Disk,
+		PID:       r.PID,
+		Process:   r.ProcessPath,
+		Source:    r.Source,
+		ImagePath: r.ImagePath,
+		Flag:      r.Flag,
+		Count:     r.Count,
+		FirstSeen: r.FirstSeen.Format(TimeFormat),
+		LastSeen:  r.LastSeen.Format(TimeFormat),
+		Message:   r.Message,
+	}
+
+	if r.Container != "" {
+		record.Container = &JSONContainer{
+			Name:   r.Container,
+			Fields: decodeFields(r.ContainerFields),
+			Path:   r.ContainerPath,
+		}
+	}
+
+	return record
+}
+
+// decodeFields decodes the container fields column, which is URL-encoded so
+// that it fits a single CSV column.
+func decodeFields(encoded string) map[string]string {
+	// Whatever could be decoded before an invalid pair is still returned
+	values, _ := url.ParseQuery(encoded)
+	if len(values) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(values))
+	for key := range values {
+		fields[key] = values.Get(key)
+	}
+
+	return fields
+}
@@ -0,0 +1,118 @@
+package types
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is a set of file operations reported for an event.
+type Op uint16
+
+const (
+	OpCreate Op = 1 << iota
+	OpRemove
+	OpWrite
+	OpOpen
+	OpRead
+	OpRename
+	OpChmod
+	// OpSummary marks records that report on other events, such as the events
+	// a rate limit suppressed, instead of a file operation. It is never watched.
+	OpSummary
+
+	OpAll = OpCreate | OpRemove | OpWrite | OpOpen | OpRead | OpRename | OpChmod
+)
+
+// opNames lists the configuration names of the operations, in the order
+// they are written to the activity log.
+var opNames = []struct { //nolint:gochecknoglobals
+	op   Op
+	name string
+}{
+	{OpCreate, "create"},
+	{OpRemove, "remove"},
+	{OpWrite, "write"},
+	{OpOpen, "open"},
+	{OpRead, "read"},
+	{OpRename, "rename"},
+	{OpChmod, "chmod"},
+	{OpSummary, "summary"},
+}
+
+// Has reports whether any of the operations in other are set.
+func (o Op) Has(other Op) bool {
+	return o&other != 0
+}
+
+// String returns the operations as they appear in the activity log, such as "OPEN|READ".
+func (o Op) String() string {
+	ops := []string{}
+
+	for _, entry := range opNames {
+		if o.Has(entry.op) {
+			ops = append(ops, strings.ToUpper(entry.name))
+		}
+	}
+
+	return strings.Join(ops, "|")
+}
+
+// Names returns the configuration names of the operations, such as ["open", "read"].
+func (o Op) Names() []string {
+	names := []string{}
+
+	for _, entry := range opNames {
+		if o.Has(entry.op) {
+			names = append(names, entry.name)
+		}
+	}
+
+	return names
+}
+
+// ParseOp returns the operation with the given name. Names are case-insensitive.
+func ParseOp(name string) (Op, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, entry := range opNames {
+		if entry.name == name {
+			return entry.op, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown operation %q", name)
+}
+
+// ParseOps combines the named operations into a single set.
+func ParseOps(names []string) (Op, error) {
+	var ops Op
+
+	for _, name := range names {
+		op, err := ParseOp(name)
+		if err != nil {
+			return 0, err
+		}
+
+		ops |= op
+	}
+
+	return ops, nil
+}
@@ -0,0 +1,94 @@
+package types
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+)
+
+func TestOp_String(t *testing.T) {
+	tests := []struct {
+		op       Op
+		expected string
+	}{
+		{OpWrite, "WRITE"},
+		{OpOpen | OpRead, "OPEN|READ"},
+		{OpRead | OpOpen, "OPEN|READ"},
+		{OpCreate | OpRemove | OpChmod, "CREATE|REMOVE|CHMOD"},
+		{OpAll, "CREATE|REMOVE|WRITE|OPEN|READ|RENAME|CHMOD"},
+		{0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := tt.op.String(); result != tt.expected {
+				t.Errorf("String() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOp_Has(t *testing.T) {
+	op := OpOpen | OpRead
+
+	if !op.Has(OpRead) {
+		t.Error("Expected OPEN|READ to have READ")
+	}
+
+	if !op.Has(OpWrite | OpRead) {
+		t.Error("Expected OPEN|READ to have one of WRITE|READ")
+	}
+
+	if op.Has(OpWrite) {
+		t.Error("Expected OPEN|READ not to have WRITE")
+	}
+}
+
+func TestParseOps(t *testing.T) {
+	op, err := ParseOps([]string{"create", " Write ", "REMOVE", "rename"})
+	if err != nil {
+		t.Fatalf("ParseOps() failed: %v", err)
+	}
+
+	if op != OpCreate|OpWrite|OpRemove|OpRename {
+		t.Errorf("ParseOps() = %v, expected CREATE|REMOVE|WRITE|RENAME", op)
+	}
+
+	_, err = ParseOps([]string{"write", "close"})
+	if err == nil {
+		t.Error("Expected error for unknown operation")
+	}
+
+	op, err = ParseOps(nil)
+	if err != nil || op != 0 {
+		t.Errorf("Expected no operations for an empty list, got %v (%v)", op, err)
+	}
+}
+
+func TestOp_Names(t *testing.T) {
+	names := (OpRead | OpCreate).Names()
+
+	if len(names) != 2 || names[0] != "create" || names[1] != "read" {
+		t.Errorf("Names() = %v, expected [create read]", names)
+	}
+
+	if names := Op(0).Names(); len(names) != 0 {
+		t.Errorf("Names() = %v, expected none", names)
+	}
+}
@@ -0,0 +1,147 @@
+package types
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the format of the timestamps in the activity log.
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Record is an entry in the activity log. A record stands for Count events
+// seen between FirstSeen and LastSeen; uncoalesced records have a Count of 1.
+// Summary records have the OpSummary operation and describe the events they
+// stand for in Message; File is only ever a path.
+type Record struct {
+	Op              Op
+	File            string
+	Disk            string
+	PID             int
+	ProcessPath     string
+	Container       string
+	ContainerFields string
+	ContainerPath   string
+	Source          string
+	ImagePath       string // Path inside the loop-mounted image File refers to
+	Flag            string
+	Count           int
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Message         string
+}
+
+// Fields returns the activity log columns of the record. Columns are only ever
+// appended, as readers address them by position.
+func (r Record) Fields() []string {
+	return []string{
+		r.FirstSeen.Format(TimeFormat),
+		r.Op.String(),
+		r.File,
+		strconv.Itoa(r.PID),
+		r.ProcessPath,
+		r.Container,
+		r.ContainerFields,
+		r.ContainerPath,
+		r.Source,
+		r.ImagePath,
+		r.Flag,
+		strconv.Itoa(r.Count),
+		r.FirstSeen.Format(TimeFormat),
+		r.LastSeen.Format(TimeFormat),
+		r.Message,
+	}
+}
+
+// ParseRecord reads a record back from its activity log columns. Logs written by
+// older versions have fewer columns; the missing ones are left empty, with a
+// Count of 1 seen at the time of the event.
+func ParseRecord(fields []string) (Record, error) {
+	const minFields = 3
+
+	if len(fields) < minFields {
+		return Record{}, fmt.Errorf("expected at least %d columns, got %d", minFields, len(fields))
+	}
+
+	column := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+
+		return ""
+	}
+
+	timestamp, err := time.Parse(TimeFormat, fields[0])
+	if err != nil {
+		return Record{}, fmt.Errorf("error parsing time: %w", err)
+	}
+
+	var ops Op
+
+	for name := range strings.SplitSeq(fields[1], "|") {
+		op, err := ParseOp(name)
+		if err != nil {
+			return Record{}, err
+		}
+
+		ops |= op
+	}
+
+	record := Record{
+		Op:              ops,
+		File:            fields[2],
+		ProcessPath:     column(4),
+		Container:       column(5),
+		ContainerFields: column(6),
+		ContainerPath:   column(7),
+		Source:          column(8),
+		ImagePath:       column(9),
+		Flag:            column(10),
+		Message:         column(14),
+		Count:           1,
+		FirstSeen:       timestamp,
+		LastSeen:        timestamp,
+	}
+
+	if pid := column(3); pid != "" {
+		record.PID, err = strconv.Atoi(pid)
+		if err != nil {
+			return Record{}, fmt.Errorf("error parsing PID: %w", err)
+		}
+	}
+
+	if count := column(11); count != "" {
+		record.Count, err = strconv.Atoi(count)
+		if err != nil {
+			return Record{}, fmt.Errorf("error parsing count: %w", err)
+		}
+	}
+
+	if lastSeen := column(13); lastSeen != "" {
+		record.LastSeen, err = time.Parse(TimeFormat, lastSeen)
+		if err != nil {
+			return Record{}, fmt.Errorf("error parsing last seen time: %w", err)
+		}
+	}
+
+	return record, nil
+}
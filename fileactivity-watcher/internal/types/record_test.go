@@ -0,0 +1,178 @@
+package types
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecord_Fields(t *testing.T) {
+	first := time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	record := Record{
+		Op:          OpOpen | OpRead,
+		File:        "/mnt/disk1/Media/movie.mkv",
+		PID:         1234,
+		ProcessPath: "/usr/lib/plexmediaserver/Plex Media Server",
+		Container:   "plex",
+		Count:       5000,
+		FirstSeen:   first,
+		LastSeen:    first.Add(time.Minute),
+	}
+
+	fields := record.Fields()
+
+	expected := []string{
+		"2026-01-02T03:04:05.006Z",
+		"OPEN|READ",
+		"/mnt/disk1/Media/movie.mkv",
+		"1234",
+		"/usr/lib/plexmediaserver/Plex Media Server",
+		"plex",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"5000",
+		"2026-01-02T03:04:05.006Z",
+		"2026-01-02T03:05:05.006Z",
+		"",
+	}
+
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected %d fields, got %d", len(expected), len(fields))
+	}
+
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("Field %d = %q, expected %q", i, fields[i], expected[i])
+		}
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	first := time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	record := Record{
+		Op:              OpCreate | OpWrite,
+		File:            "/mnt/disk1/a.txt",
+		PID:             12,
+		ProcessPath:     "/usr/bin/rsync",
+		Container:       "plex",
+		ContainerFields: "image=plex",
+		ContainerPath:   "/data/a.txt",
+		Source:          "cron",
+		ImagePath:       "/var/lib/docker/volumes/db",
+		Flag:            "watch",
+		Count:           3,
+		FirstSeen:       first,
+		LastSeen:        first.Add(time.Second),
+	}
+
+	parsed, err := ParseRecord(record.Fields())
+	if err != nil {
+		t.Fatalf("ParseRecord() failed: %v", err)
+	}
+
+	if parsed != record {
+		t.Errorf("ParseRecord() = %+v, expected %+v", parsed, record)
+	}
+
+	// Older logs only have the first six columns
+	parsed, err = ParseRecord([]string{"2026-01-02T03:04:05.006Z", "WRITE", "/mnt/disk1/b", "7"})
+	if err != nil {
+		t.Fatalf("ParseRecord() failed for a short row: %v", err)
+	}
+
+	if parsed.Count != 1 || parsed.PID != 7 || !parsed.LastSeen.Equal(first) {
+		t.Errorf("Unexpected record for a short row: %+v", parsed)
+	}
+
+	// Summary records round-trip with their message and without a path
+	summary := Record{
+		Op:        OpSummary,
+		Source:    "rate-limit:disk",
+		Count:     48213,
+		FirstSeen: first,
+		LastSeen:  first.Add(time.Minute),
+		Message:   "disk4: 48,213 events suppressed in 60s",
+	}
+
+	parsed, err = ParseRecord(summary.Fields())
+	if err != nil {
+		t.Fatalf("ParseRecord() failed for a summary: %v", err)
+	}
+
+	if parsed != summary {
+		t.Errorf("ParseRecord() = %+v, expected %+v", parsed, summary)
+	}
+
+	invalid := [][]string{
+		{"2026-01-02T03:04:05.006Z", "WRITE"},
+		{"yesterday", "WRITE", "/mnt/disk1/b"},
+		{"2026-01-02T03:04:05.006Z", "CLOSE", "/mnt/disk1/b"},
+		{"2026-01-02T03:04:05.006Z", "WRITE", "/mnt/disk1/b", "pid"},
+	}
+
+	for _, fields := range invalid {
+		if _, err := ParseRecord(fields); err == nil {
+			t.Errorf("Expected error for %v", fields)
+		}
+	}
+}
+
+func TestRecord_JSON(t *testing.T) {
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	record := Record{
+		Op:        OpRename,
+		File:      "/mnt/disk1/a.txt",
+		Disk:      "disk1",
+		PID:       12,
+		Count:     1,
+		FirstSeen: first,
+		LastSeen:  first,
+	}
+
+	jsonRecord := record.JSON()
+
+	if jsonRecord.Version != SchemaVersion || jsonRecord.Time != "2026-01-02T03:04:05.000Z" ||
+		len(jsonRecord.Ops) != 1 || jsonRecord.Ops[0] != "rename" || jsonRecord.Disk != "disk1" {
+		t.Errorf("Unexpected JSON record: %+v", jsonRecord)
+	}
+
+	if jsonRecord.Container != nil {
+		t.Errorf("Expected no container, got %+v", jsonRecord.Container)
+	}
+
+	record.Container = "plex"
+	if container := record.JSON().Container; container == nil || container.Name != "plex" {
+		t.Errorf("Expected container plex, got %+v", container)
+	}
+
+	record.ContainerFields = "compose_project=media&label%3Acom.example.tier=gold+plus"
+
+	container := record.JSON().Container
+	if container.Fields["compose_project"] != "media" ||
+		container.Fields["label:com.example.tier"] != "gold plus" {
+		t.Errorf("Expected decoded container fields, got %v", container.Fields)
+	}
+}
@@ -18,8 +18,73 @@ package types
 	along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
+import "strings"
+
 type Event struct {
-	File string
-	PID  int
-	Op   string
+	File  string
+	PID   int
+	Op    Op
+	Image string // Backing image file when File is inside a loop-mounted image
+}
+
+// Path returns the path the event is filed under. Events inside loop-mounted
+// images are rolled up under the backing image.
+func (e Event) Path() string {
+	if e.Image != "" {
+		return e.Image
+	}
+
+	return e.File
+}
+
+// ImagePath returns the path inside the loop-mounted image for events that
+// are rolled up under their backing image, and an empty string otherwise.
+func (e Event) ImagePath() string {
+	if e.Image != "" {
+		return e.File
+	}
+
+	return ""
+}
+
+// Container holds the metadata known about the container that generated an event.
+type Container struct {
+	ID             string
+	Name           string
+	Image          string
+	ComposeProject string
+	Template       string
+	Labels         map[string]string
+	Mounts         []Mount
+}
+
+// Mount maps a host path into a container.
+type Mount struct {
+	Source      string
+	Destination string
+}
+
+// Field returns the value of a metadata field by its configuration name.
+// Labels are addressed as "label:<key>".
+func (c Container) Field(name string) (string, bool) {
+	if key, ok := strings.CutPrefix(name, "label:"); ok {
+		value, exists := c.Labels[key]
+
+		return value, exists
+	}
+
+	switch name {
+	case "id":
+		return c.ID, true
+	case "name":
+		return c.Name, true
+	case "image":
+		return c.Image, true
+	case "compose_project":
+		return c.ComposeProject, true
+	case "template":
+		return c.Template, true
+	}
+
+	return "", false
 }
@@ -26,7 +26,7 @@ func TestEvent_Struct(t *testing.T) {
 	event := Event{
 		File: "/mnt/disk1/test.txt",
 		PID:  1234,
-		Op:   "WRITE",
+		Op:   OpWrite,
 	}
 
 	if event.File != "/mnt/disk1/test.txt" {
@@ -37,17 +37,17 @@ func TestEvent_Struct(t *testing.T) {
 		t.Errorf("Expected PID to be 1234, got %d", event.PID)
 	}
 
-	if event.Op != "WRITE" {
+	if event.Op != OpWrite {
 		t.Errorf("Expected Op to be 'WRITE', got %s", event.Op)
 	}
 }
 
 func TestEvent_Equality(t *testing.T) {
-	event1 := Event{File: "/test.txt", PID: 100, Op: "READ"}
-	event2 := Event{File: "/test.txt", PID: 100, Op: "READ"}
-	event3 := Event{File: "/test.txt", PID: 200, Op: "READ"}
-	event4 := Event{File: "/other.txt", PID: 100, Op: "READ"}
-	event5 := Event{File: "/test.txt", PID: 100, Op: "WRITE"}
+	event1 := Event{File: "/test.txt", PID: 100, Op: OpRead}
+	event2 := Event{File: "/test.txt", PID: 100, Op: OpRead}
+	event3 := Event{File: "/test.txt", PID: 200, Op: OpRead}
+	event4 := Event{File: "/other.txt", PID: 100, Op: OpRead}
+	event5 := Event{File: "/test.txt", PID: 100, Op: OpWrite}
 
 	if event1 != event2 {
 		t.Error("Events with identical fields should be equal")
@@ -69,9 +69,9 @@ func TestEvent_Equality(t *testing.T) {
 func TestEvent_AsMapKey(t *testing.T) {
 	eventMap := make(map[Event]string)
 
-	event1 := Event{File: "/test1.txt", PID: 100, Op: "READ"}
-	event2 := Event{File: "/test2.txt", PID: 200, Op: "WRITE"}
-	event3 := Event{File: "/test1.txt", PID: 100, Op: "READ"}
+	event1 := Event{File: "/test1.txt", PID: 100, Op: OpRead}
+	event2 := Event{File: "/test2.txt", PID: 200, Op: OpWrite}
+	event3 := Event{File: "/test1.txt", PID: 100, Op: OpRead}
 
 	eventMap[event1] = "first"
 	eventMap[event2] = "second"
@@ -101,13 +101,13 @@ func TestEvent_ZeroValue(t *testing.T) {
 		t.Error("Expected PID to be 0 by default")
 	}
 
-	if event.Op != "" {
-		t.Error("Expected Op to be empty string by default")
+	if event.Op != 0 {
+		t.Error("Expected Op to be empty by default")
 	}
 }
 
 func TestEvent_VariousOperations(t *testing.T) {
-	operations := []string{"READ", "WRITE", "CREATE", "DELETE", "MODIFY", "OPEN", "CLOSE"}
+	operations := []Op{OpRead, OpWrite, OpCreate, OpRemove, OpOpen, OpRename, OpChmod}
 
 	for _, op := range operations {
 		event := Event{
@@ -135,7 +135,7 @@ func TestEvent_VariousPaths(t *testing.T) {
 		event := Event{
 			File: path,
 			PID:  1234,
-			Op:   "READ",
+			Op:   OpRead,
 		}
 
 		if event.File != path {
@@ -151,7 +151,7 @@ func TestEvent_PIDBoundaries(t *testing.T) {
 		event := Event{
 			File: "/test.txt",
 			PID:  pid,
-			Op:   "WRITE",
+			Op:   OpWrite,
 		}
 
 		if event.PID != pid {
@@ -164,7 +164,7 @@ func TestEvent_EmptyFields(t *testing.T) {
 	event := Event{
 		File: "",
 		PID:  0,
-		Op:   "",
+		Op:   0,
 	}
 
 	if event.File != "" {
@@ -175,7 +175,7 @@ func TestEvent_EmptyFields(t *testing.T) {
 		t.Error("Expected PID to be 0")
 	}
 
-	if event.Op != "" {
+	if event.Op != 0 {
 		t.Error("Expected empty Op field")
 	}
 }
@@ -184,14 +184,14 @@ func TestEvent_CopyBehavior(t *testing.T) {
 	original := Event{
 		File: "/original.txt",
 		PID:  100,
-		Op:   "READ",
+		Op:   OpRead,
 	}
 
 	copy := original
 
 	copy.File = "/modified.txt"
 	copy.PID = 200
-	copy.Op = "WRITE"
+	copy.Op = OpWrite
 
 	if original.File != "/original.txt" {
 		t.Error("Original File was modified")
@@ -201,7 +201,7 @@ func TestEvent_CopyBehavior(t *testing.T) {
 		t.Error("Original PID was modified")
 	}
 
-	if original.Op != "READ" {
+	if original.Op != OpRead {
 		t.Error("Original Op was modified")
 	}
 
@@ -213,7 +213,68 @@ func TestEvent_CopyBehavior(t *testing.T) {
 		t.Error("Copy PID was not modified")
 	}
 
-	if copy.Op != "WRITE" {
+	if copy.Op != OpWrite {
 		t.Error("Copy Op was not modified")
 	}
 }
+
+func TestEvent_Path(t *testing.T) {
+	event := Event{File: "/mnt/disk1/a.txt"}
+
+	if event.Path() != "/mnt/disk1/a.txt" || event.ImagePath() != "" {
+		t.Errorf("Expected plain events to be filed under their own path, got %q and %q",
+			event.Path(), event.ImagePath())
+	}
+
+	event = Event{File: "/var/lib/docker/a.txt", Image: "/mnt/disk1/system/docker.img"}
+
+	if event.Path() != "/mnt/disk1/system/docker.img" {
+		t.Errorf("Expected loop image events to be filed under the image, got %q", event.Path())
+	}
+
+	if event.ImagePath() != "/var/lib/docker/a.txt" {
+		t.Errorf("Expected the path inside the image, got %q", event.ImagePath())
+	}
+}
+
+func TestContainer_Field(t *testing.T) {
+	container := Container{
+		ID:             "abc123",
+		Name:           "sonarr",
+		Image:          "lscr.io/linuxserver/sonarr",
+		ComposeProject: "arr",
+		Template:       "/boot/config/plugins/dockerMan/templates-user/my-sonarr.xml",
+		Labels:         map[string]string{"net.unraid.docker.managed": "dockerman"},
+	}
+
+	tests := []struct {
+		field    string
+		expected string
+		found    bool
+	}{
+		{"id", "abc123", true},
+		{"name", "sonarr", true},
+		{"image", "lscr.io/linuxserver/sonarr", true},
+		{"compose_project", "arr", true},
+		{"template", "/boot/config/plugins/dockerMan/templates-user/my-sonarr.xml", true},
+		{"label:net.unraid.docker.managed", "dockerman", true},
+		{"label:missing", "", false},
+		{"unknown", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			value, found := container.Field(tt.field)
+			if value != tt.expected || found != tt.found {
+				t.Errorf(
+					"Field(%q) = (%q, %v), expected (%q, %v)",
+					tt.field,
+					value,
+					found,
+					tt.expected,
+					tt.found,
+				)
+			}
+		})
+	}
+}
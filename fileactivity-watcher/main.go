@@ -22,25 +22,42 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/coalesce"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/disks"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/docker"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/filter"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/monitor"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/ratelimit"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/sink"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/version"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/writer"
 )
 
+const statsInterval = time.Minute
+
 type App struct {
 	appConfig    config.ActivityConfig
 	watchFolders map[string]int
+	loopImages   map[string]string
+	sinks        *sink.Dispatcher
+	eventFilter  *filter.Filter
+	coalescer    *coalesce.Coalescer
+	limiter      *ratelimit.Limiter
+
+	listenerMu      sync.Mutex
+	listenerStopped bool
 }
 
 func setup() {
@@ -49,6 +66,11 @@ func setup() {
 
 	debug := flag.Bool("debug", false, "sets log level to debug")
 	license := flag.Bool("license", false, "prints the license information")
+	migrate := flag.Bool(
+		"migrate",
+		false,
+		"converts the CSV activity logs to JSON Lines and exits; stop the watcher first",
+	)
 
 	flag.Parse()
 
@@ -65,6 +87,28 @@ func setup() {
 
 	// Log version information
 	version.OutputToLog()
+
+	if *migrate {
+		migrateActivityLogs(config.LoadConfig().ActivityPath)
+		os.Exit(0)
+	}
+}
+
+// migrateActivityLogs converts the activity log and its rotated files to JSON Lines.
+func migrateActivityLogs(activityPath string) {
+	paths := []string{}
+	for _, file := range writer.RotatedFiles(activityPath) {
+		paths = append(paths, file.Path)
+	}
+
+	for _, path := range append(paths, activityPath) {
+		_, err := writer.Migrate(path, func(file string) string {
+			return filter.EventDisk(types.Event{File: file})
+		})
+		if err != nil {
+			log.Fatal().Err(err).Str("file", path).Msg("Error migrating activity file")
+		}
+	}
 }
 
 func main() {
@@ -73,12 +117,18 @@ func main() {
 
 	app := NewApp()
 
-	app.watchFolders = app.GetWatchFolders()
+	app.watchFolders, app.loopImages = app.GetWatchFolders()
 
-	app.startEventListener(context.Background())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app.startEventListener(ctx)
+	app.startStatsDump(ctx)
 
 	log.Info().Msg("Watcher ready")
-	<-make(chan struct{})
+	<-ctx.Done()
+
+	app.shutdown()
 }
 
 func NewApp() *App {
@@ -93,70 +143,327 @@ func NewApp() *App {
 	}
 }
 
-func (a *App) GetWatchFolders() map[string]int {
+// GetWatchFolders returns the disks to watch and the loop-mounted images stored on them.
+func (a *App) GetWatchFolders() (map[string]int, map[string]string) {
 	disks := disks.New(a.appConfig)
+	watchFolders := disks.GetWatchFolders()
 
-	return disks.GetWatchFolders()
+	return watchFolders, disks.GetLoopImages(watchFolders)
 }
 
 func (a *App) startEventListener(ctx context.Context) {
+	ops, err := types.ParseOps(a.appConfig.Events)
+	if err != nil || ops&types.OpAll == 0 {
+		log.Fatal().Err(err).Strs("events", a.appConfig.Events).Msg("Invalid event types")
+	}
+
+	sinks, err := sink.New(a.appConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error creating sinks")
+	}
+
+	a.sinks = sinks
+	a.eventFilter = filter.New(a.appConfig)
+
+	if a.appConfig.Coalesce {
+		a.coalescer = coalesce.New(a.writeRecord)
+	}
+
+	if len(a.appConfig.RateLimits) > 0 {
+		interval := time.Duration(a.appConfig.RateLimitInterval) * time.Second
+		a.limiter = ratelimit.New(a.appConfig.RateLimits, interval, a.writeRecord)
+	}
+
 	go func() {
 		log.Info().Msg("Starting event listener...")
 
 		dockerClient := docker.New()
-		filter := filter.New(a.appConfig)
+		dockerClient.Watch(ctx)
 
-		activityFile, err := writer.New(a.appConfig.ActivityPath, a.appConfig.MaxRecords)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Error creating activity file writer")
+		monitor := monitor.New(a.watchFolders, a.loopImages, ops)
+
+		for {
+			event, err := monitor.GetEvent()
+
+			// Events are handled under the lock, so that shutdown can wait for
+			// the one in flight
+			a.listenerMu.Lock()
+
+			if a.listenerStopped {
+				a.listenerMu.Unlock()
+				log.Info().Msg("Event listener shutting down...")
+
+				return
+			}
+
+			if err != nil {
+				log.Error().Err(err).Msg("Error getting event")
+			} else {
+				a.handleEvent(ctx, monitor, dockerClient, event)
+			}
+
+			a.listenerMu.Unlock()
 		}
-		defer activityFile.Close()
+	}()
+}
+
+// handleEvent filters and enriches an event and passes its record on to the
+// coalescer or the sinks.
+func (a *App) handleEvent(
+	ctx context.Context,
+	eventMonitor *monitor.Monitor,
+	dockerClient *docker.Client,
+	event types.Event,
+) {
+	eventFilter := a.eventFilter
+	now := time.Now()
+
+	// Cheap checks first, so excluded and repeated events never
+	// pay for process and container resolution
+	if eventFilter.IsPathExcluded(event) || a.isRepeat(eventFilter, event, now) {
+		return
+	}
+
+	subject := filter.Subject{Event: event, Time: now}
+
+	match, decided := eventFilter.MatchRules(subject)
+	if decided && match.Action == filter.ActionDrop {
+		return
+	}
+
+	eventDetails := eventMonitor.GetEventDetails(event)
+	container := getContainer(ctx, dockerClient, eventDetails)
 
-		monitor := monitor.New(a.watchFolders)
+	if !decided {
+		subject.Resolved = true
+		subject.ProcessPath = eventDetails.ProcessPath
+		subject.UID = eventDetails.UID
+		subject.Source = eventDetails.Source
+		subject.Container = container
+
+		match, _ = eventFilter.MatchRules(subject)
+		if match.Action == filter.ActionDrop {
+			return
+		}
+	}
+
+	flag := ""
+	if match.Action == filter.ActionFlag {
+		flag = match.Rule
+	}
+
+	// Flagged events are never rate limited
+	if flag == "" && !a.allow(event, eventDetails, container, now) {
+		return
+	}
+
+	record := types.Record{
+		Op:              event.Op,
+		File:            event.Path(),
+		Disk:            filter.EventDisk(event),
+		PID:             event.PID,
+		ProcessPath:     eventDetails.ProcessPath,
+		Container:       container.Name,
+		ContainerFields: formatContainerFields(container, a.appConfig.ContainerFields),
+		ContainerPath:   docker.ContainerPath(container, event.File),
+		Source:          eventDetails.Source,
+		ImagePath:       event.ImagePath(),
+		Flag:            flag,
+		Count:           1,
+		FirstSeen:       now,
+		LastSeen:        now,
+	}
+
+	if a.coalescer != nil {
+		a.coalescer.Add(event, record, match.Coalesce)
+
+		return
+	}
+
+	a.writeRecord(record)
+}
+
+// isRepeat reports whether an event repeats a recent one. When coalescing,
+// the repeat is counted in the open record instead of being dropped.
+func (a *App) isRepeat(eventFilter *filter.Filter, event types.Event, now time.Time) bool {
+	if a.coalescer != nil {
+		return a.coalescer.Touch(event, now)
+	}
+
+	return eventFilter.IsDuplicate(event)
+}
+
+// allow reports whether an event is within the configured rate limits.
+func (a *App) allow(
+	event types.Event,
+	eventDetails monitor.EventDetails,
+	container types.Container,
+	now time.Time,
+) bool {
+	if a.limiter == nil {
+		return true
+	}
+
+	allowed := a.limiter.Allow(ratelimit.Subject{
+		Process:   eventDetails.ProcessPath,
+		Container: container.Name,
+		Disk:      filter.EventDisk(event),
+	}, now)
+	a.eventFilter.RecordRateLimit(event, !allowed)
+
+	return allowed
+}
+
+func (a *App) writeRecord(record types.Record) {
+	a.sinks.Dispatch(record)
+}
+
+// startStatsDump keeps the status file up to date with the filter statistics,
+// and also logs them on SIGUSR1.
+func (a *App) startStatsDump(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		ticker := time.NewTicker(statsInterval)
+		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				log.Info().Msg("Event listener shutting down...")
-
 				return
-			default:
-				event, err := monitor.GetEvent()
-				if err != nil {
-					log.Error().Err(err).Msg("Error getting event")
-
-					continue
-				}
-
-				if filter.IsExcluded(event) {
-					continue
-				}
-
-				eventDetails := monitor.GetEventDetails(event)
-
-				containerName := ""
-				if eventDetails.ContainerID != "" {
-					containerName = dockerClient.GetContainerNameByID(eventDetails.ContainerID, ctx)
-				}
-
-				err = activityFile.Write(
-					[]string{
-						time.Now().Format("2006-01-02T15:04:05.000Z07:00"),
-						event.Op,
-						event.File,
-						strconv.Itoa(event.PID),
-						eventDetails.ProcessPath,
-						containerName,
-					},
-				)
-				if err != nil {
-					log.Error().Err(err).Msg("Error writing activity record")
-				}
+			case <-signals:
+				a.dumpStats(true)
+			case <-ticker.C:
+				a.dumpStats(false)
 			}
 		}
 	}()
 }
 
+func (a *App) dumpStats(logStats bool) {
+	stats := a.eventFilter.Stats()
+
+	if logStats {
+		counters := append(append([]filter.Counter{}, stats.Exclusions...), stats.Rules...)
+		counters = append(counters, stats.Dedupe, stats.RateLimit)
+
+		for _, counter := range counters {
+			log.Info().
+				Str("name", counter.Name).
+				Uint64("seen", counter.Seen).
+				Uint64("matched", counter.Matched).
+				Uint64("dropped", counter.Dropped).
+				Str("last_path", counter.LastPath).
+				Time("since", stats.Since).
+				Msg("Filter statistics")
+		}
+
+		for _, sinkStats := range a.sinks.Stats() {
+			log.Info().
+				Str("name", sinkStats.Name).
+				Uint64("delivered", sinkStats.Delivered).
+				Uint64("dropped", sinkStats.Dropped).
+				Uint64("failed", sinkStats.Failed).
+				Int("queued", sinkStats.Queued).
+				Msg("Sink statistics")
+		}
+	}
+
+	err := stats.WriteFile(a.appConfig.StatusPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Error writing status file")
+	}
+}
+
+// shutdown writes out the open coalesced records and closes the sinks. The
+// pipeline is stopped from the front: the listener first, then the coalescer
+// and the limiter, which may still emit records, and the sinks last.
+func (a *App) shutdown() {
+	log.Info().Msg("Shutting down...")
+
+	// Wait for the event in flight. The listener can't be joined outright, as it
+	// blocks until the next event arrives, but it drops that event once stopped.
+	a.listenerMu.Lock()
+	a.listenerStopped = true
+	a.listenerMu.Unlock()
+
+	if a.coalescer != nil {
+		a.coalescer.Close()
+	}
+
+	if a.limiter != nil {
+		a.limiter.Close()
+	}
+
+	a.dumpStats(false)
+
+	err := a.sinks.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("Error closing sinks")
+	}
+}
+
+// getContainer returns the container an event came from.
+// Docker containers are resolved through the Docker API, LXC containers are already
+// identified by name, and other runtimes fall back to their short container ID.
+func getContainer(
+	ctx context.Context,
+	dockerClient *docker.Client,
+	eventDetails monitor.EventDetails,
+) types.Container {
+	switch eventDetails.ContainerRuntime {
+	case monitor.RuntimeNone:
+		return types.Container{}
+	case monitor.RuntimeDocker:
+		container := dockerClient.GetContainerByID(eventDetails.ContainerID, ctx)
+		if container.ID == "" {
+			// Keep the ID so that the event is still known to come from a container
+			container.ID = eventDetails.ContainerID
+		}
+
+		return container
+	case monitor.RuntimeLXC:
+		return types.Container{ID: eventDetails.ContainerID, Name: eventDetails.ContainerID}
+	default:
+		return types.Container{
+			ID: eventDetails.ContainerID,
+			Name: string(eventDetails.ContainerRuntime) + ":" +
+				shortContainerID(eventDetails.ContainerID),
+		}
+	}
+}
+
+// formatContainerFields encodes the configured container metadata fields as a
+// query string (for example "compose_project=arr&image=linuxserver%2Fsonarr"),
+// so that new fields don't shift the positional activity columns.
+func formatContainerFields(container types.Container, fields []string) string {
+	values := url.Values{}
+
+	for _, field := range fields {
+		value, ok := container.Field(field)
+		if !ok || value == "" {
+			continue
+		}
+
+		values.Set(field, value)
+	}
+
+	return values.Encode()
+}
+
+func shortContainerID(containerID string) string {
+	const shortIDLength = 12
+
+	if len(containerID) > shortIDLength {
+		return containerID[:shortIDLength]
+	}
+
+	return containerID
+}
+
 func printLicense() {
 	licenseText := `
 	This program is free software: you can redistribute it and/or modify
@@ -0,0 +1,163 @@
+package monitor
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strings"
+)
+
+// Runtime identifies the container engine that owns a process.
+type Runtime string
+
+const (
+	RuntimeNone       Runtime = ""
+	RuntimeDocker     Runtime = "docker"
+	RuntimeContainerd Runtime = "containerd"
+	RuntimePodman     Runtime = "podman"
+	RuntimeLXC        Runtime = "lxc"
+)
+
+// Container identifies the container a process runs in.
+// For LXC the ID is the container name, as that is all the cgroup exposes.
+type Container struct {
+	Runtime Runtime
+	ID      string
+}
+
+const containerIDLength = 64
+
+// parseCgroup returns the container described by the contents of a
+// /proc/<pid>/cgroup file. Both the cgroup v1 (one line per hierarchy)
+// and the cgroup v2 ("0::/path") layouts are supported.
+func parseCgroup(data string) Container {
+	for line := range strings.SplitSeq(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		container := parseCgroupPath(parts[2])
+		if container.Runtime != RuntimeNone {
+			return container
+		}
+	}
+
+	return Container{}
+}
+
+// parseCgroupPath walks a cgroup path from the root down and returns the
+// first container it finds. Walking from the root means that for nested
+// containers the outermost one, which is the one known to the host, wins.
+func parseCgroupPath(path string) Container {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range segments {
+		parent := ""
+		if i > 0 {
+			parent = segments[i-1]
+		}
+
+		container := parseCgroupSegment(parent, segment)
+		if container.Runtime != RuntimeNone {
+			return container
+		}
+	}
+
+	return Container{}
+}
+
+func parseCgroupSegment(parent, segment string) Container {
+	// systemd cgroup driver: docker-<id>.scope, libpod-<id>.scope, ...
+	if id, ok := scopeID(segment, "docker-"); ok {
+		return Container{Runtime: RuntimeDocker, ID: id}
+	}
+
+	if strings.HasPrefix(segment, "libpod-conmon-") {
+		// conmon is podman's monitor process, not the container itself
+		return Container{}
+	}
+
+	if id, ok := scopeID(segment, "libpod-"); ok {
+		return Container{Runtime: RuntimePodman, ID: id}
+	}
+
+	if id, ok := scopeID(segment, "cri-containerd-"); ok {
+		return Container{Runtime: RuntimeContainerd, ID: id}
+	}
+
+	if id, ok := scopeID(segment, "nerdctl-"); ok {
+		return Container{Runtime: RuntimeContainerd, ID: id}
+	}
+
+	// LXC 4.0+ places the container payload in lxc.payload.<name>
+	if name, ok := strings.CutPrefix(segment, "lxc.payload."); ok && name != "" {
+		return Container{Runtime: RuntimeLXC, ID: name}
+	}
+
+	// Older LXC releases use lxc/<name> or lxc.payload/<name>
+	if (parent == "lxc" || parent == "lxc.payload") && segment != "" {
+		return Container{Runtime: RuntimeLXC, ID: segment}
+	}
+
+	if !isContainerID(segment) {
+		return Container{}
+	}
+
+	// cgroupfs driver: the ID is a plain directory under its parent
+	switch {
+	case parent == "docker":
+		return Container{Runtime: RuntimeDocker, ID: segment}
+	case parent == "libpod_parent":
+		return Container{Runtime: RuntimePodman, ID: segment}
+	case parent != "":
+		// containerd tasks live under /<namespace>/<id>, kubelet pods under /kubepods/.../pod<uid>/<id>
+		return Container{Runtime: RuntimeContainerd, ID: segment}
+	}
+
+	return Container{}
+}
+
+// scopeID extracts the container ID from a "<prefix><id>" or "<prefix><id>.scope" segment.
+func scopeID(segment, prefix string) (string, bool) {
+	id, ok := strings.CutPrefix(segment, prefix)
+	if !ok {
+		return "", false
+	}
+
+	id = strings.TrimSuffix(id, ".scope")
+	if !isContainerID(id) {
+		return "", false
+	}
+
+	return id, true
+}
+
+func isContainerID(value string) bool {
+	if len(value) != containerIDLength {
+		return false
+	}
+
+	for _, c := range value {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
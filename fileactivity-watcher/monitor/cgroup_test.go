@@ -0,0 +1,198 @@
+package monitor
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+)
+
+const (
+	testDockerID  = "4f1e3c5a9b2d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f"
+	testPodmanID  = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	testTaskID    = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	testNestedID  = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	testPodUIDDir = "pod6b7b4f3e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"
+)
+
+func TestParseCgroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		cgroup   string
+		expected Container
+	}{
+		{
+			name: "docker cgroup v1",
+			cgroup: "12:pids:/docker/" + testDockerID + "\n" +
+				"11:memory:/docker/" + testDockerID + "\n" +
+				"1:name=systemd:/docker/" + testDockerID + "\n" +
+				"0::/system.slice/containerd.service\n",
+			expected: Container{Runtime: RuntimeDocker, ID: testDockerID},
+		},
+		{
+			name:     "docker cgroup v2 cgroupfs driver",
+			cgroup:   "0::/docker/" + testDockerID + "\n",
+			expected: Container{Runtime: RuntimeDocker, ID: testDockerID},
+		},
+		{
+			name:     "docker cgroup v2 systemd driver",
+			cgroup:   "0::/system.slice/docker-" + testDockerID + ".scope\n",
+			expected: Container{Runtime: RuntimeDocker, ID: testDockerID},
+		},
+		{
+			name:     "docker nested cgroup keeps outermost container",
+			cgroup:   "0::/docker/" + testDockerID + "/docker/" + testNestedID + "\n",
+			expected: Container{Runtime: RuntimeDocker, ID: testDockerID},
+		},
+		{
+			name:     "dockerd itself is not a container",
+			cgroup:   "0::/system.slice/docker.service\n",
+			expected: Container{},
+		},
+		{
+			name:     "containerd task cgroupfs",
+			cgroup:   "0::/default/" + testTaskID + "\n",
+			expected: Container{Runtime: RuntimeContainerd, ID: testTaskID},
+		},
+		{
+			name: "containerd cri systemd driver",
+			cgroup: "0::/kubepods.slice/kubepods-besteffort.slice/" +
+				"kubepods-besteffort-pod6b7b4f3e.slice/cri-containerd-" + testTaskID + ".scope\n",
+			expected: Container{Runtime: RuntimeContainerd, ID: testTaskID},
+		},
+		{
+			name:     "containerd kubelet cgroupfs",
+			cgroup:   "0::/kubepods/besteffort/" + testPodUIDDir + "/" + testTaskID + "\n",
+			expected: Container{Runtime: RuntimeContainerd, ID: testTaskID},
+		},
+		{
+			name:     "nerdctl systemd driver",
+			cgroup:   "0::/system.slice/nerdctl-" + testTaskID + ".scope\n",
+			expected: Container{Runtime: RuntimeContainerd, ID: testTaskID},
+		},
+		{
+			name:     "containerd shim is not a container",
+			cgroup:   "0::/system.slice/containerd.service\n",
+			expected: Container{},
+		},
+		{
+			name:     "podman rootful",
+			cgroup:   "0::/machine.slice/libpod-" + testPodmanID + ".scope/container\n",
+			expected: Container{Runtime: RuntimePodman, ID: testPodmanID},
+		},
+		{
+			name: "podman rootless",
+			cgroup: "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" +
+				testPodmanID + ".scope\n",
+			expected: Container{Runtime: RuntimePodman, ID: testPodmanID},
+		},
+		{
+			name:     "podman cgroupfs",
+			cgroup:   "0::/libpod_parent/libpod-" + testPodmanID + "\n",
+			expected: Container{Runtime: RuntimePodman, ID: testPodmanID},
+		},
+		{
+			name:     "podman conmon is not a container",
+			cgroup:   "0::/machine.slice/libpod-conmon-" + testPodmanID + ".scope\n",
+			expected: Container{},
+		},
+		{
+			name:     "lxc payload cgroup v2",
+			cgroup:   "0::/lxc.payload.HomeAssistant\n",
+			expected: Container{Runtime: RuntimeLXC, ID: "HomeAssistant"},
+		},
+		{
+			name:     "lxc payload nested process",
+			cgroup:   "0::/lxc.payload.debian/system.slice/cron.service\n",
+			expected: Container{Runtime: RuntimeLXC, ID: "debian"},
+		},
+		{
+			name: "lxc payload cgroup v1",
+			cgroup: "10:memory:/lxc.payload.debian\n" +
+				"1:name=systemd:/lxc.payload.debian/init.scope\n",
+			expected: Container{Runtime: RuntimeLXC, ID: "debian"},
+		},
+		{
+			name:     "lxc legacy layout",
+			cgroup:   "4:cpu,cpuacct:/lxc/ubuntu\n",
+			expected: Container{Runtime: RuntimeLXC, ID: "ubuntu"},
+		},
+		{
+			name:     "lxc monitor is not a container",
+			cgroup:   "0::/lxc.monitor.debian\n",
+			expected: Container{},
+		},
+		{
+			name:     "host process",
+			cgroup:   "0::/\n",
+			expected: Container{},
+		},
+		{
+			name:     "host service",
+			cgroup:   "0::/system.slice/sshd.service\n",
+			expected: Container{},
+		},
+		{
+			name:     "short id under docker is ignored",
+			cgroup:   "0::/docker/abc123\n",
+			expected: Container{},
+		},
+		{
+			name:     "empty file",
+			cgroup:   "",
+			expected: Container{},
+		},
+		{
+			name:     "malformed line",
+			cgroup:   "garbage\n",
+			expected: Container{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseCgroup(tt.cgroup)
+			if result != tt.expected {
+				t.Errorf("parseCgroup() = %+v, expected %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsContainerID(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{"valid id", testDockerID, true},
+		{"too short", "abc123", false},
+		{"uppercase", "4F1E3C5A9B2D8E7F6A5B4C3D2E1F0A9B8C7D6E5F4A3B2C1D0E9F8A7B6C5D4E3F", false},
+		{"non hex", "zz1e3c5a9b2d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isContainerID(tt.value)
+			if result != tt.expected {
+				t.Errorf("isContainerID(%q) = %v, expected %v", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
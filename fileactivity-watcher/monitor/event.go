@@ -20,7 +20,6 @@ package monitor
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/fanotify"
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
@@ -28,8 +27,11 @@ import (
 )
 
 type EventDetails struct {
-	ContainerID string
-	ProcessPath string
+	ContainerID      string
+	ContainerRuntime Runtime
+	ProcessPath      string
+	UID              int
+	Source           string
 }
 
 func (m *Monitor) GetEvent() (types.Event, error) {
@@ -39,14 +41,14 @@ func (m *Monitor) GetEvent() (types.Event, error) {
 	}
 	defer data.Close()
 
-	opName := getOp(data)
+	op := getOp(data)
 	pid := data.GetPID()
 
 	var path string
 
 	// If we have an fsid, get or open the cached mount FD
 	if data.Fsid() != [8]byte{} {
-		mountPath, err := m.getMountPath(data.Fsid())
+		mountInfo, err := m.getMountInfo(data.Fsid())
 		if err != nil {
 			return types.Event{}, fmt.Errorf(
 				"fanotify: failed to get mount path for fsid %x: %w",
@@ -56,7 +58,7 @@ func (m *Monitor) GetEvent() (types.Event, error) {
 		}
 
 		// Get or open mount FD from cache (automatically managed)
-		mountFd, err := m.getOrOpenMountFD(data.Fsid(), mountPath)
+		mountFd, err := m.getOrOpenMountFD(data.Fsid(), mountInfo.Path)
 		if err != nil {
 			return types.Event{}, fmt.Errorf(
 				"fanotify: failed to open mount FD for fsid %x: %w",
@@ -75,9 +77,10 @@ func (m *Monitor) GetEvent() (types.Event, error) {
 		}
 
 		return types.Event{
-			File: path,
-			Op:   opName,
-			PID:  pid,
+			File:  path,
+			Op:    op,
+			PID:   pid,
+			Image: mountInfo.Image,
 		}, nil
 	}
 
@@ -85,41 +88,56 @@ func (m *Monitor) GetEvent() (types.Event, error) {
 }
 
 func (m *Monitor) GetEventDetails(event types.Event) EventDetails {
+	container := getContainer(event.PID)
+
 	return EventDetails{
-		ContainerID: getContainerID(event.PID),
-		ProcessPath: getProcessPath(event.PID),
+		ContainerID:      container.ID,
+		ContainerRuntime: container.Runtime,
+		ProcessPath:      getProcessPath(event.PID),
+		UID:              getProcessUID(event.PID),
+		Source:           getSource(procRoot, event.PID),
 	}
 }
 
-func getOp(data *fanotify.EventMetadata) string {
-	ops := []string{}
-	if data.Mask&unix.FAN_CREATE != 0 {
-		ops = append(ops, "CREATE")
-	}
+// opMasks maps the fanotify event bits to operations.
+var opMasks = []struct { //nolint:gochecknoglobals
+	op   types.Op
+	mask uint64
+}{
+	{types.OpCreate, unix.FAN_CREATE},
+	{types.OpRemove, unix.FAN_DELETE},
+	{types.OpWrite, unix.FAN_MODIFY},
+	{types.OpOpen, unix.FAN_OPEN},
+	{types.OpRead, unix.FAN_ACCESS},
+	{types.OpRename, unix.FAN_RENAME},
+	{types.OpChmod, unix.FAN_ATTRIB},
+}
 
-	if data.Mask&unix.FAN_DELETE != 0 {
-		ops = append(ops, "REMOVE")
-	}
+func getOp(data *fanotify.EventMetadata) types.Op {
+	return maskToOp(data.Mask)
+}
 
-	if data.Mask&unix.FAN_MODIFY != 0 {
-		ops = append(ops, "WRITE")
-	}
+func maskToOp(mask uint64) types.Op {
+	var op types.Op
 
-	if data.Mask&unix.FAN_OPEN != 0 {
-		ops = append(ops, "OPEN")
+	for _, entry := range opMasks {
+		if mask&entry.mask != 0 {
+			op |= entry.op
+		}
 	}
 
-	if data.Mask&unix.FAN_ACCESS != 0 {
-		ops = append(ops, "READ")
-	}
+	return op
+}
 
-	if data.Mask&unix.FAN_RENAME != 0 {
-		ops = append(ops, "RENAME")
-	}
+// opToMask returns the fanotify event bits to subscribe to for the operations.
+func opToMask(op types.Op) uint64 {
+	var mask uint64
 
-	if data.Mask&unix.FAN_ATTRIB != 0 {
-		ops = append(ops, "CHMOD")
+	for _, entry := range opMasks {
+		if op.Has(entry.op) {
+			mask |= entry.mask
+		}
 	}
 
-	return strings.Join(ops, "|")
+	return mask
 }
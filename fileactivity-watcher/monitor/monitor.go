@@ -19,11 +19,13 @@ package monitor
 */
 
 import (
+	"maps"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/fanotify"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sys/unix"
 )
@@ -34,16 +36,31 @@ type Monitor struct {
 	mountFDCacheMutex sync.RWMutex
 	mountTTL          time.Duration
 	watchFolders      map[string]int
+	loopImages        map[string]string
+	eventMask         uint64
 	watcher           *fanotify.NotifyFD
 }
 
-func New(watchFolders map[string]int) *Monitor {
+// New creates a monitor for the watch folders. loopImages maps the mountpoints of
+// loop-mounted images to their backing image file; those mountpoints are watched
+// as well, and their events are rolled up under the backing image.
+// Only the operations in ops are requested from the kernel.
+func New(watchFolders map[string]int, loopImages map[string]string, ops types.Op) *Monitor {
+	folders := make(map[string]int, len(watchFolders)+len(loopImages))
+	maps.Copy(folders, watchFolders)
+
+	for mountpoint := range loopImages {
+		folders[mountpoint] = 1
+	}
+
 	monitor := &Monitor{
 		mountInfos:        []MountInfo{},
 		mountFDCache:      make(map[[8]byte]*MountFDCache),
 		mountFDCacheMutex: sync.RWMutex{},
 		mountTTL:          10 * time.Second,
-		watchFolders:      watchFolders,
+		watchFolders:      folders,
+		loopImages:        loopImages,
+		eventMask:         opToMask(ops),
 	}
 
 	monitor.setupMountTracking()
@@ -74,11 +91,10 @@ func (m *Monitor) buildFanotifyWatcher() {
 
 func (m *Monitor) addFoldersToWatcher() {
 	for folder := range m.watchFolders {
-		// err := watcher.AddWith(folder, fsnotify.WithOps(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename|fsnotify.Chmod|fsnotify.UnportableOpen))
 		err := m.watcher.Mark(
 			unix.FAN_MARK_ADD|
 				unix.FAN_MARK_FILESYSTEM,
-			unix.FAN_CREATE|unix.FAN_RENAME|unix.FAN_MODIFY|unix.FAN_DELETE|unix.FAN_ACCESS|unix.FAN_ATTRIB|unix.FAN_OPEN,
+			m.eventMask,
 			unix.AT_FDCWD,
 			folder,
 		)
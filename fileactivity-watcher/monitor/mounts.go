@@ -30,8 +30,9 @@ import (
 )
 
 type MountInfo struct {
-	Path string
-	Fsid [8]byte
+	Path  string
+	Fsid  [8]byte
+	Image string // Backing image file for loop-mounted images
 }
 
 type MountFDCache struct {
@@ -66,13 +67,15 @@ func (m *Monitor) setupMountTracking() {
 		}
 
 		m.mountInfos = append(m.mountInfos, MountInfo{
-			Path: folder,
-			Fsid: fsid,
+			Path:  folder,
+			Fsid:  fsid,
+			Image: m.loopImages[folder],
 		})
 
 		log.Info().
 			Str("path", folder).
 			Str("fsid", hex.EncodeToString(fsid[:])).
+			Str("image", m.loopImages[folder]).
 			Msg("Cached mount fsid")
 	}
 }
@@ -152,11 +155,20 @@ func (m *Monitor) getOrOpenMountFD(fsid [8]byte, mountPath string) (int, error)
 }
 
 func (m *Monitor) getMountPath(fsid [8]byte) (string, error) {
+	info, err := m.getMountInfo(fsid)
+	if err != nil {
+		return "", err
+	}
+
+	return info.Path, nil
+}
+
+func (m *Monitor) getMountInfo(fsid [8]byte) (MountInfo, error) {
 	for _, info := range m.mountInfos {
 		if info.Fsid == fsid {
-			return info.Path, nil
+			return info, nil
 		}
 	}
 
-	return "", fmt.Errorf("no mount found for fsid %x", fsid)
+	return MountInfo{}, fmt.Errorf("no mount found for fsid %x", fsid)
 }
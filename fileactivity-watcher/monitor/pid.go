@@ -21,8 +21,6 @@ package monitor
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 // getProcessPath returns the full path to the executable for the given PID.
@@ -38,23 +36,25 @@ func getProcessPath(pid int) string {
 	return target
 }
 
-// getContainerName returns the name of the container given its PID.
-// It checks if the process is running inside a Docker container by inspecting cgroup information.
-func getContainerID(pid int) string {
+// getContainer returns the container the given PID runs in.
+// It reads the cgroup membership of the process and parses the container runtime layout.
+func getContainer(pid int) Container {
 	cgroupPath := fmt.Sprintf("/proc/%d/cgroup", pid)
 
 	data, err := os.ReadFile(cgroupPath)
 	if err != nil {
-		return ""
+		return Container{}
 	}
 
-	lines := strings.SplitSeq(string(data), "\n")
-	for line := range lines {
-		parts := strings.Split(line, ":")
-		if len(parts) == 3 && strings.Contains(parts[2], "docker") {
-			return filepath.Base(parts[2])
-		}
+	return parseCgroup(string(data))
+}
+
+// getProcessUID returns the real user ID of the given PID, or -1 if it is unknown.
+func getProcessUID(pid int) int {
+	status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return -1
 	}
 
-	return ""
+	return parseStatus(status).uid
 }
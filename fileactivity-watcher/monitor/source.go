@@ -0,0 +1,158 @@
+package monitor
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	procRoot = "/proc"
+
+	// Upper bound on the ancestry walk, in case of a loop in a broken /proc
+	maxAncestry = 64
+
+	userScriptsTmpDir = "/tmp/user.scripts/tmpScripts/"
+	userScriptsDir    = "/boot/config/plugins/user.scripts/scripts/"
+	pluginsDir        = "/usr/local/emhttp/plugins/"
+)
+
+type processInfo struct {
+	name    string
+	ppid    int
+	uid     int
+	exe     string
+	cmdline []string
+}
+
+// getSource walks the ancestry of a process and returns a friendly name for the
+// Unraid job that started it: "user-script:<name>" for User Scripts,
+// "plugin:<plugin>" for plugin scripts and "cron" for other scheduled jobs.
+// The closest matching ancestor wins. An empty string is returned if nothing matches.
+func getSource(root string, pid int) string {
+	for range maxAncestry {
+		if pid <= 1 {
+			break
+		}
+
+		info, err := readProcessInfo(root, pid)
+		if err != nil {
+			break
+		}
+
+		if source := classifyProcess(info); source != "" {
+			return source
+		}
+
+		pid = info.ppid
+	}
+
+	return ""
+}
+
+func classifyProcess(info processInfo) string {
+	for _, arg := range append([]string{info.exe}, info.cmdline...) {
+		if name := pathComponentAfter(arg, userScriptsTmpDir); name != "" {
+			return "user-script:" + name
+		}
+
+		if name := pathComponentAfter(arg, userScriptsDir); name != "" {
+			return "user-script:" + name
+		}
+
+		if name := pathComponentAfter(arg, pluginsDir); name != "" {
+			return "plugin:" + name
+		}
+	}
+
+	if info.name == "crond" || info.name == "cron" {
+		return "cron"
+	}
+
+	return ""
+}
+
+// pathComponentAfter returns the path component that follows dir in value,
+// e.g. "foo" for "/tmp/user.scripts/tmpScripts/foo/script".
+func pathComponentAfter(value, dir string) string {
+	_, rest, found := strings.Cut(value, dir)
+	if !found {
+		return ""
+	}
+
+	component, _, _ := strings.Cut(rest, "/")
+
+	return component
+}
+
+func readProcessInfo(root string, pid int) (processInfo, error) {
+	processDir := filepath.Join(root, strconv.Itoa(pid))
+
+	status, err := os.ReadFile(filepath.Join(processDir, "status"))
+	if err != nil {
+		return processInfo{}, fmt.Errorf("error reading process status: %w", err)
+	}
+
+	info := parseStatus(status)
+
+	// Kernel threads have no exe or cmdline, so failures here are not fatal
+	info.exe, _ = os.Readlink(filepath.Join(processDir, "exe"))
+
+	cmdline, err := os.ReadFile(filepath.Join(processDir, "cmdline"))
+	if err == nil {
+		info.cmdline = strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	}
+
+	return info, nil
+}
+
+func parseStatus(status []byte) processInfo {
+	info := processInfo{uid: -1}
+
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "Name":
+			info.name = value
+		case "PPid":
+			info.ppid, _ = strconv.Atoi(value)
+		case "Uid":
+			// Real, effective, saved and filesystem UID; the real UID identifies the user
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				info.uid, _ = strconv.Atoi(fields[0])
+			}
+		}
+	}
+
+	return info
+}
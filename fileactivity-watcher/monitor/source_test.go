@@ -0,0 +1,205 @@
+package monitor
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fakeProcess struct {
+	pid     int
+	ppid    int
+	name    string
+	exe     string
+	cmdline []string
+}
+
+// writeFakeProc builds a minimal /proc tree with status, exe and cmdline entries.
+func writeFakeProc(t *testing.T, processes []fakeProcess) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for _, process := range processes {
+		dir := filepath.Join(root, strconv.Itoa(process.pid))
+
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			t.Fatalf("Failed to create process dir: %v", err)
+		}
+
+		status := fmt.Sprintf(
+			"Name:\t%s\nUmask:\t0022\nState:\tS (sleeping)\nTgid:\t%d\nPid:\t%d\nPPid:\t%d\n",
+			process.name, process.pid, process.pid, process.ppid,
+		)
+
+		err = os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644)
+		if err != nil {
+			t.Fatalf("Failed to write status: %v", err)
+		}
+
+		if process.exe != "" {
+			err = os.Symlink(process.exe, filepath.Join(dir, "exe"))
+			if err != nil {
+				t.Fatalf("Failed to create exe link: %v", err)
+			}
+		}
+
+		cmdline := strings.Join(process.cmdline, "\x00")
+		if cmdline != "" {
+			cmdline += "\x00"
+		}
+
+		err = os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644)
+		if err != nil {
+			t.Fatalf("Failed to write cmdline: %v", err)
+		}
+	}
+
+	return root
+}
+
+func TestGetSource(t *testing.T) {
+	root := writeFakeProc(t, []fakeProcess{
+		{pid: 1, ppid: 0, name: "init", exe: "/sbin/init", cmdline: []string{"init"}},
+		{pid: 100, ppid: 1, name: "crond", exe: "/usr/sbin/crond", cmdline: []string{"crond"}},
+		// cron -> sh -> user script runner -> script -> rsync
+		{pid: 200, ppid: 100, name: "sh", exe: "/bin/bash", cmdline: []string{
+			"/bin/sh", "-c", "/usr/local/emhttp/plugins/user.scripts/startSchedule.php backup",
+		}},
+		{pid: 201, ppid: 200, name: "bash", exe: "/bin/bash", cmdline: []string{
+			"/bin/bash", "/tmp/user.scripts/tmpScripts/nightly backup/script",
+		}},
+		{pid: 202, ppid: 201, name: "rsync", exe: "/usr/bin/rsync", cmdline: []string{
+			"rsync", "-a", "/mnt/disk1/", "/mnt/disks/backup/",
+		}},
+		// cron -> plugin php script
+		{pid: 300, ppid: 100, name: "php", exe: "/usr/bin/php", cmdline: []string{
+			"/usr/bin/php", "-q", "/usr/local/emhttp/plugins/dynamix.file.integrity/scripts/bunker",
+		}},
+		// cron -> plain job
+		{pid: 400, ppid: 100, name: "sh", exe: "/bin/bash", cmdline: []string{
+			"/bin/sh", "-c", "/usr/local/sbin/mover",
+		}},
+		{pid: 401, ppid: 400, name: "mover", exe: "/bin/bash", cmdline: []string{
+			"/bin/bash", "/usr/local/sbin/mover",
+		}},
+		// interactive shell
+		{pid: 500, ppid: 1, name: "sshd", exe: "/usr/sbin/sshd", cmdline: []string{"sshd"}},
+		{pid: 501, ppid: 500, name: "bash", exe: "/bin/bash", cmdline: []string{"-bash"}},
+		{pid: 502, ppid: 501, name: "cp", exe: "/bin/cp", cmdline: []string{"cp", "a", "b"}},
+		// script started from the user scripts storage directory
+		{pid: 600, ppid: 1, name: "bash", exe: "/bin/bash", cmdline: []string{
+			"/bin/bash", "/boot/config/plugins/user.scripts/scripts/cleanup/script",
+		}},
+		// parent has already exited
+		{pid: 700, ppid: 699, name: "touch", exe: "/bin/touch", cmdline: []string{"touch", "x"}},
+	})
+
+	tests := []struct {
+		name     string
+		pid      int
+		expected string
+	}{
+		{"child of user script", 202, "user-script:nightly backup"},
+		{"user script itself", 201, "user-script:nightly backup"},
+		{"user script runner", 200, "plugin:user.scripts"},
+		{"plugin script", 300, "plugin:dynamix.file.integrity"},
+		{"cron job", 401, "cron"},
+		{"crond itself", 100, "cron"},
+		{"interactive shell", 502, ""},
+		{"user script from flash", 600, "user-script:cleanup"},
+		{"orphaned ancestry", 700, ""},
+		{"missing process", 999, ""},
+		{"init", 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := getSource(root, tt.pid)
+			if result != tt.expected {
+				t.Errorf("getSource(%d) = %q, expected %q", tt.pid, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetSource_Loop(t *testing.T) {
+	// A broken tree where two processes are each other's parent must terminate
+	root := writeFakeProc(t, []fakeProcess{
+		{pid: 10, ppid: 11, name: "a", cmdline: []string{"a"}},
+		{pid: 11, ppid: 10, name: "b", cmdline: []string{"b"}},
+	})
+
+	if source := getSource(root, 10); source != "" {
+		t.Errorf("Expected empty source for looping ancestry, got %q", source)
+	}
+}
+
+func TestGetSource_CurrentProcess(t *testing.T) {
+	// Should not fail on the real /proc
+	_ = getSource(procRoot, os.Getpid())
+}
+
+func TestParseStatus(t *testing.T) {
+	info := parseStatus([]byte(
+		"Name:\tcrond\nUmask:\t0022\nPid:\t42\nPPid:\t1\nUid:\t99\t0\t0\t0\n",
+	))
+
+	if info.name != "crond" {
+		t.Errorf("Expected name 'crond', got %q", info.name)
+	}
+
+	if info.ppid != 1 {
+		t.Errorf("Expected ppid 1, got %d", info.ppid)
+	}
+
+	if info.uid != 99 {
+		t.Errorf("Expected real uid 99, got %d", info.uid)
+	}
+
+	if parseStatus([]byte("Name:\tx\n")).uid != -1 {
+		t.Error("Expected unknown uid to be -1")
+	}
+}
+
+func TestPathComponentAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		dir      string
+		expected string
+	}{
+		{"/tmp/user.scripts/tmpScripts/foo/script", userScriptsTmpDir, "foo"},
+		{"/usr/local/emhttp/plugins/dynamix/scripts/x", pluginsDir, "dynamix"},
+		{"/usr/local/emhttp/plugins/", pluginsDir, ""},
+		{"/usr/bin/php", pluginsDir, ""},
+	}
+
+	for _, tt := range tests {
+		result := pathComponentAfter(tt.value, tt.dir)
+		if result != tt.expected {
+			t.Errorf("pathComponentAfter(%q) = %q, expected %q", tt.value, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package profiles
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed *.json
+var files embed.FS //nolint:gochecknoglobals
+
+// Profile is a named, versioned set of rules for the noise of a known workload.
+type Profile struct {
+	Name        string        `json:"name"`
+	Version     int           `json:"version"`
+	Description string        `json:"description"`
+	Rules       []config.Rule `json:"rules"`
+}
+
+// RuleName returns the name a profile rule is logged under, for example "mover@v1/mover".
+func (p Profile) RuleName(index int) string {
+	name := strings.TrimSpace(p.Rules[index].Name)
+	if name == "" {
+		name = "rules[" + strconv.Itoa(index) + "]"
+	}
+
+	return p.Name + "@v" + strconv.Itoa(p.Version) + "/" + name
+}
+
+// Load returns the profiles embedded in the binary, sorted by name.
+func Load() ([]Profile, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading profiles: %w", err)
+	}
+
+	profiles := make([]Profile, 0, len(entries))
+
+	for _, entry := range entries {
+		data, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading profile %s: %w", entry.Name(), err)
+		}
+
+		var profile Profile
+
+		err = json.Unmarshal(data, &profile)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing profile %s: %w", entry.Name(), err)
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	slices.SortFunc(profiles, func(a, b Profile) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return profiles, nil
+}
+
+// Enabled returns the profiles that are enabled in the configuration.
+// Profiles are disabled unless they are listed with a value of true.
+func Enabled(settings map[string]bool) []Profile {
+	profiles, err := Load()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load profiles")
+
+		return nil
+	}
+
+	enabled := []Profile{}
+
+	for _, profile := range profiles {
+		if settings[profile.Name] {
+			enabled = append(enabled, profile)
+		}
+	}
+
+	for name := range settings {
+		known := slices.ContainsFunc(profiles, func(profile Profile) bool {
+			return profile.Name == name
+		})
+		if !known {
+			log.Warn().Str("profile", name).Msg("Unknown profile in configuration")
+		}
+	}
+
+	return enabled
+}
@@ -0,0 +1,87 @@
+package profiles
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"testing"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+)
+
+func TestLoad(t *testing.T) {
+	profiles, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	expected := []string{"cache_dirs", "file_integrity", "mover", "parity_tuning", "shfs"}
+	if len(profiles) != len(expected) {
+		t.Fatalf("Expected %d profiles, got %d", len(expected), len(profiles))
+	}
+
+	for i, profile := range profiles {
+		if profile.Name != expected[i] {
+			t.Errorf("Expected profile %d to be %s, got %s", i, expected[i], profile.Name)
+		}
+
+		if profile.Version < 1 {
+			t.Errorf("Profile %s has no version", profile.Name)
+		}
+
+		if profile.Description == "" {
+			t.Errorf("Profile %s has no description", profile.Name)
+		}
+
+		if len(profile.Rules) == 0 {
+			t.Errorf("Profile %s has no rules", profile.Name)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	enabled := Enabled(map[string]bool{
+		"mover":   true,
+		"shfs":    false,
+		"unknown": true,
+	})
+
+	if len(enabled) != 1 || enabled[0].Name != "mover" {
+		t.Errorf("Expected only the mover profile, got %+v", enabled)
+	}
+
+	if len(Enabled(nil)) != 0 {
+		t.Error("Expected profiles to be disabled by default")
+	}
+}
+
+func TestProfile_RuleName(t *testing.T) {
+	profile := Profile{
+		Name:    "mover",
+		Version: 2,
+		Rules:   []config.Rule{{Name: "mover"}, {}},
+	}
+
+	if name := profile.RuleName(0); name != "mover@v2/mover" {
+		t.Errorf("Expected mover@v2/mover, got %s", name)
+	}
+
+	if name := profile.RuleName(1); name != "mover@v2/rules[1]" {
+		t.Errorf("Expected mover@v2/rules[1], got %s", name)
+	}
+}
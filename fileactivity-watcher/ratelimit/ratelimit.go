@@ -0,0 +1,353 @@
+package ratelimit
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Key selects what a rate limit is counted against.
+type Key string
+
+const (
+	KeyProcess   Key = "process"
+	KeyContainer Key = "container"
+	KeyDisk      Key = "disk"
+)
+
+// Source is written in the source column of summary records.
+const Source = "rate-limit"
+
+// DefaultInterval is the summary interval used when none is configured.
+const DefaultInterval = time.Minute
+
+// Subject describes where an event came from.
+type Subject struct {
+	Process   string
+	Container string
+	Disk      string
+}
+
+func (s Subject) value(key Key) string {
+	switch key {
+	case KeyProcess:
+		return s.Process
+	case KeyContainer:
+		return s.Container
+	case KeyDisk:
+		return s.Disk
+	}
+
+	return ""
+}
+
+// Limiter applies token-bucket rate limits to events. Suppressed events are
+// counted and reported in a summary record at the end of every interval.
+type Limiter struct {
+	limits   []*limit
+	interval time.Duration
+	emit     func(types.Record)
+
+	mu sync.Mutex
+
+	done      chan struct{}
+	finished  chan struct{}
+	closeOnce sync.Once
+}
+
+type limit struct {
+	key     Key
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+	since      time.Time
+	disks      map[string]int
+}
+
+// New creates a limiter for the configured limits that passes summary
+// records to emit. Invalid limits are skipped, and an interval of zero or less
+// falls back to DefaultInterval.
+func New(
+	configLimits []config.RateLimit,
+	interval time.Duration,
+	emit func(types.Record),
+) *Limiter {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	limiter := &Limiter{
+		limits:   make([]*limit, 0, len(configLimits)),
+		interval: interval,
+		emit:     emit,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	for i, configLimit := range configLimits {
+		compiled, err := compileLimit(configLimit)
+		if err != nil {
+			log.Warn().Int("index", i).Err(err).Msg("Failed to add rate limit")
+
+			continue
+		}
+
+		log.Info().
+			Str("key", string(compiled.key)).
+			Float64("rate", compiled.rate).
+			Float64("burst", compiled.burst).
+			Msg("Adding rate limit")
+
+		limiter.limits = append(limiter.limits, compiled)
+	}
+
+	go limiter.run()
+
+	return limiter
+}
+
+func compileLimit(configLimit config.RateLimit) (*limit, error) {
+	key := Key(strings.ToLower(strings.TrimSpace(configLimit.Key)))
+
+	switch key {
+	case KeyProcess, KeyContainer, KeyDisk:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", configLimit.Key)
+	}
+
+	if configLimit.Rate <= 0 {
+		return nil, fmt.Errorf("rate limit for %s must be positive", key)
+	}
+
+	burst := float64(configLimit.Burst)
+	if burst <= 0 {
+		burst = max(configLimit.Rate, 1)
+	}
+
+	return &limit{
+		key:     key,
+		rate:    configLimit.Rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}, nil
+}
+
+func (l *Limiter) run() {
+	defer close(l.finished)
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			l.summarize(now)
+		}
+	}
+}
+
+// Allow reports whether an event is within all limits. An event over any
+// limit is suppressed and counted against the limits it exceeded.
+func (l *Limiter) Allow(subject Subject, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := make([]*bucket, 0, len(l.limits))
+	exceeded := false
+
+	for _, limit := range l.limits {
+		value := subject.value(limit.key)
+		if value == "" {
+			continue
+		}
+
+		bucket := limit.bucket(value, now)
+		if bucket.tokens < 1 {
+			bucket.suppress(subject.Disk, now)
+
+			exceeded = true
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	if exceeded {
+		return false
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return true
+}
+
+// bucket returns the refilled bucket for a key value.
+func (l *limit) bucket(value string, now time.Time) *bucket {
+	current, ok := l.buckets[value]
+	if !ok {
+		current = &bucket{tokens: l.burst, last: now}
+		l.buckets[value] = current
+
+		return current
+	}
+
+	if elapsed := now.Sub(current.last); elapsed > 0 {
+		current.tokens = min(l.burst, current.tokens+elapsed.Seconds()*l.rate)
+		current.last = now
+	}
+
+	return current
+}
+
+func (b *bucket) suppress(disk string, now time.Time) {
+	if b.suppressed == 0 {
+		b.since = now
+		b.disks = map[string]int{}
+	}
+
+	b.suppressed++
+
+	if disk != "" {
+		b.disks[disk]++
+	}
+}
+
+// Close stops the limiter and writes the summaries of the current interval.
+// Nothing is emitted once Close has returned.
+func (l *Limiter) Close() {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		<-l.finished
+		l.summarize(time.Now())
+	})
+}
+
+// summarize emits a summary record for every bucket that suppressed events
+// and forgets the buckets that have been idle long enough to be full again.
+func (l *Limiter) summarize(now time.Time) {
+	l.mu.Lock()
+
+	summaries := []types.Record{}
+
+	for _, limit := range l.limits {
+		for value, bucket := range limit.buckets {
+			if bucket.suppressed > 0 {
+				summaries = append(summaries, limit.summary(value, bucket, now))
+				bucket.suppressed = 0
+				bucket.disks = nil
+
+				continue
+			}
+
+			if now.Sub(bucket.last).Seconds()*limit.rate+bucket.tokens >= limit.burst {
+				delete(limit.buckets, value)
+			}
+		}
+	}
+
+	l.mu.Unlock()
+
+	for _, summary := range summaries {
+		l.emit(summary)
+	}
+}
+
+// summary builds the record for the events a bucket suppressed, with a message
+// such as "rsync: 48,213 events suppressed on disk4 in 60s".
+func (l *limit) summary(value string, bucket *bucket, now time.Time) types.Record {
+	subject := value
+	record := types.Record{
+		Op:        types.OpSummary,
+		Source:    Source + ":" + string(l.key),
+		Count:     bucket.suppressed,
+		FirstSeen: bucket.since,
+		LastSeen:  now,
+	}
+
+	switch l.key {
+	case KeyProcess:
+		subject = filepath.Base(value)
+		record.ProcessPath = value
+	case KeyContainer:
+		record.Container = value
+	case KeyDisk:
+		record.Disk = value
+	}
+
+	message := subject + ": " + formatCount(bucket.suppressed) + " events suppressed"
+
+	if l.key != KeyDisk && len(bucket.disks) > 0 {
+		disks := make([]string, 0, len(bucket.disks))
+		for disk := range bucket.disks {
+			disks = append(disks, disk)
+		}
+
+		slices.Sort(disks)
+
+		message += " on " + strings.Join(disks, ", ")
+
+		if len(disks) == 1 {
+			record.Disk = disks[0]
+		}
+	}
+
+	record.Message = message + " in " + formatDuration(now.Sub(bucket.since))
+
+	return record
+}
+
+// formatCount formats a count with thousands separators.
+func formatCount(count int) string {
+	digits := strconv.Itoa(count)
+
+	var builder strings.Builder
+
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			builder.WriteByte(',')
+		}
+
+		builder.WriteRune(digit)
+	}
+
+	return builder.String()
+}
+
+func formatDuration(duration time.Duration) string {
+	return strconv.Itoa(int(max(duration.Round(time.Second), time.Second).Seconds())) + "s"
+}
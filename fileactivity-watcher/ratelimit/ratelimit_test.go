@@ -0,0 +1,276 @@
+package ratelimit
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	records []types.Record
+}
+
+func (r *recorder) emit(record types.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, record)
+}
+
+func (r *recorder) get() []types.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]types.Record{}, r.records...)
+}
+
+func newTestLimiter(limits []config.RateLimit) (*Limiter, *recorder) {
+	output := &recorder{}
+
+	return New(limits, time.Hour, output.emit), output
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	limiter, _ := newTestLimiter([]config.RateLimit{{Key: "process", Rate: 10, Burst: 5}})
+	defer limiter.Close()
+
+	start := time.Now()
+	subject := Subject{Process: "/usr/bin/rsync", Disk: "disk4"}
+
+	allowed := 0
+
+	for range 100 {
+		if limiter.Allow(subject, start) {
+			allowed++
+		}
+	}
+
+	if allowed != 5 {
+		t.Errorf("Expected the burst of 5 events to be allowed, got %d", allowed)
+	}
+
+	// Half a second refills 5 tokens at 10 events per second
+	allowed = 0
+
+	for range 100 {
+		if limiter.Allow(subject, start.Add(500*time.Millisecond)) {
+			allowed++
+		}
+	}
+
+	if allowed != 5 {
+		t.Errorf("Expected 5 events to be allowed after refill, got %d", allowed)
+	}
+
+	// Other processes have their own bucket
+	if !limiter.Allow(Subject{Process: "/usr/bin/cp"}, start) {
+		t.Error("Expected another process to be allowed")
+	}
+}
+
+func TestLimiter_AllLimitsApply(t *testing.T) {
+	limiter, _ := newTestLimiter([]config.RateLimit{
+		{Key: "process", Rate: 100},
+		{Key: "disk", Rate: 1, Burst: 2},
+	})
+	defer limiter.Close()
+
+	now := time.Now()
+
+	for i, process := range []string{"/usr/bin/a", "/usr/bin/b"} {
+		if !limiter.Allow(Subject{Process: process, Disk: "disk1"}, now) {
+			t.Errorf("Expected event %d to be allowed", i)
+		}
+	}
+
+	if limiter.Allow(Subject{Process: "/usr/bin/c", Disk: "disk1"}, now) {
+		t.Error("Expected the disk limit to suppress the third event")
+	}
+
+	if !limiter.Allow(Subject{Process: "/usr/bin/c", Disk: "disk2"}, now) {
+		t.Error("Expected events on another disk to be allowed")
+	}
+}
+
+func TestLimiter_EmptyValueIsNotLimited(t *testing.T) {
+	limiter, _ := newTestLimiter([]config.RateLimit{{Key: "container", Rate: 1, Burst: 1}})
+	defer limiter.Close()
+
+	now := time.Now()
+
+	for range 10 {
+		if !limiter.Allow(Subject{Process: "/usr/bin/rsync"}, now) {
+			t.Fatal("Expected host processes not to be limited by container")
+		}
+	}
+}
+
+func TestLimiter_Summary(t *testing.T) {
+	limiter, output := newTestLimiter([]config.RateLimit{{Key: "process", Rate: 1, Burst: 1}})
+	defer limiter.Close()
+
+	start := time.Now()
+	subject := Subject{Process: "/usr/bin/rsync", Disk: "disk4"}
+
+	for range 48214 {
+		limiter.Allow(subject, start)
+	}
+
+	limiter.summarize(start.Add(60 * time.Second))
+
+	records := output.get()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 summary record, got %d", len(records))
+	}
+
+	summary := records[0]
+
+	expected := "rsync: 48,213 events suppressed on disk4 in 60s"
+	if summary.Message != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary.Message)
+	}
+
+	if summary.Op != types.OpSummary || summary.File != "" || summary.Disk != "disk4" {
+		t.Errorf("Expected a summary record without a path on disk4, got %+v", summary)
+	}
+
+	if summary.Count != 48213 || summary.ProcessPath != "/usr/bin/rsync" {
+		t.Errorf("Unexpected summary record %+v", summary)
+	}
+
+	if summary.Source != "rate-limit:process" {
+		t.Errorf("Expected rate-limit:process source, got %q", summary.Source)
+	}
+
+	// Counts are reset once summarized
+	limiter.summarize(start.Add(120 * time.Second))
+
+	if len(output.get()) != 1 {
+		t.Error("Expected no summary for an interval without suppressed events")
+	}
+}
+
+func TestLimiter_CloseWritesSummaries(t *testing.T) {
+	limiter, output := newTestLimiter([]config.RateLimit{{Key: "disk", Rate: 1, Burst: 1}})
+
+	now := time.Now()
+	limiter.Allow(Subject{Disk: "disk2"}, now)
+	limiter.Allow(Subject{Disk: "disk2"}, now)
+	limiter.Close()
+
+	records := output.get()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 summary record at close, got %d", len(records))
+	}
+
+	if records[0].Message != "disk2: 1 events suppressed in 1s" || records[0].Disk != "disk2" {
+		t.Errorf("Unexpected summary %+v", records[0])
+	}
+}
+
+func TestLimiter_NoEmitAfterClose(t *testing.T) {
+	output := &recorder{}
+	limits := []config.RateLimit{{Key: "disk", Rate: 1, Burst: 1}}
+	limiter := New(limits, time.Millisecond, output.emit)
+
+	for range 100 {
+		limiter.Allow(Subject{Disk: "disk2"}, time.Now())
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	limiter.Close()
+
+	emitted := len(output.get())
+
+	// Suppressed events after Close are never summarized
+	limiter.Allow(Subject{Disk: "disk2"}, time.Now())
+	limiter.Allow(Subject{Disk: "disk2"}, time.Now())
+	time.Sleep(10 * time.Millisecond)
+
+	if len(output.get()) != emitted {
+		t.Errorf("Expected no summaries after Close, got %d more", len(output.get())-emitted)
+	}
+}
+
+func TestLimiter_ForgetsIdleBuckets(t *testing.T) {
+	limiter, _ := newTestLimiter([]config.RateLimit{{Key: "process", Rate: 1, Burst: 5}})
+	defer limiter.Close()
+
+	now := time.Now()
+	limiter.Allow(Subject{Process: "/usr/bin/cp"}, now)
+
+	limiter.summarize(now.Add(10 * time.Second))
+
+	if len(limiter.limits[0].buckets) != 0 {
+		t.Error("Expected the refilled bucket to be forgotten")
+	}
+}
+
+func TestNew_InvalidLimits(t *testing.T) {
+	limiter, _ := newTestLimiter([]config.RateLimit{
+		{Key: "inode", Rate: 10},
+		{Key: "process", Rate: 0},
+		{Key: " Disk ", Rate: 0.5},
+	})
+	defer limiter.Close()
+
+	if len(limiter.limits) != 1 {
+		t.Fatalf("Expected 1 valid limit, got %d", len(limiter.limits))
+	}
+
+	if limiter.limits[0].burst != 1 {
+		t.Errorf("Expected burst of at least 1, got %v", limiter.limits[0].burst)
+	}
+}
+
+func TestNew_DefaultInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		limits := []config.RateLimit{{Key: "process", Rate: 1}}
+		limiter := New(limits, interval, func(types.Record) {})
+
+		if limiter.interval != DefaultInterval {
+			t.Errorf("New(%v) interval = %v, expected %v",
+				interval, limiter.interval, DefaultInterval)
+		}
+
+		limiter.Close()
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int]string{
+		0:       "0",
+		999:     "999",
+		1000:    "1,000",
+		48213:   "48,213",
+		1234567: "1,234,567",
+	}
+
+	for count, expected := range tests {
+		if result := formatCount(count); result != expected {
+			t.Errorf("formatCount(%d) = %q, expected %q", count, result, expected)
+		}
+	}
+}
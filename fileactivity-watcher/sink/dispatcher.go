@@ -0,0 +1,215 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/filter"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// DefaultQueueSize is the number of records queued for a sink when its
+// configuration does not set one.
+const DefaultQueueSize = 1024
+
+// Dispatcher fans activity records out to the sinks. Each sink has its own
+// queue and goroutine, so a slow sink only ever drops its own records and never
+// holds up the event listener or the other sinks.
+type Dispatcher struct {
+	outputs []*output
+}
+
+// output is a sink together with its queue, filter and counters.
+type output struct {
+	name        string
+	sink        Sink
+	rules       *filter.RuleSet
+	flaggedOnly bool
+	queue       chan Event
+	done        chan struct{}
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// Stats are the counters of a sink.
+type Stats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"`
+	Queued    int    `json:"queued"`
+}
+
+// New creates the configured sinks and starts their queues. If a sink cannot be
+// created, the ones created so far are closed again.
+func New(appConfig config.ActivityConfig) (*Dispatcher, error) {
+	dispatcher := &Dispatcher{}
+
+	for i, sinkConfig := range appConfig.Sinks {
+		name := strings.TrimSpace(sinkConfig.Name)
+		if name == "" {
+			name = sinkConfig.Type + "[" + strconv.Itoa(i) + "]"
+		}
+
+		sink, err := newSink(appConfig, sinkConfig)
+		if err != nil {
+			closeErr := dispatcher.Close()
+			if closeErr != nil {
+				log.Error().Err(closeErr).Msg("Error closing sinks")
+			}
+
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+
+		dispatcher.add(name, sink, sinkConfig)
+
+		log.Info().
+			Str("sink", name).
+			Str("type", sinkConfig.Type).
+			Int("rules", len(sinkConfig.Rules)).
+			Bool("flagged_only", sinkConfig.FlaggedOnly).
+			Msg("Started sink")
+	}
+
+	return dispatcher, nil
+}
+
+// add starts the queue of a sink.
+func (d *Dispatcher) add(name string, sink Sink, sinkConfig config.Sink) {
+	queueSize := sinkConfig.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	out := &output{
+		name:        name,
+		sink:        sink,
+		rules:       filter.NewRuleSet("sinks."+name, sinkConfig.Rules, sinkConfig.DefaultAction),
+		flaggedOnly: sinkConfig.FlaggedOnly,
+		queue:       make(chan Event, queueSize),
+		done:        make(chan struct{}),
+	}
+
+	d.outputs = append(d.outputs, out)
+
+	go out.run()
+}
+
+// Dispatch queues a record for every sink whose filter accepts it. It never
+// blocks: when the queue of a sink is full, the record is dropped for that sink.
+func (d *Dispatcher) Dispatch(record types.Record) {
+	for _, out := range d.outputs {
+		if out.flaggedOnly && record.Flag == "" {
+			continue
+		}
+
+		match := out.rules.Match(record)
+		if match.Action == filter.ActionDrop {
+			continue
+		}
+
+		select {
+		case out.queue <- Event{Record: record, Rule: match.Rule}:
+		default:
+			dropped := out.dropped.Add(1)
+
+			// Log the first drop and then ever less often
+			if bits.OnesCount64(dropped) == 1 {
+				log.Warn().
+					Str("sink", out.name).
+					Uint64("dropped", dropped).
+					Msg("Sink queue is full, dropping records")
+			}
+		}
+	}
+}
+
+func (o *output) run() {
+	defer close(o.done)
+
+	for event := range o.queue {
+		err := o.sink.Write(event)
+		if err != nil {
+			failed := o.failed.Add(1)
+
+			if bits.OnesCount64(failed) == 1 {
+				log.Error().
+					Str("sink", o.name).
+					Uint64("failed", failed).
+					Err(err).
+					Msg("Error writing to sink")
+			}
+
+			continue
+		}
+
+		o.delivered.Add(1)
+	}
+}
+
+// Stats returns the counters of every sink.
+func (d *Dispatcher) Stats() []Stats {
+	stats := make([]Stats, 0, len(d.outputs))
+
+	for _, out := range d.outputs {
+		stats = append(stats, Stats{
+			Name:      out.name,
+			Delivered: out.delivered.Load(),
+			Dropped:   out.dropped.Load(),
+			Failed:    out.failed.Load(),
+			Queued:    len(out.queue),
+		})
+	}
+
+	return stats
+}
+
+// Close delivers the queued records and closes the sinks. Dispatch must not be
+// called during or after Close.
+func (d *Dispatcher) Close() error {
+	for _, out := range d.outputs {
+		close(out.queue)
+	}
+
+	var errs []error
+
+	for _, out := range d.outputs {
+		<-out.done
+
+		err := out.sink.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", out.name, err))
+		}
+	}
+
+	d.outputs = nil
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,269 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// memorySink collects the events written to it. When block is set, writes
+// wait until it is closed.
+type memorySink struct {
+	mu     sync.Mutex
+	events []Event
+	block  chan struct{}
+	fail   bool
+	closed bool
+}
+
+func (s *memorySink) Write(event Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	if s.fail {
+		return errors.New("write failed")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
+
+func testRecord(file string, flag string) types.Record {
+	timestamp := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+
+	return types.Record{
+		Op:        types.OpWrite,
+		File:      file,
+		PID:       1234,
+		Flag:      flag,
+		Count:     1,
+		FirstSeen: timestamp,
+		LastSeen:  timestamp,
+	}
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	all := &memorySink{}
+	flagged := &memorySink{}
+	photos := &memorySink{}
+
+	dispatcher := &Dispatcher{}
+	dispatcher.add("all", all, config.Sink{})
+	dispatcher.add("flagged", flagged, config.Sink{FlaggedOnly: true})
+	dispatcher.add("photos", photos, config.Sink{
+		Rules:         []config.Rule{{Name: "photos", Share: "Photos", Action: "keep"}},
+		DefaultAction: "drop",
+	})
+
+	dispatcher.Dispatch(testRecord("/mnt/disk1/Photos/a.jpg", ""))
+	dispatcher.Dispatch(testRecord("/mnt/disk1/Media/a.mkv", "important"))
+	dispatcher.Dispatch(testRecord("/mnt/disk1/Media/b.mkv", ""))
+
+	err := dispatcher.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	if len(all.events) != 3 || !all.closed {
+		t.Errorf("Expected 3 events and a closed sink, got %d, %v", len(all.events), all.closed)
+	}
+
+	if len(flagged.events) != 1 || flagged.events[0].Record.Flag != "important" {
+		t.Errorf("Expected only the flagged record, got %+v", flagged.events)
+	}
+
+	if len(photos.events) != 1 || photos.events[0].Rule != "photos" {
+		t.Errorf("Expected only the Photos record matched by its rule, got %+v", photos.events)
+	}
+}
+
+func TestDispatcher_SlowSink(t *testing.T) {
+	slow := &memorySink{block: make(chan struct{})}
+	fast := &memorySink{}
+
+	dispatcher := &Dispatcher{}
+	dispatcher.add("slow", slow, config.Sink{QueueSize: 2})
+	dispatcher.add("fast", fast, config.Sink{QueueSize: 100})
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 10 {
+			dispatcher.Dispatch(testRecord("/mnt/disk1/a", ""))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispatch blocked on a slow sink")
+	}
+
+	close(slow.block)
+
+	err := dispatcher.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	if len(fast.events) != 10 {
+		t.Errorf("Expected the fast sink to get all 10 records, got %d", len(fast.events))
+	}
+
+	// The slow sink holds one record while blocked and queues two more
+	if len(slow.events) < 2 || len(slow.events) > 3 {
+		t.Errorf("Expected the slow sink to get 2 or 3 records, got %d", len(slow.events))
+	}
+}
+
+func TestDispatcher_Stats(t *testing.T) {
+	failing := &memorySink{fail: true}
+	slow := &memorySink{block: make(chan struct{})}
+
+	dispatcher := &Dispatcher{}
+	dispatcher.add("failing", failing, config.Sink{})
+	dispatcher.add("slow", slow, config.Sink{QueueSize: 1})
+
+	for range 5 {
+		dispatcher.Dispatch(testRecord("/mnt/disk1/a", ""))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for dispatcher.Stats()[0].Failed < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := dispatcher.Stats()
+
+	if stats[0].Name != "failing" || stats[0].Failed != 5 || stats[0].Delivered != 0 {
+		t.Errorf("Expected 5 failed writes, got %+v", stats[0])
+	}
+
+	if stats[1].Dropped < 3 || stats[1].Delivered != 0 {
+		t.Errorf("Expected the slow sink to drop records, got %+v", stats[1])
+	}
+
+	close(slow.block)
+
+	err := dispatcher.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+}
+
+func TestNew_FileSink(t *testing.T) {
+	tmpDir := t.TempDir()
+	activityPath := filepath.Join(tmpDir, "data.log")
+	flaggedPath := filepath.Join(tmpDir, "flagged.jsonl")
+
+	appConfig := config.ActivityConfig{
+		ActivityPath: activityPath,
+		OutputFormat: "csv",
+		MaxRecords:   1000,
+		Sinks: []config.Sink{
+			{Type: "file"},
+			{
+				Name:        "flagged",
+				Type:        "file",
+				FlaggedOnly: true,
+				Options:     []byte(`{"path": "` + flaggedPath + `", "format": "jsonl"}`),
+			},
+		},
+	}
+
+	dispatcher, err := New(appConfig)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	dispatcher.Dispatch(testRecord("/mnt/disk1/a", ""))
+	dispatcher.Dispatch(testRecord("/mnt/disk1/b", "important"))
+
+	stats := dispatcher.Stats()
+	if stats[0].Name != "file[0]" || stats[1].Name != "flagged" {
+		t.Errorf("Unexpected sink names: %+v", stats)
+	}
+
+	err = dispatcher.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	activity, _ := os.ReadFile(activityPath)
+	if lines := strings.Count(string(activity), "\n"); lines != 2 {
+		t.Errorf("Expected 2 records in the activity file, got %d", lines)
+	}
+
+	flagged, _ := os.ReadFile(flaggedPath)
+	if !strings.HasPrefix(string(flagged), "{") || strings.Count(string(flagged), "\n") != 1 {
+		t.Errorf("Expected the flagged record as JSON, got %q", flagged)
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name string
+		sink config.Sink
+	}{
+		{"unknown type", config.Sink{Type: "carrier-pigeon"}},
+		{"invalid options", config.Sink{Type: "file", Options: []byte(`[]`)}},
+		{"invalid format", config.Sink{Type: "file", Options: []byte(`{"format": "xml"}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig := config.ActivityConfig{
+				ActivityPath: filepath.Join(tmpDir, "data.log"),
+				Sinks:        []config.Sink{{Type: "file"}, tt.sink},
+			}
+
+			_, err := New(appConfig)
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
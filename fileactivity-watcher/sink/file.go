@@ -0,0 +1,100 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/writer"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// FileOptions are the options of a file sink. Unset options default to the
+// activity file settings; rotation and flushing always follow those.
+type FileOptions struct {
+	Path       string `json:"path,omitempty"`
+	Format     string `json:"format,omitempty"`
+	MaxRecords int    `json:"max_records,omitempty"`
+}
+
+// fileSink writes records to an activity file.
+type fileSink struct {
+	writer *writer.Writer
+}
+
+func newFileSink(appConfig config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	options := FileOptions{
+		Path:       appConfig.ActivityPath,
+		Format:     appConfig.OutputFormat,
+		MaxRecords: appConfig.MaxRecords,
+	}
+
+	if len(sinkConfig.Options) > 0 {
+		err := json.Unmarshal(sinkConfig.Options, &options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file sink options: %w", err)
+		}
+	}
+
+	format, err := writer.ParseFormat(options.Format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output format: %w", err)
+	}
+
+	period, err := writer.ParsePeriod(appConfig.RotatePeriod)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotation period: %w", err)
+	}
+
+	rotation := writer.Rotation{
+		Period:       period,
+		MaxFileBytes: int64(appConfig.MaxFileMB) * bytesPerMB,
+		Generations:  appConfig.Generations,
+		MaxAge:       time.Duration(appConfig.RetentionDays) * 24 * time.Hour,
+		Compress:     appConfig.CompressRotated,
+		MaxBytes:     int64(appConfig.MaxRotatedMB) * bytesPerMB,
+	}
+
+	flush := writer.FlushPolicy{
+		Records:  appConfig.FlushRecords,
+		Interval: time.Duration(appConfig.FlushIntervalMS) * time.Millisecond,
+		Sync:     time.Duration(appConfig.FsyncIntervalMS) * time.Millisecond,
+	}
+
+	activityFile, err := writer.New(options.Path, options.MaxRecords, format, rotation, flush)
+	if err != nil {
+		return nil, fmt.Errorf("error creating activity file writer: %w", err)
+	}
+
+	return &fileSink{writer: activityFile}, nil
+}
+
+func (s *fileSink) Write(event Event) error {
+	return s.writer.Write(event.Record)
+}
+
+func (s *fileSink) Close() error {
+	s.writer.Close()
+
+	return nil
+}
@@ -0,0 +1,662 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/filter"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/version"
+)
+
+const (
+	mqttStatsInterval = time.Minute
+	mqttMinRetry      = time.Second
+	mqttMaxRetry      = time.Minute
+	mqttOnline        = "online"
+	mqttOffline       = "offline"
+)
+
+var errMQTTDisconnected = errors.New("not connected to the MQTT broker")
+
+// MQTTOptions are the options of an MQTT sink. Broker is a URL such as
+// "tcp://host:1883" or, for TLS, "ssl://host:8883"; CAFile adds a CA to trust.
+//
+// Records are published as JSON to EventTopic (TopicPrefix + "/events") unless
+// NoEvents is set. Every StatsIntervalSeconds, the activity of each disk is
+// published, retained, to TopicPrefix + "/disk/<disk>". The availability of the
+// watcher is retained in TopicPrefix + "/status" as "online" or "offline", with
+// "offline" as the last will. With Discovery set, Home Assistant discovery
+// configs for per-disk sensors are published under DiscoveryPrefix.
+type MQTTOptions struct {
+	Broker               string `json:"broker"`
+	ClientID             string `json:"client_id,omitempty"`
+	Username             string `json:"username,omitempty"`
+	Password             string `json:"password,omitempty"`
+	CAFile               string `json:"ca_file,omitempty"`
+	InsecureSkipVerify   bool   `json:"insecure_skip_verify,omitempty"`
+	QoS                  int    `json:"qos,omitempty"`
+	KeepAliveSeconds     int    `json:"keep_alive_seconds,omitempty"`
+	TopicPrefix          string `json:"topic_prefix,omitempty"`
+	EventTopic           string `json:"event_topic,omitempty"`
+	NoEvents             bool   `json:"no_events,omitempty"`
+	StatsIntervalSeconds int    `json:"stats_interval_seconds,omitempty"`
+	Discovery            bool   `json:"discovery,omitempty"`
+	DiscoveryPrefix      string `json:"discovery_prefix,omitempty"`
+}
+
+// DiskState is the activity of a disk as it is published.
+type DiskState struct {
+	Disk              string `json:"disk"`
+	LastActivity      string `json:"last_activity,omitempty"`
+	LastOp            string `json:"last_op,omitempty"`
+	LastPath          string `json:"last_path,omitempty"`
+	LastProcess       string `json:"last_process,omitempty"`
+	LastDeleteProcess string `json:"last_delete_process,omitempty"`
+	EventsPerHour     uint64 `json:"events_per_hour"`
+	ReadsPerHour      uint64 `json:"reads_per_hour"`
+	WritesPerHour     uint64 `json:"writes_per_hour"`
+	CreatesPerHour    uint64 `json:"creates_per_hour"`
+	DeletesPerHour    uint64 `json:"deletes_per_hour"`
+}
+
+// diskSensors are the Home Assistant sensors of each disk.
+var diskSensors = []struct { //nolint:gochecknoglobals
+	key         string
+	name        string
+	deviceClass string
+	unit        string
+	icon        string
+}{
+	{"last_activity", "last activity", "timestamp", "", ""},
+	{"last_process", "last process", "", "", "mdi:application"},
+	{"last_delete_process", "last deleting process", "", "", "mdi:delete"},
+	{"last_path", "last file", "", "", "mdi:file"},
+	{"events_per_hour", "events per hour", "", "events/h", "mdi:chart-line"},
+	{"reads_per_hour", "files read per hour", "", "files/h", "mdi:file-eye"},
+	{"writes_per_hour", "files written per hour", "", "files/h", "mdi:file-edit"},
+	{"creates_per_hour", "files created per hour", "", "files/h", "mdi:file-plus"},
+	{"deletes_per_hour", "files deleted per hour", "", "files/h", "mdi:file-remove"},
+}
+
+// hourCounter counts over the last hour in one-minute buckets.
+type hourCounter struct {
+	counts  [60]uint64
+	minutes [60]int64
+}
+
+func (c *hourCounter) add(moment time.Time) {
+	minute := moment.Unix() / 60
+	bucket := minute % 60
+
+	if c.minutes[bucket] != minute {
+		c.minutes[bucket] = minute
+		c.counts[bucket] = 0
+	}
+
+	c.counts[bucket]++
+}
+
+func (c *hourCounter) sum(now time.Time) uint64 {
+	minute := now.Unix() / 60
+
+	var total uint64
+
+	for bucket, count := range c.counts {
+		if minute-c.minutes[bucket] < 60 {
+			total += count
+		}
+	}
+
+	return total
+}
+
+// diskActivity is what the sink knows about the activity of a disk.
+type diskActivity struct {
+	lastActivity      time.Time
+	lastOp            types.Op
+	lastPath          string
+	lastProcess       string
+	lastDeleteProcess string
+	events            hourCounter
+	ops               map[types.Op]*hourCounter
+}
+
+type mqttSink struct {
+	options     mqtt.Options
+	qos         byte
+	prefix      string
+	eventTopic  string
+	statusTopic string
+	discovery   string
+	nodeID      string
+	host        string
+
+	// mu guards the disk activity
+	mu    sync.Mutex
+	disks map[string]*diskActivity
+
+	// connMu guards the connection and serializes publishing
+	connMu     sync.Mutex
+	client     *mqtt.Client
+	discovered map[string]bool
+	retryAt    time.Time
+	retryDelay time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newMQTTSink(_ config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	var options MQTTOptions
+
+	if len(sinkConfig.Options) > 0 {
+		err := json.Unmarshal(sinkConfig.Options, &options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MQTT sink options: %w", err)
+		}
+	}
+
+	sink, err := mqttFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sink.connect()
+	if err != nil {
+		// The broker may start after the watcher, so keep trying
+		log.Warn().
+			Str("broker", options.Broker).
+			Err(err).
+			Msg("MQTT broker not reachable, will retry")
+	}
+
+	interval := time.Duration(options.StatsIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = mqttStatsInterval
+	}
+
+	go sink.loop(interval)
+
+	return sink, nil
+}
+
+func mqttFromOptions(options MQTTOptions) (*mqttSink, error) {
+	if options.QoS < 0 || options.QoS > 2 {
+		return nil, fmt.Errorf("invalid MQTT QoS %d", options.QoS)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unraid"
+	}
+
+	sink := &mqttSink{
+		qos:        byte(options.QoS),
+		prefix:     strings.TrimSuffix(options.TopicPrefix, "/"),
+		eventTopic: options.EventTopic,
+		host:       host,
+		disks:      map[string]*diskActivity{},
+		discovered: map[string]bool{},
+		retryDelay: mqttMinRetry,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if sink.prefix == "" {
+		sink.prefix = "fileactivity"
+	}
+
+	if sink.eventTopic == "" && !options.NoEvents {
+		sink.eventTopic = sink.prefix + "/events"
+	}
+
+	if options.Discovery {
+		sink.discovery = strings.TrimSuffix(options.DiscoveryPrefix, "/")
+		if sink.discovery == "" {
+			sink.discovery = "homeassistant"
+		}
+	}
+
+	sink.statusTopic = sink.prefix + "/status"
+
+	clientID := options.ClientID
+	if clientID == "" {
+		clientID = "fileactivity-" + host
+	}
+
+	sink.nodeID = unsafeNameChars.ReplaceAllString(clientID, "_")
+
+	sink.options, err = mqttClientOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	sink.options.ClientID = clientID
+	sink.options.Will = &mqtt.Message{
+		Topic:   sink.statusTopic,
+		Payload: []byte(mqttOffline),
+		QoS:     sink.qos,
+		Retain:  true,
+	}
+
+	return sink, nil
+}
+
+// mqttClientOptions returns the connection options for the broker URL.
+func mqttClientOptions(options MQTTOptions) (mqtt.Options, error) {
+	clientOptions := mqtt.Options{
+		Username:     options.Username,
+		Password:     options.Password,
+		KeepAlive:    time.Duration(options.KeepAliveSeconds) * time.Second,
+		CleanSession: true,
+	}
+
+	broker := options.Broker
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+
+	brokerURL, err := url.Parse(broker)
+	if err != nil || brokerURL.Hostname() == "" {
+		return clientOptions, fmt.Errorf("invalid MQTT broker %q", options.Broker)
+	}
+
+	port := brokerURL.Port()
+
+	switch brokerURL.Scheme {
+	case "tcp", "mqtt":
+		if port == "" {
+			port = "1883"
+		}
+	case "ssl", "tls", "mqtts":
+		if port == "" {
+			port = "8883"
+		}
+
+		clientOptions.TLS = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			ServerName:         brokerURL.Hostname(),
+			InsecureSkipVerify: options.InsecureSkipVerify,
+		}
+
+		if options.CAFile != "" {
+			pem, err := os.ReadFile(options.CAFile)
+			if err != nil {
+				return clientOptions, fmt.Errorf("error reading MQTT CA file: %w", err)
+			}
+
+			clientOptions.TLS.RootCAs = x509.NewCertPool()
+			if !clientOptions.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return clientOptions, fmt.Errorf("no certificates in %s", options.CAFile)
+			}
+		}
+	default:
+		return clientOptions, fmt.Errorf("unknown MQTT broker scheme %q", brokerURL.Scheme)
+	}
+
+	clientOptions.Address = net.JoinHostPort(brokerURL.Hostname(), port)
+
+	return clientOptions, nil
+}
+
+// connect dials the broker, unless a failed attempt is too recent, and
+// announces that the watcher is online. connMu must be held or the sink not yet
+// in use.
+func (s *mqttSink) connect() error {
+	now := time.Now()
+	if now.Before(s.retryAt) {
+		return errMQTTDisconnected
+	}
+
+	client, err := mqtt.Dial(s.options)
+	if err == nil {
+		err = client.Publish(s.statusMessage(mqttOnline))
+		if err != nil {
+			_ = client.Close()
+		}
+	}
+
+	if err != nil {
+		s.retryAt = now.Add(s.retryDelay)
+		s.retryDelay = min(s.retryDelay*2, mqttMaxRetry)
+
+		return fmt.Errorf("error connecting to MQTT broker: %w", err)
+	}
+
+	s.client = client
+	s.retryAt = time.Time{}
+	s.retryDelay = mqttMinRetry
+
+	// The broker may have lost the retained configs
+	s.discovered = map[string]bool{}
+
+	return nil
+}
+
+func (s *mqttSink) statusMessage(status string) mqtt.Message {
+	return mqtt.Message{Topic: s.statusTopic, Payload: []byte(status), QoS: s.qos, Retain: true}
+}
+
+// publish sends messages, reconnecting first if the connection was lost.
+// connMu must be held.
+func (s *mqttSink) publish(messages ...mqtt.Message) error {
+	if s.client != nil {
+		select {
+		case <-s.client.Done():
+			s.client = nil
+		default:
+		}
+	}
+
+	if s.client == nil {
+		err := s.connect()
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("broker", s.options.Address).Msg("Connected to MQTT broker")
+	}
+
+	for _, message := range messages {
+		err := s.client.Publish(message)
+		if err != nil {
+			_ = s.client.Close()
+			s.client = nil
+
+			return fmt.Errorf("error publishing to %s: %w", message.Topic, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *mqttSink) Write(event Event) error {
+	record := event.Record
+	disk := filter.EventDisk(types.Event{File: record.File})
+
+	if disk != "" {
+		s.track(disk, record)
+	}
+
+	if s.eventTopic == "" {
+		return nil
+	}
+
+	payload, err := marshalJSON(event.JSON())
+	if err != nil {
+		return err
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	return s.publish(mqtt.Message{Topic: s.eventTopic, Payload: payload, QoS: s.qos})
+}
+
+// track adds a record to the activity of its disk.
+func (s *mqttSink) track(disk string, record types.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity, ok := s.disks[disk]
+	if !ok {
+		activity = &diskActivity{ops: map[types.Op]*hourCounter{}}
+		s.disks[disk] = activity
+	}
+
+	moment := record.LastSeen
+	if moment.IsZero() {
+		moment = time.Now()
+	}
+
+	activity.lastActivity = moment
+	activity.lastOp = record.Op
+	activity.lastPath = record.File
+	activity.lastProcess = recordActor(record)
+	activity.events.add(moment)
+
+	if record.Op.Has(types.OpRemove) {
+		activity.lastDeleteProcess = activity.lastProcess
+	}
+
+	for _, op := range []types.Op{types.OpRead, types.OpWrite, types.OpCreate, types.OpRemove} {
+		if !record.Op.Has(op) {
+			continue
+		}
+
+		counter, ok := activity.ops[op]
+		if !ok {
+			counter = &hourCounter{}
+			activity.ops[op] = counter
+		}
+
+		counter.add(moment)
+	}
+}
+
+// states returns the activity of every disk.
+func (s *mqttSink) states(now time.Time) []DiskState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]DiskState, 0, len(s.disks))
+
+	for disk, activity := range s.disks {
+		state := DiskState{
+			Disk:              disk,
+			LastActivity:      activity.lastActivity.Format(time.RFC3339),
+			LastOp:            strings.Join(activity.lastOp.Names(), ","),
+			LastPath:          activity.lastPath,
+			LastProcess:       activity.lastProcess,
+			LastDeleteProcess: activity.lastDeleteProcess,
+			EventsPerHour:     activity.events.sum(now),
+		}
+
+		count := func(op types.Op) uint64 {
+			if counter, ok := activity.ops[op]; ok {
+				return counter.sum(now)
+			}
+
+			return 0
+		}
+
+		state.ReadsPerHour = count(types.OpRead)
+		state.WritesPerHour = count(types.OpWrite)
+		state.CreatesPerHour = count(types.OpCreate)
+		state.DeletesPerHour = count(types.OpRemove)
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+func (s *mqttSink) diskTopic(disk string) string {
+	return s.prefix + "/disk/" + unsafeNameChars.ReplaceAllString(disk, "_")
+}
+
+// discoveryMessages returns the Home Assistant discovery configs of a disk.
+func (s *mqttSink) discoveryMessages(disk string) ([]mqtt.Message, error) {
+	objectID := unsafeNameChars.ReplaceAllString(disk, "_")
+	topic := s.discovery + "/sensor/" + s.nodeID + "/" + objectID + "_"
+	messages := make([]mqtt.Message, 0, len(diskSensors))
+
+	for _, sensor := range diskSensors {
+		sensorConfig := map[string]any{
+			"name":               disk + " " + sensor.name,
+			"unique_id":          s.nodeID + "_" + objectID + "_" + sensor.key,
+			"state_topic":        s.diskTopic(disk),
+			"value_template":     "{{ value_json." + sensor.key + " }}",
+			"availability_topic": s.statusTopic,
+			"device": map[string]any{
+				"identifiers":  []string{s.nodeID},
+				"name":         "File Activity (" + s.host + ")",
+				"manufacturer": "Unraid File Activity",
+				"sw_version":   version.Tag,
+			},
+		}
+
+		if sensor.deviceClass != "" {
+			sensorConfig["device_class"] = sensor.deviceClass
+		}
+
+		if sensor.unit != "" {
+			sensorConfig["unit_of_measurement"] = sensor.unit
+			sensorConfig["state_class"] = "measurement"
+		}
+
+		if sensor.icon != "" {
+			sensorConfig["icon"] = sensor.icon
+		}
+
+		payload, err := marshalJSON(sensorConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, mqtt.Message{
+			Topic:   topic + sensor.key + "/config",
+			Payload: payload,
+			QoS:     s.qos,
+			Retain:  true,
+		})
+	}
+
+	return messages, nil
+}
+
+// publishStates publishes the activity of every disk, preceded by the discovery
+// configs of disks that are new to the broker. Without any disks it still
+// reconnects, so a lost broker is noticed either way.
+func (s *mqttSink) publishStates(now time.Time) error {
+	states := s.states(now)
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if len(states) == 0 {
+		return s.publish()
+	}
+
+	for _, state := range states {
+		payload, err := marshalJSON(state)
+		if err != nil {
+			return err
+		}
+
+		messages := []mqtt.Message{}
+
+		if s.discovery != "" && !s.discovered[state.Disk] {
+			messages, err = s.discoveryMessages(state.Disk)
+			if err != nil {
+				return err
+			}
+		}
+
+		messages = append(messages, mqtt.Message{
+			Topic:   s.diskTopic(state.Disk),
+			Payload: payload,
+			QoS:     s.qos,
+			Retain:  true,
+		})
+
+		err = s.publish(messages...)
+		if err != nil {
+			return err
+		}
+
+		if s.discovery != "" {
+			s.discovered[state.Disk] = true
+		}
+	}
+
+	return nil
+}
+
+func (s *mqttSink) loop(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Only the start and the end of an outage are worth a warning
+	failing := false
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			err := s.publishStates(now)
+
+			switch {
+			case err != nil && !failing:
+				log.Warn().
+					Str("broker", s.options.Address).
+					Err(err).
+					Msg("Error publishing disk activity, will retry")
+
+				failing = true
+			case err != nil:
+				log.Debug().Err(err).Msg("Error publishing disk activity")
+			case failing:
+				log.Info().Str("broker", s.options.Address).Msg("Publishing disk activity again")
+
+				failing = false
+			}
+		}
+	}
+}
+
+// Close publishes the final disk activity, marks the watcher offline and
+// disconnects.
+func (s *mqttSink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	err := s.publishStates(time.Now())
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.client == nil {
+		return err
+	}
+
+	err = errors.Join(err, s.client.Publish(s.statusMessage(mqttOffline)), s.client.Close())
+	s.client = nil
+
+	return err
+}
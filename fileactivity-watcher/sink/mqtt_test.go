@@ -0,0 +1,363 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/mqtt/mqtttest"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func newTestBroker(t *testing.T) *mqtttest.Broker {
+	t.Helper()
+
+	broker, err := mqtttest.NewBroker(nil)
+	if err != nil {
+		t.Skipf("Cannot listen: %v", err)
+	}
+
+	t.Cleanup(broker.Close)
+
+	return broker
+}
+
+func testMQTTSink(t *testing.T, options MQTTOptions) *mqttSink {
+	t.Helper()
+
+	if options.ClientID == "" {
+		options.ClientID = "tower"
+	}
+
+	sink, err := mqttFromOptions(options)
+	if err != nil {
+		t.Fatalf("mqttFromOptions() error: %v", err)
+	}
+
+	go sink.loop(time.Hour)
+
+	return sink
+}
+
+func receiveMessage(t *testing.T, broker *mqtttest.Broker) mqtt.Message {
+	t.Helper()
+
+	select {
+	case message := <-broker.Messages():
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+
+	return mqtt.Message{}
+}
+
+func TestMQTTSink_Events(t *testing.T) {
+	broker := newTestBroker(t)
+
+	sink := testMQTTSink(t, MQTTOptions{
+		Broker:   "tcp://" + broker.Addr(),
+		Username: "user",
+		Password: "secret",
+		QoS:      1,
+	})
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk3/Media/a.mkv", ""), Rule: "media"})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	status := receiveMessage(t, broker)
+	if status.Topic != "fileactivity/status" || string(status.Payload) != "online" ||
+		!status.Retain {
+		t.Errorf("Expected a retained online status, got %+v", status)
+	}
+
+	message := receiveMessage(t, broker)
+	if message.Topic != "fileactivity/events" || message.QoS != 1 || message.Retain {
+		t.Errorf("Unexpected event message %+v", message)
+	}
+
+	var event EventJSON
+
+	err = json.Unmarshal(message.Payload, &event)
+	if err != nil || event.Path != "/mnt/disk3/Media/a.mkv" || event.Rule != "media" {
+		t.Errorf("Unexpected event %s: %v", message.Payload, err)
+	}
+
+	connects := broker.Connects()
+	if len(connects) != 1 || connects[0].Username != "user" || connects[0].Password != "secret" ||
+		connects[0].Will == nil || string(connects[0].Will.Payload) != "offline" {
+		t.Errorf("Unexpected connect %+v", connects)
+	}
+
+	err = sink.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	// Closing publishes the disk activity and marks the watcher offline
+	var offline bool
+
+	for !offline {
+		message = receiveMessage(t, broker)
+		offline = message.Topic == "fileactivity/status" && string(message.Payload) == "offline"
+	}
+}
+
+func TestMQTTSink_Discovery(t *testing.T) {
+	broker := newTestBroker(t)
+
+	sink := testMQTTSink(t, MQTTOptions{
+		Broker:      broker.Addr(),
+		TopicPrefix: "unraid/files",
+		NoEvents:    true,
+		Discovery:   true,
+	})
+	defer sink.Close()
+
+	write := testRecord("/mnt/disk3/Media/a.mkv", "")
+	write.ProcessPath = "/usr/bin/rsync"
+
+	remove := testRecord("/mnt/disk3/Media/b.mkv", "")
+	remove.Op = types.OpRemove
+	remove.Container = "sonarr"
+
+	now := time.Now()
+	write.LastSeen = now
+	remove.LastSeen = now
+
+	for _, record := range []types.Record{write, remove} {
+		err := sink.Write(Event{Record: record})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	err := sink.publishStates(now)
+	if err != nil {
+		t.Fatalf("publishStates() error: %v", err)
+	}
+
+	if status := receiveMessage(t, broker); status.Topic != "unraid/files/status" {
+		t.Errorf("Expected the status first, got %s", status.Topic)
+	}
+
+	for _, sensor := range diskSensors {
+		message := receiveMessage(t, broker)
+
+		expected := "homeassistant/sensor/tower/disk3_" + sensor.key + "/config"
+		if message.Topic != expected || !message.Retain {
+			t.Errorf("Discovery topic %s, expected %s", message.Topic, expected)
+		}
+
+		var sensorConfig map[string]any
+
+		err = json.Unmarshal(message.Payload, &sensorConfig)
+		if err != nil || sensorConfig["state_topic"] != "unraid/files/disk/disk3" ||
+			sensorConfig["availability_topic"] != "unraid/files/status" {
+			t.Errorf("Unexpected discovery config %s: %v", message.Payload, err)
+		}
+	}
+
+	message := receiveMessage(t, broker)
+	if message.Topic != "unraid/files/disk/disk3" || !message.Retain {
+		t.Fatalf("Unexpected state message %+v", message)
+	}
+
+	var state DiskState
+
+	err = json.Unmarshal(message.Payload, &state)
+	if err != nil {
+		t.Fatalf("Invalid state %s: %v", message.Payload, err)
+	}
+
+	if state.WritesPerHour != 1 || state.DeletesPerHour != 1 || state.EventsPerHour != 2 ||
+		state.LastProcess != "sonarr" || state.LastDeleteProcess != "sonarr" ||
+		state.LastOp != "remove" || state.LastActivity != now.Format(time.RFC3339) {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	// Discovery configs are only published once per connection
+	err = sink.publishStates(now)
+	if err != nil {
+		t.Fatalf("publishStates() error: %v", err)
+	}
+
+	if message := receiveMessage(t, broker); message.Topic != "unraid/files/disk/disk3" {
+		t.Errorf("Expected only the state, got %s", message.Topic)
+	}
+}
+
+func TestMQTTSink_Reconnect(t *testing.T) {
+	broker := newTestBroker(t)
+
+	sink := testMQTTSink(t, MQTTOptions{Broker: broker.Addr(), QoS: 1})
+	defer sink.Close()
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	receiveMessage(t, broker)
+	receiveMessage(t, broker)
+
+	broker.Disconnect()
+
+	// The first write may still go to the dropped connection
+	deadline := time.Now().Add(5 * time.Second)
+
+	for len(broker.Connects()) < 2 && time.Now().Before(deadline) {
+		_ = sink.Write(Event{Record: testRecord("/mnt/disk1/b", "")})
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(broker.Connects()) < 2 {
+		t.Fatal("Sink did not reconnect")
+	}
+}
+
+func TestMQTTSink_ReconnectWithoutActivity(t *testing.T) {
+	broker := newTestBroker(t)
+
+	sink := testMQTTSink(t, MQTTOptions{Broker: broker.Addr()})
+	defer sink.Close()
+
+	// Nothing to publish still reconnects, so the end of an outage is noticed
+	deadline := time.Now().Add(5 * time.Second)
+
+	for len(broker.Connects()) < 1 && time.Now().Before(deadline) {
+		_ = sink.publishStates(time.Now())
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	broker.Disconnect()
+
+	for len(broker.Connects()) < 2 && time.Now().Before(deadline) {
+		_ = sink.publishStates(time.Now())
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(broker.Connects()) < 2 {
+		t.Fatal("Sink did not reconnect")
+	}
+}
+
+func TestMQTTSink_TLS(t *testing.T) {
+	// Borrow the test certificate of httptest
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker, err := mqtttest.NewBroker(server.TLS)
+	if err != nil {
+		t.Skipf("Cannot listen: %v", err)
+	}
+	defer broker.Close()
+
+	sink := testMQTTSink(t, MQTTOptions{Broker: "ssl://" + broker.Addr(), CAFile: caFile})
+	defer sink.Close()
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	receiveMessage(t, broker)
+
+	if message := receiveMessage(t, broker); message.Topic != "fileactivity/events" {
+		t.Errorf("Unexpected message %+v", message)
+	}
+}
+
+func TestMQTTSink_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{"missing broker", `{}`},
+		{"scheme", `{"broker": "http://localhost"}`},
+		{"qos", `{"broker": "localhost", "qos": 3}`},
+		{"ca file", `{"broker": "ssl://localhost", "ca_file": "/nonexistent/ca.pem"}`},
+		{"json", `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newMQTTSink(config.ActivityConfig{}, config.Sink{
+				Type:    "mqtt",
+				Options: []byte(tt.options),
+			})
+			if err == nil || !strings.Contains(strings.ToLower(err.Error()), "mqtt") {
+				t.Errorf("Expected an MQTT options error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHourCounter(t *testing.T) {
+	var counter hourCounter
+
+	start := time.Date(2026, 10, 18, 12, 0, 30, 0, time.UTC)
+
+	counter.add(start)
+	counter.add(start.Add(30 * time.Minute))
+	counter.add(start.Add(30 * time.Minute))
+
+	if total := counter.sum(start.Add(45 * time.Minute)); total != 3 {
+		t.Errorf("sum() after 45m = %d, expected 3", total)
+	}
+
+	if total := counter.sum(start.Add(61 * time.Minute)); total != 2 {
+		t.Errorf("sum() after 61m = %d, expected 2", total)
+	}
+
+	// A bucket is reused an hour later
+	counter.add(start.Add(60 * time.Minute))
+
+	if total := counter.sum(start.Add(61 * time.Minute)); total != 3 {
+		t.Errorf("sum() after reuse = %d, expected 3", total)
+	}
+
+	if total := counter.sum(start.Add(3 * time.Hour)); total != 0 {
+		t.Errorf("sum() after 3h = %d, expected 0", total)
+	}
+}
@@ -0,0 +1,455 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/filter"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+const (
+	notifyScript    = "/usr/local/emhttp/webGui/scripts/notify"
+	notifyTimeout   = 30 * time.Second
+	notifyAggregate = 10 * time.Second
+	notifyRateLimit = 5 * time.Minute
+	notifyTick      = time.Second
+	notifySamples   = 5
+)
+
+// NotifyOptions are the options of a notify sink, which raises Unraid
+// notifications for the records it receives. Records are grouped by rule,
+// operation and share; a group is sent as one notification AggregateSeconds
+// after its first record, such as "37 files deleted from Photos in 10s". Once a
+// group was sent, records for the same alert are collected for RateLimitSeconds
+// before the next notification.
+// Importance is "normal", "warning" (the default) or "alert", and can be set
+// per rule name in RuleImportance. The rule is the sink rule that matched the
+// record, or else the filter rule that flagged it.
+// A notify sink without rules of its own only notifies about the records a
+// filter rule flagged, rather than about every file access. Give it rules to
+// choose the records yourself.
+type NotifyOptions struct {
+	Script           string            `json:"script,omitempty"`
+	Event            string            `json:"event,omitempty"`
+	Importance       string            `json:"importance,omitempty"`
+	RuleImportance   map[string]string `json:"rule_importance,omitempty"`
+	AggregateSeconds int               `json:"aggregate_seconds,omitempty"`
+	RateLimitSeconds int               `json:"rate_limit_seconds,omitempty"`
+}
+
+// notifyVerbs describe the operations in alerts, most notable first. A record
+// with several operations is reported under the first of them.
+var notifyVerbs = []struct { //nolint:gochecknoglobals
+	op   types.Op
+	verb string
+	from string
+}{
+	{types.OpRemove, "deleted", "from"},
+	{types.OpRename, "renamed", "in"},
+	{types.OpCreate, "created", "in"},
+	{types.OpWrite, "modified", "in"},
+	{types.OpChmod, "changed", "in"},
+	{types.OpRead, "read", "in"},
+	{types.OpOpen, "opened", "in"},
+}
+
+// alertKey identifies identical alerts.
+type alertKey struct {
+	rule     string
+	op       types.Op
+	location string
+}
+
+// alertGroup collects the records of an alert until it is sent.
+type alertGroup struct {
+	key     alertKey
+	count   int
+	files   []string
+	actors  []string
+	first   time.Time
+	last    time.Time
+	sendAt  time.Time
+	pending bool
+}
+
+// notification is an alert as it is passed to the notify script.
+type notification struct {
+	subject     string
+	description string
+	importance  string
+	message     string
+}
+
+type notifySink struct {
+	script         string
+	event          string
+	importance     string
+	ruleImportance map[string]string
+	aggregate      time.Duration
+	rateLimit      time.Duration
+	flaggedOnly    bool
+	run            func(args []string) error
+
+	mu     sync.Mutex
+	groups map[alertKey]*alertGroup
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newNotifySink(_ config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	var options NotifyOptions
+
+	if len(sinkConfig.Options) > 0 {
+		err := json.Unmarshal(sinkConfig.Options, &options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notify sink options: %w", err)
+		}
+	}
+
+	sink, err := notifyFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	sink.flaggedOnly = len(sinkConfig.Rules) == 0
+
+	go sink.loop(notifyTick)
+
+	return sink, nil
+}
+
+func notifyFromOptions(options NotifyOptions) (*notifySink, error) {
+	sink := &notifySink{
+		script:         options.Script,
+		event:          options.Event,
+		importance:     "warning",
+		ruleImportance: map[string]string{},
+		aggregate:      time.Duration(options.AggregateSeconds) * time.Second,
+		rateLimit:      time.Duration(options.RateLimitSeconds) * time.Second,
+		groups:         map[alertKey]*alertGroup{},
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	sink.run = sink.exec
+
+	if sink.script == "" {
+		sink.script = notifyScript
+	}
+
+	if sink.event == "" {
+		sink.event = "File Activity"
+	}
+
+	if sink.aggregate <= 0 {
+		sink.aggregate = notifyAggregate
+	}
+
+	if sink.rateLimit <= 0 {
+		sink.rateLimit = notifyRateLimit
+	}
+
+	if options.Importance != "" {
+		importance, err := parseImportance(options.Importance)
+		if err != nil {
+			return nil, err
+		}
+
+		sink.importance = importance
+	}
+
+	for rule, value := range options.RuleImportance {
+		importance, err := parseImportance(value)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule, err)
+		}
+
+		sink.ruleImportance[rule] = importance
+	}
+
+	return sink, nil
+}
+
+func parseImportance(importance string) (string, error) {
+	importance = strings.ToLower(strings.TrimSpace(importance))
+
+	switch importance {
+	case "normal", "warning", "alert":
+		return importance, nil
+	}
+
+	return "", fmt.Errorf("unknown notification importance %q", importance)
+}
+
+// alertRule returns the rule an alert is raised for.
+func alertRule(event Event) string {
+	if event.Rule != "" {
+		return event.Rule
+	}
+
+	if event.Record.Flag != "" {
+		return event.Record.Flag
+	}
+
+	return "File activity"
+}
+
+// alertLocation returns where a record happened: its share, else its disk,
+// else its directory.
+func alertLocation(record types.Record) string {
+	event := types.Event{File: record.File}
+
+	if share := filter.EventShare(event); share != "" {
+		return share
+	}
+
+	if disk := filter.EventDisk(event); disk != "" {
+		return disk
+	}
+
+	// Summary records have no path, but may name their disk
+	if record.File == "" {
+		return record.Disk
+	}
+
+	return filepath.Dir(record.File)
+}
+
+// alertOp returns the operation a record is reported under.
+func alertOp(op types.Op) types.Op {
+	for _, entry := range notifyVerbs {
+		if op.Has(entry.op) {
+			return entry.op
+		}
+	}
+
+	return op
+}
+
+func (s *notifySink) Write(event Event) error {
+	record := event.Record
+	if s.flaggedOnly && record.Flag == "" {
+		return nil
+	}
+
+	key := alertKey{
+		rule:     alertRule(event),
+		op:       alertOp(record.Op),
+		location: alertLocation(record),
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, ok := s.groups[key]
+	if !ok {
+		group = &alertGroup{key: key}
+		s.groups[key] = group
+	}
+
+	if !group.pending {
+		group.pending = true
+		group.count = 0
+		group.files = nil
+		group.actors = nil
+		group.first = now
+		// A rate-limited alert keeps collecting until the limit has passed
+		group.sendAt = maxTime(group.sendAt, now.Add(s.aggregate))
+	}
+
+	group.count++
+	group.last = now
+
+	sample := record.File
+	if sample == "" {
+		sample = record.Message
+	}
+
+	if len(group.files) < notifySamples {
+		group.files = append(group.files, sample)
+	}
+
+	actor := recordActor(record)
+	if actor != "" && len(group.actors) < notifySamples && !slices.Contains(group.actors, actor) {
+		group.actors = append(group.actors, actor)
+	}
+
+	return nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
+
+// due returns the notifications of the groups whose time has come, or of all
+// pending groups when flushing. Groups past their rate limit are forgotten.
+func (s *notifySink) due(now time.Time, flush bool) []notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notifications []notification
+
+	for key, group := range s.groups {
+		if !group.pending {
+			if now.After(group.sendAt) {
+				delete(s.groups, key)
+			}
+
+			continue
+		}
+
+		if !flush && now.Before(group.sendAt) {
+			continue
+		}
+
+		notifications = append(notifications, s.notification(group))
+		group.pending = false
+		group.sendAt = now.Add(s.rateLimit)
+	}
+
+	return notifications
+}
+
+// notification describes a group of records, e.g. "37 files deleted from Photos
+// in 10s".
+func (s *notifySink) notification(group *alertGroup) notification {
+	verb, from := group.key.op.String(), "in"
+
+	for _, entry := range notifyVerbs {
+		if entry.op == group.key.op {
+			verb, from = entry.verb, entry.from
+		}
+	}
+
+	var description string
+
+	if group.count == 1 {
+		description = group.files[0] + " " + verb
+	} else {
+		elapsed := max(group.last.Sub(group.first).Round(time.Second), time.Second)
+		description = fmt.Sprintf("%d files %s %s %s in %s",
+			group.count, verb, from, group.key.location, elapsed)
+	}
+
+	if len(group.actors) > 0 {
+		description += " by " + strings.Join(group.actors, ", ")
+	}
+
+	message := strings.Join(group.files, "\n")
+	if more := group.count - len(group.files); more > 0 {
+		message += fmt.Sprintf("\nand %d more", more)
+	}
+
+	importance, ok := s.ruleImportance[group.key.rule]
+	if !ok {
+		importance = s.importance
+	}
+
+	return notification{
+		subject:     s.event + ": " + group.key.rule,
+		description: description,
+		importance:  importance,
+		message:     message,
+	}
+}
+
+func (s *notifySink) send(notifications []notification) {
+	for _, alert := range notifications {
+		err := s.run([]string{
+			"-e", s.event,
+			"-s", alert.subject,
+			"-d", alert.description,
+			"-i", alert.importance,
+			"-m", alert.message,
+		})
+		if err != nil {
+			log.Error().
+				Str("subject", alert.subject).
+				Str("description", alert.description).
+				Err(err).
+				Msg("Error sending notification")
+
+			continue
+		}
+
+		log.Debug().
+			Str("subject", alert.subject).
+			Str("description", alert.description).
+			Msg("Sent notification")
+	}
+}
+
+func (s *notifySink) exec(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, s.script, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", s.script, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// loop sends the notifications that are due.
+func (s *notifySink) loop(tick time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.send(s.due(now, false))
+		}
+	}
+}
+
+// Close sends the pending alerts right away.
+func (s *notifySink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.send(s.due(time.Now(), true))
+
+	return nil
+}
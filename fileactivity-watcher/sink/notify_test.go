@@ -0,0 +1,266 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// notifyRecorder collects the arguments the notify script is run with.
+type notifyRecorder struct {
+	mu    sync.Mutex
+	calls []map[string]string
+}
+
+func (r *notifyRecorder) run(args []string) error {
+	call := map[string]string{}
+	for i := 0; i+1 < len(args); i += 2 {
+		call[args[i]] = args[i+1]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+
+	return nil
+}
+
+func (r *notifyRecorder) sent() []map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]map[string]string{}, r.calls...)
+}
+
+func testNotifySink(
+	t *testing.T,
+	options NotifyOptions,
+	aggregate, rateLimit time.Duration,
+) (*notifySink, *notifyRecorder) {
+	t.Helper()
+
+	sink, err := notifyFromOptions(options)
+	if err != nil {
+		t.Fatalf("notifyFromOptions() error: %v", err)
+	}
+
+	recorder := &notifyRecorder{}
+	sink.run = recorder.run
+	sink.aggregate = aggregate
+	sink.rateLimit = rateLimit
+
+	go sink.loop(time.Millisecond)
+
+	return sink, recorder
+}
+
+func removal(file string, process string) Event {
+	record := testRecord(file, "photo removals")
+	record.Op = types.OpRemove
+	record.ProcessPath = process
+
+	return Event{Record: record}
+}
+
+func TestNotifySink_Burst(t *testing.T) {
+	sink, recorder := testNotifySink(t, NotifyOptions{
+		RuleImportance: map[string]string{"photo removals": "alert"},
+	}, 50*time.Millisecond, time.Hour)
+
+	for range 37 {
+		err := sink.Write(removal("/mnt/user/Photos/a.jpg", "/usr/bin/rm"))
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	waitFor(t, "the notification", func() bool { return len(recorder.sent()) == 1 })
+
+	call := recorder.sent()[0]
+
+	if call["-s"] != "File Activity: photo removals" || call["-i"] != "alert" {
+		t.Errorf("Unexpected subject or importance: %v", call)
+	}
+
+	if !strings.HasPrefix(call["-d"], "37 files deleted from Photos in ") ||
+		!strings.HasSuffix(call["-d"], " by rm") {
+		t.Errorf("Unexpected description %q", call["-d"])
+	}
+
+	if !strings.HasSuffix(call["-m"], "\nand 32 more") {
+		t.Errorf("Unexpected message %q", call["-m"])
+	}
+
+	sink.Close()
+}
+
+func TestNotifySink_RateLimit(t *testing.T) {
+	sink, recorder := testNotifySink(t, NotifyOptions{}, 10*time.Millisecond, 200*time.Millisecond)
+
+	err := sink.Write(removal("/mnt/user/Photos/a.jpg", ""))
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	waitFor(t, "the first notification", func() bool { return len(recorder.sent()) == 1 })
+
+	if description := recorder.sent()[0]["-d"]; description != "/mnt/user/Photos/a.jpg deleted" {
+		t.Errorf("Unexpected description %q", description)
+	}
+
+	// Identical alerts wait for the rate limit, other alerts don't
+	sent := time.Now()
+
+	for _, file := range []string{"/mnt/user/Photos/b.jpg", "/mnt/user/Photos/c.jpg"} {
+		err = sink.Write(removal(file, ""))
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	err = sink.Write(removal("/mnt/user/Media/a.mkv", ""))
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	waitFor(t, "the other alert", func() bool { return len(recorder.sent()) == 2 })
+
+	if description := recorder.sent()[1]["-d"]; !strings.HasPrefix(description, "/mnt/user/Media") {
+		t.Errorf("Expected the Media alert first, got %q", description)
+	}
+
+	waitFor(t, "the rate-limited alert", func() bool { return len(recorder.sent()) == 3 })
+
+	if elapsed := time.Since(sent); elapsed < 150*time.Millisecond {
+		t.Errorf("Rate-limited alert sent after %v", elapsed)
+	}
+
+	if description := recorder.sent()[2]["-d"]; !strings.HasPrefix(description, "2 files deleted") {
+		t.Errorf("Unexpected description %q", description)
+	}
+
+	sink.Close()
+}
+
+func TestNotifySink_Close(t *testing.T) {
+	sink, recorder := testNotifySink(t, NotifyOptions{Importance: "normal"}, time.Hour, time.Hour)
+
+	record := testRecord("/mnt/disk1/Media/a.mkv", "")
+	record.Op = types.OpOpen | types.OpWrite
+	record.Container = "sonarr"
+
+	err := sink.Write(Event{Record: record, Rule: "media writes"})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	sink.Close()
+
+	calls := recorder.sent()
+	if len(calls) != 1 {
+		t.Fatalf("Expected the pending alert to be sent on close, got %d", len(calls))
+	}
+
+	expected := map[string]string{
+		"-e": "File Activity",
+		"-s": "File Activity: media writes",
+		"-d": "/mnt/disk1/Media/a.mkv modified by sonarr",
+		"-i": "normal",
+		"-m": "/mnt/disk1/Media/a.mkv",
+	}
+
+	for flag, value := range expected {
+		if calls[0][flag] != value {
+			t.Errorf("%s = %q, expected %q", flag, calls[0][flag], value)
+		}
+	}
+}
+
+func TestNewNotifySink_FlaggedOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []config.Rule
+		expected int
+	}{
+		{"without rules", nil, 1},
+		{"with rules", []config.Rule{
+			{Name: "media", Path: "^/mnt/user/Media/", Action: "keep"},
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := newNotifySink(config.ActivityConfig{}, config.Sink{
+				Type:  "notify",
+				Rules: tt.rules,
+			})
+			if err != nil {
+				t.Fatalf("newNotifySink() error: %v", err)
+			}
+
+			sink, ok := created.(*notifySink)
+			if !ok {
+				t.Fatalf("Unexpected sink %T", created)
+			}
+
+			recorder := &notifyRecorder{}
+			sink.run = recorder.run
+
+			// Only the flagged record is reported by a sink without rules
+			for _, event := range []Event{
+				{Record: testRecord("/mnt/user/Media/a.mkv", "")},
+				removal("/mnt/user/Photos/a.jpg", ""),
+			} {
+				err = sink.Write(event)
+				if err != nil {
+					t.Fatalf("Write() error: %v", err)
+				}
+			}
+
+			sink.Close()
+
+			if calls := recorder.sent(); len(calls) != tt.expected {
+				t.Errorf("Expected %d notifications, got %d", tt.expected, len(calls))
+			}
+		})
+	}
+}
+
+func TestNewNotifySink_Invalid(t *testing.T) {
+	for _, options := range []string{
+		`{"importance": "urgent"}`,
+		`{"rule_importance": {"photos": "loud"}}`,
+		`[]`,
+	} {
+		_, err := newNotifySink(config.ActivityConfig{}, config.Sink{
+			Type:    "notify",
+			Options: []byte(options),
+		})
+		if err == nil {
+			t.Errorf("Expected an error for %s", options)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// Event is an activity record delivered to a sink.
+type Event struct {
+	Record types.Record
+	// Rule is the name of the sink rule that matched the record, if any.
+	Rule string
+}
+
+// EventJSON is an event as sinks send it as JSON: the record as it appears in
+// the JSON Lines activity log, together with the sink rule.
+type EventJSON struct {
+	types.JSONRecord
+
+	// Rule is the name of the sink rule that matched the record, if any.
+	Rule string `json:"rule,omitempty"`
+}
+
+// JSON returns the event as sinks send it as JSON.
+func (e Event) JSON() EventJSON {
+	return EventJSON{JSONRecord: e.Record.JSON(), Rule: e.Rule}
+}
+
+// Sink is an output for activity records. Write is only called from the
+// goroutine of the sink's queue, so implementations need not be safe for
+// concurrent use.
+type Sink interface {
+	Write(event Event) error
+	Close() error
+}
+
+// Factory creates a sink from its configuration.
+type Factory func(appConfig config.ActivityConfig, sinkConfig config.Sink) (Sink, error)
+
+// factories are the sink implementations, by configuration type.
+var factories = map[string]Factory{ //nolint:gochecknoglobals
+	"file":    newFileSink,
+	"mqtt":    newMQTTSink,
+	"notify":  newNotifySink,
+	"syslog":  newSyslogSink,
+	"webhook": newWebhookSink,
+}
+
+// newSink creates a sink of the configured type.
+func newSink(appConfig config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	factory, ok := factories[strings.ToLower(strings.TrimSpace(sinkConfig.Type))]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q", sinkConfig.Type)
+	}
+
+	return factory(appConfig, sinkConfig)
+}
+
+// recordActor returns who caused a record: its container, else the name of its
+// process.
+func recordActor(record types.Record) string {
+	if record.Container != "" {
+		return record.Container
+	}
+
+	if record.ProcessPath != "" {
+		return filepath.Base(record.ProcessPath)
+	}
+
+	return ""
+}
+
+// marshalJSON encodes a value without escaping HTML, as the activity log does.
+func marshalJSON(value any) ([]byte, error) {
+	var body bytes.Buffer
+
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	return bytes.TrimSuffix(body.Bytes(), []byte("\n")), nil
+}
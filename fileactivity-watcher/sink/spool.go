@@ -0,0 +1,224 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const spoolSuffix = ".json"
+
+// spool is a directory of payloads waiting to be delivered, oldest first. It
+// survives restarts and drops the oldest payloads when it grows beyond its cap.
+// It is not safe for concurrent use.
+type spool struct {
+	dir      string
+	maxBytes int64
+	files    []spoolFile
+	size     int64
+	seq      uint64
+	dropped  uint64
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// openSpool opens a spool directory, picking up the payloads left by an
+// earlier run.
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading spool directory: %w", err)
+	}
+
+	queue := &spool{dir: dir, maxBytes: maxBytes}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Left over from a write that was interrupted
+		if strings.HasSuffix(name, ".tmp") {
+			_ = os.Remove(filepath.Join(dir, name))
+
+			continue
+		}
+
+		if entry.IsDir() || !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		queue.files = append(queue.files, spoolFile{name: name, size: info.Size()})
+		queue.size += info.Size()
+	}
+
+	// Names start with a fixed-width timestamp, so they sort by age
+	slices.SortFunc(queue.files, func(a, b spoolFile) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	queue.trim()
+
+	return queue, nil
+}
+
+func (s *spool) len() int {
+	return len(s.files)
+}
+
+// push adds a payload to the end of the spool.
+func (s *spool) push(payload []byte) error {
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolSuffix)
+
+	err := s.write(name, payload)
+	if err != nil {
+		return err
+	}
+
+	s.files = append(s.files, spoolFile{name: name, size: int64(len(payload))})
+	s.size += int64(len(payload))
+	s.trim()
+
+	return nil
+}
+
+// pushFront adds a payload to the front of the spool, for a payload that was
+// taken out to be sent but could not be delivered.
+func (s *spool) pushFront(payload []byte) error {
+	if len(s.files) == 0 {
+		return s.push(payload)
+	}
+
+	// Sort just before the oldest payload
+	timestamp, err := strconv.ParseInt(strings.SplitN(s.files[0].name, "-", 2)[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected spool file %s: %w", s.files[0].name, err)
+	}
+
+	name := fmt.Sprintf("%020d-%06d%s", timestamp-1, 0, spoolSuffix)
+
+	err = s.write(name, payload)
+	if err != nil {
+		return err
+	}
+
+	s.files = slices.Insert(s.files, 0, spoolFile{name: name, size: int64(len(payload))})
+	s.size += int64(len(payload))
+	s.trim()
+
+	return nil
+}
+
+// write stores a payload under name, so that it never appears half written.
+func (s *spool) write(name string, payload []byte) error {
+	path := filepath.Join(s.dir, name)
+
+	err := os.WriteFile(path+".tmp", payload, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing spool file: %w", err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+
+		return fmt.Errorf("error writing spool file: %w", err)
+	}
+
+	return nil
+}
+
+// peek returns the oldest payload and its file name. Payloads that can no
+// longer be read are skipped.
+func (s *spool) peek() ([]byte, string, bool) {
+	for len(s.files) > 0 {
+		payload, err := os.ReadFile(filepath.Join(s.dir, s.files[0].name))
+		if err == nil {
+			return payload, s.files[0].name, true
+		}
+
+		log.Warn().Str("file", s.files[0].name).Err(err).Msg("Skipping unreadable spool file")
+		s.pop()
+	}
+
+	return nil, "", false
+}
+
+// remove pops the oldest payload if it is still the named one; it may have been
+// dropped to make room since it was peeked.
+func (s *spool) remove(name string) {
+	if len(s.files) > 0 && s.files[0].name == name {
+		s.pop()
+	}
+}
+
+// pop removes the oldest payload.
+func (s *spool) pop() {
+	if len(s.files) == 0 {
+		return
+	}
+
+	err := os.Remove(filepath.Join(s.dir, s.files[0].name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn().Str("file", s.files[0].name).Err(err).Msg("Error removing spool file")
+	}
+
+	s.size -= s.files[0].size
+	s.files = s.files[1:]
+}
+
+// trim drops the oldest payloads until the spool is within its cap.
+func (s *spool) trim() {
+	dropped := 0
+
+	for s.maxBytes > 0 && s.size > s.maxBytes && len(s.files) > 0 {
+		s.pop()
+		dropped++
+	}
+
+	if dropped > 0 {
+		s.dropped += uint64(dropped)
+
+		log.Warn().
+			Str("dir", s.dir).
+			Int("dropped", dropped).
+			Uint64("total_dropped", s.dropped).
+			Msg("Spool is full, dropped the oldest payloads")
+	}
+}
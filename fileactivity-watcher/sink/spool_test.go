@@ -0,0 +1,81 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+
+	queue, err := openSpool(dir, 10)
+	if err != nil {
+		t.Fatalf("openSpool() error: %v", err)
+	}
+
+	for _, payload := range []string{"aaaa", "bbbb", "cccc"} {
+		err = queue.push([]byte(payload))
+		if err != nil {
+			t.Fatalf("push() error: %v", err)
+		}
+	}
+
+	// The oldest payload is dropped to stay within 10 bytes
+	if queue.len() != 2 || queue.dropped != 1 {
+		t.Fatalf("Expected 2 payloads and 1 dropped, got %d and %d", queue.len(), queue.dropped)
+	}
+
+	// Interrupted writes are cleaned up on open
+	err = os.WriteFile(filepath.Join(dir, "partial.json.tmp"), []byte("x"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := openSpool(dir, 10)
+	if err != nil {
+		t.Fatalf("openSpool() error: %v", err)
+	}
+
+	// A payload taken out to be sent goes back in front of the others
+	err = reopened.pushFront([]byte("zz"))
+	if err != nil {
+		t.Fatalf("pushFront() error: %v", err)
+	}
+
+	for _, expected := range []string{"zz", "bbbb", "cccc"} {
+		payload, name, ok := reopened.peek()
+		if !ok || string(payload) != expected {
+			t.Errorf("peek() = %q, %v, expected %q", payload, ok, expected)
+		}
+
+		reopened.remove(name)
+	}
+
+	if _, _, ok := reopened.peek(); ok || reopened.size != 0 {
+		t.Errorf("Expected an empty spool, got %d bytes", reopened.size)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty directory, got %d entries", len(entries))
+	}
+}
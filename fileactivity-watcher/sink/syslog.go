@@ -0,0 +1,446 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+const (
+	syslogWriteTimeout = 5 * time.Second
+	syslogMinRetry     = time.Second
+	syslogMaxRetry     = time.Minute
+	syslogMaxHostname  = 255
+	syslogMaxAppName   = 48
+
+	// syslogSDID is the structured data ID of the activity parameters, under the
+	// example enterprise number of RFC 5612.
+	syslogSDID = "fileactivity@32473"
+
+	// syslogTimeFormat is RFC 3339 limited to the six fractional digits RFC 5424 allows.
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	// flaggedClass is the event class of records flagged by a filter rule.
+	flaggedClass = "flagged"
+)
+
+var errSyslogDisconnected = errors.New("not connected to syslog")
+
+//nolint:gochecknoglobals
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6,
+	"news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12,
+	"security": 13, "console": 14, "clock": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+//nolint:gochecknoglobals
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "error": 3,
+	"warning": 4, "warn": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// SyslogPriority is the facility and severity of a syslog message, by name,
+// such as "local0" and "warning".
+type SyslogPriority struct {
+	Facility string `json:"facility,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// SyslogOptions are the options of a syslog sink. Network is "unixgram" (the
+// default, with Address defaulting to /dev/log), "udp" or "tcp". Hostname
+// defaults to the host name, AppName to "fileactivity", and the priority to
+// "user" and "info". Classes
+// override the priority for records with an operation, such as "remove", or for
+// flagged records ("flagged"); unset fields fall back to the sink's priority.
+// When several classes apply, the most severe one wins.
+type SyslogOptions struct {
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+	SyslogPriority
+
+	Classes map[string]SyslogPriority `json:"classes,omitempty"`
+}
+
+// priority is a parsed syslog priority.
+type priority struct {
+	facility int
+	severity int
+}
+
+func (p priority) value() int {
+	return p.facility*8 + p.severity
+}
+
+// syslogSink writes records as RFC 5424 messages. TCP messages are framed by
+// octet counting (RFC 6587), datagrams carry a single message each.
+type syslogSink struct {
+	network  string
+	address  string
+	hostname string
+	appName  string
+	procID   string
+
+	defaultPriority priority
+	classes         map[string]priority
+
+	conn       net.Conn
+	retryAt    time.Time
+	retryDelay time.Duration
+
+	// failing is set while messages cannot be delivered, so that the recovery
+	// is logged once they can
+	failing bool
+}
+
+func newSyslogSink(_ config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	var options SyslogOptions
+
+	if len(sinkConfig.Options) > 0 {
+		err := json.Unmarshal(sinkConfig.Options, &options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog sink options: %w", err)
+		}
+	}
+
+	sink, err := syslogFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sink.connect()
+	if err != nil {
+		// The collector may start after the watcher, so keep trying
+		sink.failing = true
+
+		log.Warn().
+			Str("network", sink.network).
+			Str("address", sink.address).
+			Err(err).
+			Msg("Syslog not reachable, will retry")
+	}
+
+	return sink, nil
+}
+
+func syslogFromOptions(options SyslogOptions) (*syslogSink, error) {
+	sink := &syslogSink{
+		network:    strings.ToLower(strings.TrimSpace(options.Network)),
+		address:    options.Address,
+		hostname:   syslogField(options.Hostname, syslogMaxHostname),
+		appName:    syslogField(options.AppName, syslogMaxAppName),
+		procID:     strconv.Itoa(os.Getpid()),
+		classes:    map[string]priority{},
+		retryDelay: syslogMinRetry,
+	}
+
+	if options.AppName == "" {
+		sink.appName = "fileactivity"
+	}
+
+	switch sink.network {
+	case "", "unixgram":
+		sink.network = "unixgram"
+
+		if sink.address == "" {
+			sink.address = "/dev/log"
+		}
+	case "udp", "tcp":
+		if sink.address == "" {
+			return nil, fmt.Errorf("syslog over %s needs an address", sink.network)
+		}
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", options.Network)
+	}
+
+	if sink.hostname == "-" {
+		hostname, err := os.Hostname()
+		if err == nil {
+			sink.hostname = syslogField(hostname, syslogMaxHostname)
+		}
+	}
+
+	var err error
+
+	sink.defaultPriority, err = parsePriority(options.SyslogPriority, priority{
+		facility: syslogFacilities["user"],
+		severity: syslogSeverities["info"],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for class, classPriority := range options.Classes {
+		class = strings.ToLower(strings.TrimSpace(class))
+
+		if class != flaggedClass {
+			_, err = types.ParseOp(class)
+			if err != nil {
+				return nil, fmt.Errorf("invalid syslog event class: %w", err)
+			}
+		}
+
+		sink.classes[class], err = parsePriority(classPriority, sink.defaultPriority)
+		if err != nil {
+			return nil, fmt.Errorf("syslog event class %s: %w", class, err)
+		}
+	}
+
+	return sink, nil
+}
+
+// parsePriority parses a priority, using defaults for unset names.
+func parsePriority(names SyslogPriority, defaults priority) (priority, error) {
+	result := defaults
+
+	if names.Facility != "" {
+		facility, ok := syslogFacilities[strings.ToLower(strings.TrimSpace(names.Facility))]
+		if !ok {
+			return result, fmt.Errorf("unknown syslog facility %q", names.Facility)
+		}
+
+		result.facility = facility
+	}
+
+	if names.Severity != "" {
+		severity, ok := syslogSeverities[strings.ToLower(strings.TrimSpace(names.Severity))]
+		if !ok {
+			return result, fmt.Errorf("unknown syslog severity %q", names.Severity)
+		}
+
+		result.severity = severity
+	}
+
+	return result, nil
+}
+
+// syslogField returns a header field as RFC 5424 allows it: printable ASCII
+// without spaces, at most maxLength characters, or "-" when empty.
+func syslogField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < '!' || r > '~' {
+			return -1
+		}
+
+		return r
+	}, value)
+
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+
+	if field == "" {
+		return "-"
+	}
+
+	return field
+}
+
+// classify returns the event class and priority of a record. The most severe
+// of the classes that apply wins; ties go to the flagged class, then to the
+// operations in log order.
+func (s *syslogSink) classify(record types.Record) (string, priority) {
+	class := "activity"
+	result := s.defaultPriority
+	found := false
+
+	candidates := record.Op.Names()
+	if record.Flag != "" {
+		candidates = append([]string{flaggedClass}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		classPriority, ok := s.classes[candidate]
+		if ok && (!found || classPriority.severity < result.severity) {
+			class = candidate
+			result = classPriority
+			found = true
+		}
+	}
+
+	return class, result
+}
+
+// format returns the record as an RFC 5424 message.
+func (s *syslogSink) format(event Event) []byte {
+	record := event.Record
+	class, classPriority := s.classify(record)
+
+	params := [][2]string{
+		{"path", record.File},
+		{"op", record.Op.String()},
+		{"pid", strconv.Itoa(record.PID)},
+		{"process", record.ProcessPath},
+		{"container", record.Container},
+		{"disk", record.Disk},
+		{"source", record.Source},
+		{"image_path", record.ImagePath},
+		{"flag", record.Flag},
+		{"rule", event.Rule},
+		{"count", strconv.Itoa(record.Count)},
+	}
+
+	var message strings.Builder
+
+	fmt.Fprintf(&message, "<%d>1 %s %s %s %s %s [%s",
+		classPriority.value(),
+		record.FirstSeen.Format(syslogTimeFormat),
+		s.hostname,
+		s.appName,
+		s.procID,
+		class,
+		syslogSDID,
+	)
+
+	for _, param := range params {
+		// Empty values are left out rather than written as ""
+		if param[1] == "" {
+			continue
+		}
+
+		message.WriteString(" " + param[0] + `="` + escapeSDValue(param[1]) + `"`)
+	}
+
+	// The BOM marks the message as UTF-8
+	text := record.File
+	if record.Message != "" {
+		text = record.Message
+	}
+
+	message.WriteString("] \ufeff" + record.Op.String() + " " + text)
+
+	return []byte(message.String())
+}
+
+// escapeSDValue escapes the characters RFC 5424 reserves in parameter values.
+func escapeSDValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// connect dials the collector, unless a failed attempt is too recent.
+func (s *syslogSink) connect() error {
+	now := time.Now()
+	if now.Before(s.retryAt) {
+		return errSyslogDisconnected
+	}
+
+	conn, err := net.DialTimeout(s.network, s.address, syslogWriteTimeout)
+	if err != nil {
+		s.retryAt = now.Add(s.retryDelay)
+		s.retryDelay = min(s.retryDelay*2, syslogMaxRetry)
+
+		return fmt.Errorf("error connecting to syslog: %w", err)
+	}
+
+	s.conn = conn
+	s.retryAt = time.Time{}
+	s.retryDelay = syslogMinRetry
+
+	return nil
+}
+
+func (s *syslogSink) Write(event Event) error {
+	message := s.format(event)
+	if s.network == "tcp" {
+		message = append([]byte(strconv.Itoa(len(message))+" "), message...)
+	}
+
+	err := s.deliver(message)
+	if err != nil {
+		s.failing = true
+
+		return err
+	}
+
+	if s.failing {
+		s.failing = false
+
+		log.Info().Str("address", s.address).Msg("Reconnected to syslog")
+	}
+
+	return nil
+}
+
+// deliver sends a message. A write on a stale connection fails once the socket
+// is gone; it is sent again on a new connection.
+func (s *syslogSink) deliver(message []byte) error {
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			err := s.connect()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := s.send(message)
+		if err == nil {
+			return nil
+		}
+
+		s.disconnect()
+
+		if attempt > 0 {
+			return err
+		}
+
+		s.failing = true
+	}
+}
+
+func (s *syslogSink) send(message []byte) error {
+	err := s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if err != nil {
+		return fmt.Errorf("error writing to syslog: %w", err)
+	}
+
+	_, err = s.conn.Write(message)
+	if err != nil {
+		return fmt.Errorf("error writing to syslog: %w", err)
+	}
+
+	return nil
+}
+
+func (s *syslogSink) disconnect() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *syslogSink) Close() error {
+	s.disconnect()
+
+	return nil
+}
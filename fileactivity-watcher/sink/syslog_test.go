@@ -0,0 +1,378 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func testSyslogSink(t *testing.T, options SyslogOptions) *syslogSink {
+	t.Helper()
+
+	if options.Network == "" {
+		options.Network = "udp"
+		options.Address = "127.0.0.1:514"
+	}
+
+	if options.Hostname == "" {
+		options.Hostname = "tower"
+	}
+
+	sink, err := syslogFromOptions(options)
+	if err != nil {
+		t.Fatalf("syslogFromOptions() error: %v", err)
+	}
+
+	sink.procID = "42"
+
+	return sink
+}
+
+func TestSyslogSink_Format(t *testing.T) {
+	sink := testSyslogSink(t, SyslogOptions{
+		SyslogPriority: SyslogPriority{Facility: "local0", Severity: "info"},
+	})
+
+	record := testRecord(`/mnt/disk1/Photos/a "b"].jpg`, "")
+	record.Op = types.OpOpen | types.OpRead
+	record.FirstSeen = time.Date(2026, 10, 18, 12, 0, 0, 123456789, time.UTC)
+	record.ProcessPath = `C:\odd`
+	record.Container = "plex"
+	record.Disk = "disk1"
+
+	expected := "<134>1 2026-10-18T12:00:00.123456Z tower fileactivity 42 activity " +
+		`[fileactivity@32473 path="/mnt/disk1/Photos/a \"b\"\].jpg" op="OPEN|READ" ` +
+		`pid="1234" process="C:\\odd" container="plex" disk="disk1" rule="photos" count="1"] ` +
+		"\ufeffOPEN|READ /mnt/disk1/Photos/a \"b\"].jpg"
+
+	message := string(sink.format(Event{Record: record, Rule: "photos"}))
+	if message != expected {
+		t.Errorf("format() =\n%s\nexpected\n%s", message, expected)
+	}
+}
+
+func TestSyslogSink_Classes(t *testing.T) {
+	sink := testSyslogSink(t, SyslogOptions{
+		SyslogPriority: SyslogPriority{Facility: "local0", Severity: "info"},
+		Classes: map[string]SyslogPriority{
+			"remove":  {Severity: "warning"},
+			"write":   {Severity: "notice"},
+			"flagged": {Facility: "local1", Severity: "notice"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		op       types.Op
+		flag     string
+		class    string
+		priority int
+	}{
+		{"default", types.OpRead, "", "activity", 16*8 + 6},
+		{"op class", types.OpWrite, "", "write", 16*8 + 5},
+		{"most severe op", types.OpWrite | types.OpRemove, "", "remove", 16*8 + 4},
+		{"flagged", types.OpRead, "rule", "flagged", 17*8 + 5},
+		{"flagged tie", types.OpWrite, "rule", "flagged", 17*8 + 5},
+		{"op more severe than flag", types.OpRemove, "rule", "remove", 16*8 + 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := testRecord("/mnt/disk1/a", tt.flag)
+			record.Op = tt.op
+
+			class, classPriority := sink.classify(record)
+			if class != tt.class || classPriority.value() != tt.priority {
+				t.Errorf("classify() = %s <%d>, expected %s <%d>",
+					class, classPriority.value(), tt.class, tt.priority)
+			}
+		})
+	}
+}
+
+func TestSyslogSink_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{"network", `{"network": "carrier-pigeon"}`},
+		{"missing address", `{"network": "tcp"}`},
+		{"facility", `{"facility": "local9"}`},
+		{"severity", `{"severity": "loud"}`},
+		{"class", `{"classes": {"delete": {"severity": "warning"}}}`},
+		{"class severity", `{"classes": {"remove": {"severity": "loud"}}}`},
+		{"json", `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSyslogSink(config.ActivityConfig{}, config.Sink{
+				Type:    "syslog",
+				Options: []byte(tt.options),
+			})
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestSyslogSink_UDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer listener.Close()
+
+	sink := testSyslogSink(t, SyslogOptions{Network: "udp", Address: listener.LocalAddr().String()})
+	defer sink.Close()
+
+	for _, file := range []string{"/mnt/disk1/a", "/mnt/disk1/b"} {
+		err = sink.Write(Event{Record: testRecord(file, "")})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	buffer := make([]byte, 2048)
+
+	for _, file := range []string{"/mnt/disk1/a", "/mnt/disk1/b"} {
+		_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		n, _, err := listener.ReadFrom(buffer)
+		if err != nil {
+			t.Fatalf("ReadFrom() error: %v", err)
+		}
+
+		if !strings.HasPrefix(string(buffer[:n]), "<14>1 ") ||
+			!strings.HasSuffix(string(buffer[:n]), " "+file) {
+			t.Errorf("Unexpected datagram %q", buffer[:n])
+		}
+	}
+}
+
+func TestSyslogSink_Unixgram(t *testing.T) {
+	socketDir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(socketDir)
+
+	socketPath := filepath.Join(socketDir, "log")
+
+	listen := func() net.PacketConn {
+		listener, err := net.ListenPacket("unixgram", socketPath)
+		if err != nil {
+			t.Skipf("Unix datagram sockets not available: %v", err)
+		}
+
+		return listener
+	}
+
+	read := func(listener net.PacketConn) string {
+		buffer := make([]byte, 2048)
+		_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		n, _, err := listener.ReadFrom(buffer)
+		if err != nil {
+			t.Fatalf("ReadFrom() error: %v", err)
+		}
+
+		return string(buffer[:n])
+	}
+
+	listener := listen()
+
+	sink := testSyslogSink(t, SyslogOptions{Network: "unixgram", Address: socketPath})
+	defer sink.Close()
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	if message := read(listener); !strings.HasSuffix(message, " /mnt/disk1/a") {
+		t.Errorf("Unexpected message %q", message)
+	}
+
+	// The collector restarts and recreates its socket
+	listener.Close()
+	os.Remove(socketPath)
+
+	listener = listen()
+	defer listener.Close()
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/b", "")})
+	if err != nil {
+		t.Fatalf("Write() after restart error: %v", err)
+	}
+
+	if message := read(listener); !strings.HasSuffix(message, " /mnt/disk1/b") {
+		t.Errorf("Unexpected message after restart %q", message)
+	}
+}
+
+func TestSyslogSink_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("TCP not available: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+
+	// Each connection reads one octet-counted message and hangs up
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+
+			length, err := reader.ReadString(' ')
+			if err == nil {
+				size, _ := strconv.Atoi(strings.TrimSpace(length))
+				message := make([]byte, size)
+
+				_, err = reader.Read(message)
+				if err == nil {
+					messages <- string(message)
+				}
+			}
+
+			conn.Close()
+		}
+	}()
+
+	sink := testSyslogSink(t, SyslogOptions{Network: "tcp", Address: listener.Addr().String()})
+	defer sink.Close()
+
+	receive := func(file string) {
+		t.Helper()
+
+		select {
+		case message := <-messages:
+			if !strings.HasPrefix(message, "<14>1 ") || !strings.HasSuffix(message, " "+file) {
+				t.Errorf("Unexpected message %q", message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Message for %s not received", file)
+		}
+	}
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	receive("/mnt/disk1/a")
+
+	// Writes on the closed connection fail once the peer's reset arrives, and
+	// the sink then reconnects
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		err = sink.Write(Event{Record: testRecord("/mnt/disk1/b", "")})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+
+		select {
+		case message := <-messages:
+			if !strings.HasSuffix(message, " /mnt/disk1/b") {
+				t.Errorf("Unexpected message %q", message)
+			}
+
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	t.Error("Sink did not reconnect")
+}
+
+func TestSyslogSink_Unreachable(t *testing.T) {
+	sink := testSyslogSink(t, SyslogOptions{
+		Network: "unixgram",
+		Address: filepath.Join(t.TempDir(), "missing"),
+	})
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err == nil {
+		t.Fatal("Expected an error without a collector")
+	}
+
+	// Retries are spaced out after a failed attempt
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != errSyslogDisconnected {
+		t.Errorf("Expected the retry to wait, got %v", err)
+	}
+
+	if sink.retryDelay != 2*syslogMinRetry {
+		t.Errorf("Expected the retry delay to double, got %v", sink.retryDelay)
+	}
+}
+
+func TestSyslogSink_Recovery(t *testing.T) {
+	socketDir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(socketDir)
+
+	socketPath := filepath.Join(socketDir, "log")
+
+	sink := testSyslogSink(t, SyslogOptions{Network: "unixgram", Address: socketPath})
+	defer sink.Close()
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err == nil || !sink.failing {
+		t.Fatalf("Expected the outage to be recorded, got %v", err)
+	}
+
+	listener, err := net.ListenPacket("unixgram", socketPath)
+	if err != nil {
+		t.Skipf("Unix datagram sockets not available: %v", err)
+	}
+	defer listener.Close()
+
+	// A later write after the retry delay recovers
+	sink.retryAt = time.Time{}
+
+	err = sink.Write(Event{Record: testRecord("/mnt/disk1/b", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	if sink.failing {
+		t.Error("Expected the recovery to clear the outage")
+	}
+}
@@ -0,0 +1,639 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+)
+
+const (
+	webhookTimeout       = 10 * time.Second
+	webhookRetries       = 3
+	webhookRetryDelay    = time.Second
+	webhookMaxRetryDelay = 5 * time.Minute
+	webhookBatchInterval = time.Second
+	webhookMaxQueueMB    = 10
+	webhookMemoryQueue   = 64
+	webhookSignature     = "X-FileActivity-Signature"
+)
+
+//nolint:gochecknoglobals
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// webhookQueueRoot holds the default queue directories. It is on the flash
+// drive, as /var/log is lost on reboot.
+var webhookQueueRoot = "/boot/config/plugins/file.activity/webhook" //nolint:gochecknoglobals
+
+// WebhookOptions are the options of a webhook sink, which POSTs records as JSON
+// to URL. Which records are sent is up to the sink's rules; with default_action
+// set to "drop", only the records a rule keeps are sent. Records are sent one
+// per request, unless BatchSize is above one; a batch is sent when it is full
+// or BatchIntervalMS after its first record.
+//
+// BodyTemplate is a text/template producing the JSON body, with .Events, .Event
+// (the first of them), .Count and .Host; the json function encodes a value as
+// JSON. Without a template, single records are sent as an object and batches as
+// {"count": n, "events": [...]}. With a Secret, the HMAC-SHA256 of the body is
+// sent in SignatureHeader as "sha256=<hex>".
+//
+// Requests are sent in the background, in order, so a slow URL does not hold up
+// the other sinks. Failed requests are retried Retries times, waiting
+// RetryDelayMS and then twice as long each time. Payloads that still fail, or
+// that pile up while the URL is slow, are kept in QueueDir, up to MaxQueueMB,
+// and retried in order until the URL accepts them again. QueueDir defaults to a
+// directory per sink on the flash drive, so queued payloads survive a reboot.
+type WebhookOptions struct {
+	URL             string            `json:"url"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Secret          string            `json:"secret,omitempty"`
+	SignatureHeader string            `json:"signature_header,omitempty"`
+	BodyTemplate    string            `json:"body_template,omitempty"`
+	BatchSize       int               `json:"batch_size,omitempty"`
+	BatchIntervalMS int               `json:"batch_interval_ms,omitempty"`
+	TimeoutMS       int               `json:"timeout_ms,omitempty"`
+	Retries         int               `json:"retries,omitempty"`
+	RetryDelayMS    int               `json:"retry_delay_ms,omitempty"`
+	QueueDir        string            `json:"queue_dir,omitempty"`
+	MaxQueueMB      int               `json:"max_queue_mb,omitempty"`
+}
+
+// WebhookBody is the data the body template is executed with.
+type WebhookBody struct {
+	Events []EventJSON `json:"events"`
+	Event  EventJSON   `json:"-"`
+	Count  int         `json:"count"`
+	Host   string      `json:"-"`
+}
+
+// errPermanent marks a request the server rejected for good, so retrying or
+// queueing it is pointless.
+var errPermanent = errors.New("webhook rejected the request")
+
+type webhookSink struct {
+	url             string
+	headers         map[string]string
+	secret          []byte
+	signatureHeader string
+	template        *template.Template
+	host            string
+	client          *http.Client
+	retries         int
+	retryDelay      time.Duration
+
+	batchSize     int
+	batchInterval time.Duration
+
+	// mu guards the batch
+	mu         sync.Mutex
+	batch      []Event
+	batchStart time.Time
+
+	// queueMu guards the payloads waiting to be sent. Payloads in memory are
+	// always older than those in the spool; the first of them is being sent
+	// while sending is set.
+	queueMu sync.Mutex
+	pending [][]byte
+	sending bool
+	spool   *spool
+
+	wake    chan struct{}
+	stop    chan struct{}
+	workers sync.WaitGroup
+}
+
+func newWebhookSink(_ config.ActivityConfig, sinkConfig config.Sink) (Sink, error) {
+	var options WebhookOptions
+
+	if len(sinkConfig.Options) > 0 {
+		err := json.Unmarshal(sinkConfig.Options, &options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook sink options: %w", err)
+		}
+	}
+
+	if options.QueueDir == "" {
+		options.QueueDir = filepath.Join(webhookQueueRoot, queueName(sinkConfig.Name, options.URL))
+	}
+
+	return webhookFromOptions(options)
+}
+
+// queueName returns the queue directory name of a sink. Unnamed sinks use a
+// hash of their URL, which may hold tokens that do not belong in a path.
+func queueName(name, target string) string {
+	name = unsafeNameChars.ReplaceAllString(name, "_")
+	if name != "" {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(target))
+
+	return "url-" + hex.EncodeToString(sum[:6])
+}
+
+func webhookFromOptions(options WebhookOptions) (*webhookSink, error) {
+	target, err := url.Parse(options.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", options.URL)
+	}
+
+	sink := &webhookSink{
+		url:             options.URL,
+		headers:         options.Headers,
+		secret:          []byte(options.Secret),
+		signatureHeader: options.SignatureHeader,
+		client:          &http.Client{Timeout: webhookTimeout},
+		retries:         options.Retries,
+		retryDelay:      time.Duration(options.RetryDelayMS) * time.Millisecond,
+		batchSize:       max(options.BatchSize, 1),
+		batchInterval:   time.Duration(options.BatchIntervalMS) * time.Millisecond,
+		wake:            make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+	}
+
+	if sink.signatureHeader == "" {
+		sink.signatureHeader = webhookSignature
+	}
+
+	if options.TimeoutMS > 0 {
+		sink.client.Timeout = time.Duration(options.TimeoutMS) * time.Millisecond
+	}
+
+	if sink.retries <= 0 {
+		sink.retries = webhookRetries
+	}
+
+	if sink.retryDelay <= 0 {
+		sink.retryDelay = webhookRetryDelay
+	}
+
+	if sink.batchInterval <= 0 {
+		sink.batchInterval = webhookBatchInterval
+	}
+
+	sink.host, err = os.Hostname()
+	if err != nil {
+		sink.host = "unknown"
+	}
+
+	if options.BodyTemplate != "" {
+		sink.template, err = template.New("body").
+			Funcs(template.FuncMap{"json": templateJSON, "join": strings.Join}).
+			Parse(options.BodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook body template: %w", err)
+		}
+	}
+
+	maxQueueMB := options.MaxQueueMB
+	if maxQueueMB <= 0 {
+		maxQueueMB = webhookMaxQueueMB
+	}
+
+	sink.spool, err = openSpool(options.QueueDir, int64(maxQueueMB)*bytesPerMB)
+	if err != nil {
+		return nil, err
+	}
+
+	if sink.spool.len() > 0 {
+		log.Info().
+			Str("url", sink.url).
+			Int("payloads", sink.spool.len()).
+			Msg("Resuming queued webhook payloads")
+	}
+
+	sink.workers.Add(1)
+
+	go sink.run()
+
+	sink.workers.Add(1)
+
+	go sink.deliverLoop()
+
+	return sink, nil
+}
+
+func templateJSON(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error encoding template value: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// body renders a batch of events.
+func (s *webhookSink) body(events []Event) ([]byte, error) {
+	data := WebhookBody{Count: len(events), Host: s.host}
+
+	for _, event := range events {
+		data.Events = append(data.Events, event.JSON())
+	}
+
+	data.Event = data.Events[0]
+
+	if s.template == nil {
+		if s.batchSize == 1 {
+			return marshalJSON(data.Event)
+		}
+
+		return marshalJSON(data)
+	}
+
+	var body bytes.Buffer
+
+	err := s.template.Execute(&body, data)
+	if err != nil {
+		return nil, fmt.Errorf("error executing webhook body template: %w", err)
+	}
+
+	if !json.Valid(body.Bytes()) {
+		return nil, errors.New("webhook body template did not produce valid JSON")
+	}
+
+	return body.Bytes(), nil
+}
+
+func (s *webhookSink) Write(event Event) error {
+	s.mu.Lock()
+
+	if len(s.batch) == 0 {
+		s.batchStart = time.Now()
+	}
+
+	s.batch = append(s.batch, event)
+
+	var events []Event
+	if len(s.batch) >= s.batchSize {
+		events = s.takeBatch()
+	}
+
+	s.mu.Unlock()
+
+	if events == nil {
+		return nil
+	}
+
+	return s.enqueue(events)
+}
+
+// takeBatch returns the pending events and starts a new batch. mu must be held.
+func (s *webhookSink) takeBatch() []Event {
+	events := s.batch
+	s.batch = nil
+
+	return events
+}
+
+// enqueue renders a batch and queues it for delivery. Payloads wait in memory
+// while the URL keeps up; once too many are waiting, or older payloads are
+// already spooled, they go to the spool so they are delivered in order.
+func (s *webhookSink) enqueue(events []Event) error {
+	body, err := s.body(events)
+	if err != nil {
+		return err
+	}
+
+	s.queueMu.Lock()
+
+	if s.spool.len() == 0 && len(s.pending) < webhookMemoryQueue {
+		s.pending = append(s.pending, body)
+	} else {
+		s.spill()
+		err = s.spool.push(body)
+	}
+
+	s.queueMu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return err
+}
+
+// spill moves the payloads waiting in memory to the front of the spool, except
+// one that is being sent. queueMu must be held.
+func (s *webhookSink) spill() {
+	keep := 0
+	if s.sending {
+		keep = 1
+	}
+
+	for i := len(s.pending) - 1; i >= keep; i-- {
+		err := s.spool.pushFront(s.pending[i])
+		if err != nil {
+			log.Error().Str("url", s.url).Err(err).Msg("Error queueing webhook payload")
+		}
+	}
+
+	s.pending = s.pending[:min(keep, len(s.pending))]
+}
+
+// next returns the oldest payload waiting to be sent, and the spool file it was
+// read from, if any.
+func (s *webhookSink) next() ([]byte, string, bool) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if len(s.pending) > 0 {
+		s.sending = true
+
+		return s.pending[0], "", true
+	}
+
+	return s.spool.peek()
+}
+
+// finish removes a payload that was delivered or rejected from the queue.
+func (s *webhookSink) finish(spooled string) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if spooled != "" {
+		s.spool.remove(spooled)
+
+		return
+	}
+
+	s.pending = s.pending[1:]
+	s.sending = false
+}
+
+// requeue spools a payload from memory that could not be delivered, along with
+// the ones waiting behind it.
+func (s *webhookSink) requeue() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	s.sending = false
+	s.spill()
+}
+
+// deliverLoop sends the queued payloads, oldest first. Payloads from memory are
+// retried right away; once they fail, they are spooled and retried with a
+// growing delay until the URL accepts them again.
+func (s *webhookSink) deliverLoop() {
+	defer s.workers.Done()
+
+	delay := s.retryDelay
+	delivered := 0
+	failing := false
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		body, spooled, ok := s.next()
+		if !ok {
+			select {
+			case <-s.stop:
+				return
+			case <-s.wake:
+			}
+
+			continue
+		}
+
+		var err error
+		if spooled == "" {
+			err = s.sendWithRetry(body)
+		} else {
+			err = s.send(body)
+		}
+
+		if err == nil || errors.Is(err, errPermanent) {
+			if err != nil {
+				log.Error().Str("url", s.url).Err(err).Msg("Dropping rejected webhook payload")
+			}
+
+			s.finish(spooled)
+
+			delivered++
+
+			if failing && s.queued() == 0 {
+				log.Info().
+					Str("url", s.url).
+					Int("delivered", delivered).
+					Msg("Webhook reachable again, delivered the queued payloads")
+
+				failing = false
+				delay = s.retryDelay
+			}
+
+			continue
+		}
+
+		if spooled == "" {
+			s.requeue()
+
+			log.Warn().
+				Str("url", s.url).
+				Err(err).
+				Msg("Webhook unreachable, queueing payloads on disk")
+		} else {
+			log.Debug().
+				Str("url", s.url).
+				Int("queued", s.queued()).
+				Dur("retry_in", delay).
+				Err(err).
+				Msg("Webhook still unreachable")
+		}
+
+		failing = true
+		delivered = 0
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, webhookMaxRetryDelay)
+	}
+}
+
+// queued returns the number of payloads waiting to be sent.
+func (s *webhookSink) queued() int {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	return len(s.pending) + s.spool.len()
+}
+
+func (s *webhookSink) sendWithRetry(body []byte) error {
+	delay := s.retryDelay
+
+	var err error
+
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-s.stop:
+				return err
+			}
+
+			delay = min(delay*2, webhookMaxRetryDelay)
+		}
+
+		err = s.send(body)
+		if err == nil || errors.Is(err, errPermanent) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (s *webhookSink) send(body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", errPermanent, err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "fileactivity-watcher")
+
+	for name, value := range s.headers {
+		request.Header.Set(name, value)
+	}
+
+	if len(s.secret) > 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(body)
+		request.Header.Set(s.signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, bytesPerMB))
+	_ = response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusRequestTimeout,
+		response.StatusCode == http.StatusTooManyRequests,
+		response.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", response.Status)
+	}
+
+	return fmt.Errorf("%w: %s", errPermanent, response.Status)
+}
+
+// run sends batches that waited long enough.
+func (s *webhookSink) run() {
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(s.batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.flushBatch(now)
+		}
+	}
+}
+
+// flushBatch queues the pending batch once its interval has passed.
+func (s *webhookSink) flushBatch(now time.Time) {
+	s.mu.Lock()
+
+	var events []Event
+	if len(s.batch) > 0 && now.Sub(s.batchStart) >= s.batchInterval {
+		events = s.takeBatch()
+	}
+
+	s.mu.Unlock()
+
+	if events == nil {
+		return
+	}
+
+	err := s.enqueue(events)
+	if err != nil {
+		log.Error().Str("url", s.url).Err(err).Msg("Error queueing webhook batch")
+	}
+}
+
+// Close queues the pending batch and makes one attempt to send the payloads
+// waiting in memory. Payloads that cannot be delivered right away are spooled
+// for the next start.
+func (s *webhookSink) Close() error {
+	close(s.stop)
+	s.workers.Wait()
+
+	s.mu.Lock()
+	events := s.takeBatch()
+	s.mu.Unlock()
+
+	var err error
+	if len(events) > 0 {
+		err = s.enqueue(events)
+	}
+
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	s.sending = false
+
+	for s.spool.len() == 0 && len(s.pending) > 0 {
+		sendErr := s.send(s.pending[0])
+		if sendErr != nil && !errors.Is(sendErr, errPermanent) {
+			break
+		}
+
+		s.pending = s.pending[1:]
+	}
+
+	s.spill()
+
+	return err
+}
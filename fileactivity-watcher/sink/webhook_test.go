@@ -0,0 +1,456 @@
+package sink
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/config"
+)
+
+// webhookServer records the requests it receives and answers with the status
+// codes in responses, then with 200.
+type webhookServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []int
+	bodies    []string
+	headers   []http.Header
+	accepted  []string
+}
+
+func newWebhookServer(t *testing.T, responses ...int) *webhookServer {
+	t.Helper()
+
+	server := &webhookServer{responses: responses}
+	server.Server = httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			body, _ := io.ReadAll(request.Body)
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+
+			server.bodies = append(server.bodies, string(body))
+			server.headers = append(server.headers, request.Header.Clone())
+
+			status := http.StatusOK
+			if len(server.responses) > 0 {
+				status = server.responses[0]
+				server.responses = server.responses[1:]
+			}
+
+			if status == http.StatusOK {
+				server.accepted = append(server.accepted, string(body))
+			}
+
+			writer.WriteHeader(status)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *webhookServer) requests() ([]string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.bodies...), append([]string{}, s.accepted...)
+}
+
+func (s *webhookServer) setResponses(responses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = responses
+}
+
+func testWebhookSink(t *testing.T, options WebhookOptions) *webhookSink {
+	t.Helper()
+
+	if options.QueueDir == "" {
+		options.QueueDir = filepath.Join(t.TempDir(), "queue")
+	}
+
+	if options.RetryDelayMS == 0 {
+		options.RetryDelayMS = 10
+	}
+
+	sink, err := webhookFromOptions(options)
+	if err != nil {
+		t.Fatalf("webhookFromOptions() error: %v", err)
+	}
+
+	return sink
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookSink_Single(t *testing.T) {
+	server := newWebhookServer(t)
+
+	sink := testWebhookSink(t, WebhookOptions{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Secret:  "secret",
+	})
+
+	err := sink.Write(Event{Record: testRecord("/mnt/user/Photos/a.jpg", ""), Rule: "photos"})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	err = sink.Close()
+	if err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	bodies, _ := server.requests()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(bodies))
+	}
+
+	var event EventJSON
+
+	err = json.Unmarshal([]byte(bodies[0]), &event)
+	if err != nil {
+		t.Fatalf("Invalid body %q: %v", bodies[0], err)
+	}
+
+	if event.Path != "/mnt/user/Photos/a.jpg" || event.Rule != "photos" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	header := server.headers[0]
+	if header.Get("Authorization") != "Bearer token" ||
+		header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", header)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(bodies[0]))
+
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature := header.Get(webhookSignature); signature != expected {
+		t.Errorf("Signature = %q, expected %q", signature, expected)
+	}
+}
+
+func TestWebhookSink_Batch(t *testing.T) {
+	server := newWebhookServer(t)
+
+	sink := testWebhookSink(t, WebhookOptions{
+		URL:             server.URL,
+		BatchSize:       3,
+		BatchIntervalMS: 50,
+	})
+	defer sink.Close()
+
+	for _, file := range []string{"/mnt/disk1/a", "/mnt/disk1/b", "/mnt/disk1/c", "/mnt/disk1/d"} {
+		err := sink.Write(Event{Record: testRecord(file, "")})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	// The full batch goes out right away, the rest after the interval
+	waitFor(t, "the partial batch", func() bool {
+		bodies, _ := server.requests()
+
+		return len(bodies) == 2
+	})
+
+	bodies, _ := server.requests()
+
+	for i, expected := range []int{3, 1} {
+		var batch WebhookBody
+
+		err := json.Unmarshal([]byte(bodies[i]), &batch)
+		if err != nil {
+			t.Fatalf("Invalid body %q: %v", bodies[i], err)
+		}
+
+		if batch.Count != expected || len(batch.Events) != expected {
+			t.Errorf("Batch %d has %d events, expected %d", i, len(batch.Events), expected)
+		}
+	}
+}
+
+func TestWebhookSink_Template(t *testing.T) {
+	server := newWebhookServer(t)
+
+	sink := testWebhookSink(t, WebhookOptions{
+		URL: server.URL,
+		BodyTemplate: `{"text": {{json (printf "%s %s on %s" (join .Event.Ops ",") ` +
+			`.Event.Path .Host)}}, "count": {{.Count}}}`,
+	})
+
+	err := sink.Write(Event{Record: testRecord(`/mnt/disk1/"quoted"`, "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	sink.Close()
+
+	bodies, _ := server.requests()
+
+	var body struct {
+		Text  string `json:"text"`
+		Count int    `json:"count"`
+	}
+
+	err = json.Unmarshal([]byte(bodies[0]), &body)
+	if err != nil {
+		t.Fatalf("Invalid body %q: %v", bodies[0], err)
+	}
+
+	if body.Text != `write /mnt/disk1/"quoted" on `+sink.host || body.Count != 1 {
+		t.Errorf("Unexpected body %+v", body)
+	}
+}
+
+func TestWebhookSink_InvalidTemplateOutput(t *testing.T) {
+	server := newWebhookServer(t)
+
+	sink := testWebhookSink(t, WebhookOptions{
+		URL:          server.URL,
+		BodyTemplate: `{"path": {{.Event.Path}}}`,
+	})
+	defer sink.Close()
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err == nil || !strings.Contains(err.Error(), "valid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	}
+}
+
+func TestWebhookSink_Retry(t *testing.T) {
+	server := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	sink := testWebhookSink(t, WebhookOptions{URL: server.URL, Retries: 2})
+	defer sink.Close()
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	waitFor(t, "the delivery", func() bool {
+		_, accepted := server.requests()
+
+		return len(accepted) == 1
+	})
+
+	bodies, _ := server.requests()
+	if len(bodies) != 3 || sink.queued() != 0 {
+		t.Errorf("Expected 3 attempts and nothing queued, got %d and %d",
+			len(bodies), sink.queued())
+	}
+}
+
+func TestWebhookSink_Permanent(t *testing.T) {
+	server := newWebhookServer(t, http.StatusBadRequest)
+
+	sink := testWebhookSink(t, WebhookOptions{URL: server.URL})
+	defer sink.Close()
+
+	err := sink.Write(Event{Record: testRecord("/mnt/disk1/a", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	waitFor(t, "the payload to be dropped", func() bool {
+		return sink.queued() == 0
+	})
+
+	bodies, _ := server.requests()
+	if len(bodies) != 1 {
+		t.Errorf("Expected a single attempt, got %d", len(bodies))
+	}
+}
+
+func TestWebhookSink_Slow(t *testing.T) {
+	release := make(chan struct{})
+	server := newWebhookServer(t)
+
+	// Hold every request until released
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(writer http.ResponseWriter, request *http.Request) {
+			<-release
+			server.Config.Handler.ServeHTTP(writer, request)
+		},
+	))
+	defer slow.Close()
+
+	sink := testWebhookSink(t, WebhookOptions{URL: slow.URL})
+	defer sink.Close()
+
+	// Writes do not wait for the URL; what it cannot take yet is spooled
+	count := webhookMemoryQueue + 10
+	for i := range count {
+		file := fmt.Sprintf("/mnt/disk1/%03d", i)
+
+		err := sink.Write(Event{Record: testRecord(file, "")})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	sink.queueMu.Lock()
+	spooled := sink.spool.len()
+	sink.queueMu.Unlock()
+
+	if spooled == 0 {
+		t.Error("Expected payloads to be spooled")
+	}
+
+	close(release)
+
+	waitFor(t, "the queue to drain", func() bool {
+		_, accepted := server.requests()
+
+		return len(accepted) == count
+	})
+
+	_, accepted := server.requests()
+
+	for i, body := range accepted {
+		if !strings.Contains(body, fmt.Sprintf(`"path":"/mnt/disk1/%03d"`, i)) {
+			t.Fatalf("Delivery %d = %s, out of order", i, body)
+		}
+	}
+}
+
+func TestWebhookSink_Queue(t *testing.T) {
+	server := newWebhookServer(t)
+	server.setResponses(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway,
+		http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+
+	queueDir := filepath.Join(t.TempDir(), "queue")
+	options := WebhookOptions{URL: server.URL, Retries: 1, RetryDelayMS: 10, QueueDir: queueDir}
+
+	sink := testWebhookSink(t, options)
+
+	for _, file := range []string{"/mnt/disk1/a", "/mnt/disk1/b"} {
+		err := sink.Write(Event{Record: testRecord(file, "")})
+		if err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	// Stop before the queue is retried; the payloads stay on disk
+	sink.Close()
+
+	if sink.spool.len() != 2 {
+		t.Fatalf("Expected 2 queued payloads, got %d", sink.spool.len())
+	}
+
+	server.setResponses()
+
+	restarted := testWebhookSink(t, options)
+	defer restarted.Close()
+
+	err := restarted.Write(Event{Record: testRecord("/mnt/disk1/c", "")})
+	if err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	waitFor(t, "the queue to drain", func() bool {
+		_, accepted := server.requests()
+
+		return len(accepted) == 3
+	})
+
+	_, accepted := server.requests()
+
+	for i, file := range []string{"/mnt/disk1/a", "/mnt/disk1/b", "/mnt/disk1/c"} {
+		if !strings.Contains(accepted[i], `"path":"`+file+`"`) {
+			t.Errorf("Delivery %d = %s, expected %s", i, accepted[i], file)
+		}
+	}
+}
+
+func TestNewWebhookSink_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+	}{
+		{"missing url", `{}`},
+		{"scheme", `{"url": "ftp://example.com/"}`},
+		{"template", `{"url": "http://example.com/", "body_template": "{{"}`},
+		{"json", `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newWebhookSink(
+				config.ActivityConfig{ActivityPath: filepath.Join(t.TempDir(), "data.log")},
+				config.Sink{Name: "hook", Type: "webhook", Options: []byte(tt.options)},
+			)
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestQueueName(t *testing.T) {
+	tests := []struct {
+		name     string
+		sinkName string
+		url      string
+		expected string
+	}{
+		{"named", "home assistant", "http://example.com/", "home_assistant"},
+		{"unnamed", "", "http://example.com/hook?token=secret", "url-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := queueName(tt.sinkName, tt.url)
+			if !strings.HasPrefix(name, tt.expected) || strings.Contains(name, "secret") {
+				t.Errorf("queueName() = %q, expected %q", name, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Size of the write buffer; a full buffer is written out regardless of the policy
+const bufferSize = 64 * 1024
+
+// FlushPolicy decides when buffered records are written to the activity file.
+// Records are written out after Records records or Interval, whichever comes
+// first, and when the file is rotated or closed.
+type FlushPolicy struct {
+	// Records is the number of records buffered; 1 or less writes every record
+	Records int
+	// Interval is the longest a record stays buffered; 0 or less has no limit
+	Interval time.Duration
+	// Sync is how often the activity file is synced to disk; 0 or less never syncs
+	Sync time.Duration
+}
+
+// startFlushLoop writes out buffered records every interval.
+func (w *Writer) startFlushLoop() {
+	if w.flush.Records <= 1 || w.flush.Interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	w.stopFlush = stop
+	w.flushGroup.Add(1)
+
+	go func() {
+		defer w.flushGroup.Done()
+
+		ticker := time.NewTicker(w.flush.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.mu.Lock()
+
+				err := w.flushLocked(false)
+				if err != nil {
+					log.Error().Err(err).Msg("Error flushing activity file")
+				}
+
+				w.mu.Unlock()
+			}
+		}
+	}()
+}
+
+func (w *Writer) stopFlushLoop() {
+	w.mu.Lock()
+	stop := w.stopFlush
+	w.stopFlush = nil
+	w.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		w.flushGroup.Wait()
+	}
+}
+
+// flushLocked writes out the buffered records, and syncs the activity file when
+// it is due or forced. The caller holds the lock.
+func (w *Writer) flushLocked(forceSync bool) error {
+	if w.buffer == nil {
+		return nil
+	}
+
+	if w.pending > 0 || w.buffer.Buffered() > 0 {
+		err := w.buffer.Flush()
+		if err != nil {
+			return fmt.Errorf("error flushing activity file: %w", err)
+		}
+
+		w.pending = 0
+		w.unsynced = true
+	}
+
+	now := time.Now()
+	if w.flush.Sync <= 0 || !w.unsynced || (!forceSync && now.Sub(w.lastSync) < w.flush.Sync) {
+		return nil
+	}
+
+	err := w.activityFile.Sync()
+	if err != nil {
+		return fmt.Errorf("error syncing activity file: %w", err)
+	}
+
+	w.lastSync = now
+	w.unsynced = false
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	return strings.Count(string(data), "\n")
+}
+
+func TestFlushPolicy_Records(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{Records: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	for i := range 5 {
+		err := writer.Write(testRecord("/mnt/disk1/a", types.OpWrite, i))
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+
+		expected := 0
+		if i >= 2 {
+			expected = 3
+		}
+
+		if lines := countLines(t, activityPath); lines != expected {
+			t.Errorf("Expected %d lines after %d records, got %d", expected, i+1, lines)
+		}
+	}
+
+	if writer.currentBytes <= 0 {
+		t.Error("Expected buffered records to count towards the file size")
+	}
+}
+
+func TestFlushPolicy_Interval(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatJSONL, Rotation{}, FlushPolicy{
+		Records:  1000,
+		Interval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	err = writer.Write(testRecord("/mnt/disk1/a", types.OpWrite, 1))
+	if err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countLines(t, activityPath) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the buffered record to be written out after the interval")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFlushPolicy_Close(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{
+		Records:  1000,
+		Interval: time.Hour,
+		Sync:     time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10 {
+		err := writer.Write(testRecord("/mnt/disk1/a", types.OpWrite, i))
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	if lines := countLines(t, activityPath); lines != 0 {
+		t.Errorf("Expected records to be buffered, got %d lines", lines)
+	}
+
+	synced := writer.lastSync
+	writer.Close()
+
+	if lines := countLines(t, activityPath); lines != 10 {
+		t.Errorf("Expected 10 lines after Close(), got %d", lines)
+	}
+
+	if !writer.lastSync.After(synced) {
+		t.Error("Expected Close() to sync the activity file")
+	}
+}
+
+func TestFlushPolicy_Sync(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{Sync: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	synced := writer.lastSync
+
+	err = writer.Write(testRecord("/mnt/disk1/a", types.OpWrite, 1))
+	if err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if !writer.lastSync.Equal(synced) || !writer.unsynced {
+		t.Error("Expected no sync before the sync interval")
+	}
+
+	writer.lastSync = time.Now().Add(-2 * time.Hour)
+
+	err = writer.Write(testRecord("/mnt/disk1/b", types.OpWrite, 2))
+	if err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if writer.unsynced || time.Since(writer.lastSync) > time.Minute {
+		t.Error("Expected a sync once the sync interval passed")
+	}
+}
+
+func TestFlushPolicy_Rotation(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 2, FormatCSV, Rotation{}, FlushPolicy{Records: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		err := writer.Write(testRecord("/mnt/disk1/a", types.OpWrite, i))
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	writer.Close()
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 1 {
+		t.Fatalf("Expected a rotated file, got %v", files)
+	}
+
+	if lines := countLines(t, files[0].Path); lines != 3 {
+		t.Errorf("Expected the buffered records in the rotated file, got %d lines", lines)
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		format Format
+		flush  FlushPolicy
+	}{
+		{"csv/per-record", FormatCSV, FlushPolicy{}},
+		{"csv/batched", FormatCSV, FlushPolicy{Records: 100, Interval: time.Second}},
+		{"jsonl/per-record", FormatJSONL, FlushPolicy{}},
+		{"jsonl/batched", FormatJSONL, FlushPolicy{Records: 100, Interval: time.Second}},
+	}
+
+	record := testRecord("/mnt/disk1/Media/Movies/movie.mkv", types.OpOpen|types.OpRead, 1234)
+	record.ProcessPath = "/usr/lib/plexmediaserver/Plex Media Server"
+	record.Container = "plex"
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			activityPath := filepath.Join(b.TempDir(), "data.log")
+
+			writer, err := New(activityPath, b.N+1, bm.format, Rotation{}, bm.flush)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer writer.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				err := writer.Write(record)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
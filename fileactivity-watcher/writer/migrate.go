@@ -0,0 +1,178 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// MigrateResult counts the records of a migrated activity log.
+type MigrateResult struct {
+	Converted int
+	Skipped   int
+}
+
+// Migrate converts a CSV activity log to JSON Lines in place. Files that are
+// already JSON Lines and missing files are left alone, and rows that cannot be
+// parsed are skipped. Compressed rotated files stay compressed. The watcher
+// must not be writing to the file meanwhile. CSV rows have no disk column, so
+// diskOf is called with the path of each record to fill it in.
+func Migrate(path string, diskOf func(path string) string) (MigrateResult, error) {
+	result := MigrateResult{}
+
+	input, err := OpenGeneration(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return result, nil
+		}
+
+		return result, err
+	}
+	defer input.Close()
+
+	reader := bufio.NewReader(input)
+
+	first, _ := reader.Peek(1)
+	if len(first) > 0 && first[0] == '{' {
+		log.Info().Str("file", path).Msg("Activity file is already JSON Lines")
+
+		return result, nil
+	}
+
+	output, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".migrate-*")
+	if err != nil {
+		return result, fmt.Errorf("error creating migrated file: %w", err)
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	result, err = convertCSV(reader, output, filepath.Ext(path) == compressedSuffix, diskOf)
+	if err != nil {
+		return result, err
+	}
+
+	err = output.Chmod(0o644)
+	if err != nil {
+		return result, fmt.Errorf("error setting migrated file permissions: %w", err)
+	}
+
+	err = output.Close()
+	if err != nil {
+		return result, fmt.Errorf("error closing migrated file: %w", err)
+	}
+
+	err = os.Rename(output.Name(), path)
+	if err != nil {
+		return result, fmt.Errorf("error replacing activity file: %w", err)
+	}
+
+	log.Info().
+		Str("file", path).
+		Int("converted", result.Converted).
+		Int("skipped", result.Skipped).
+		Msg("Migrated activity file to JSON Lines")
+
+	return result, nil
+}
+
+func convertCSV(
+	input io.Reader,
+	output io.Writer,
+	compress bool,
+	diskOf func(path string) string,
+) (MigrateResult, error) {
+	result := MigrateResult{}
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+
+	var compressor *gzip.Writer
+
+	if compress {
+		compressor = gzip.NewWriter(output)
+		output = compressor
+	}
+
+	buffered := bufio.NewWriter(output)
+
+	for {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return result, fmt.Errorf("error reading activity file: %w", err)
+			}
+
+			log.Debug().Err(err).Msg("Skipping malformed activity row")
+
+			result.Skipped++
+
+			continue
+		}
+
+		record, err := types.ParseRecord(fields)
+		if err != nil {
+			log.Debug().Err(err).Strs("fields", fields).Msg("Skipping malformed activity row")
+
+			result.Skipped++
+
+			continue
+		}
+
+		record.Disk = diskOf(record.File)
+
+		line, err := MarshalJSON(record)
+		if err != nil {
+			return result, err
+		}
+
+		_, err = buffered.Write(line)
+		if err != nil {
+			return result, fmt.Errorf("error writing migrated file: %w", err)
+		}
+
+		result.Converted++
+	}
+
+	err := buffered.Flush()
+	if err == nil && compressor != nil {
+		err = compressor.Close()
+	}
+
+	if err != nil {
+		return result, fmt.Errorf("error writing migrated file: %w", err)
+	}
+
+	return result, nil
+}
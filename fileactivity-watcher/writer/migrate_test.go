@@ -0,0 +1,115 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+// testDiskOf takes the disk from the second path element, like /mnt/disk1/a.
+func testDiskOf(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return parts[2]
+}
+
+func TestMigrate(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	csvData := strings.Join([]string{
+		// Written before the extra columns were added
+		`2026-01-02T03:04:05.006Z,WRITE,/mnt/disk1/old.txt,100,/usr/bin/rsync,`,
+		`2026-01-02T03:04:06.000Z,OPEN|READ,"/mnt/disk2/a ""b"",c.mkv",200,/usr/bin/plex,plex,` +
+			`,/data/a.mkv,,,,3,2026-01-02T03:04:06.000Z,2026-01-02T03:05:06.000Z`,
+		`not a timestamp,WRITE,/mnt/disk1/x,1,,`,
+		`2026-01-02T03:04:07.000Z,CLOSE,/mnt/disk1/x,1,,`,
+	}, "\n") + "\n"
+
+	err := os.WriteFile(activityPath, []byte(csvData), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Migrate(activityPath, testDiskOf)
+	if err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+
+	if result.Converted != 2 || result.Skipped != 2 {
+		t.Errorf("Expected 2 converted and 2 skipped rows, got %+v", result)
+	}
+
+	data, err := os.ReadFile(activityPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %q", data)
+	}
+
+	var first, second types.JSONRecord
+
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Path != "/mnt/disk1/old.txt" || first.Disk != "disk1" || first.Count != 1 ||
+		first.Process != "/usr/bin/rsync" || first.Container != nil {
+		t.Errorf("Unexpected first record: %+v", first)
+	}
+
+	if second.Path != `/mnt/disk2/a "b",c.mkv` || second.Count != 3 ||
+		second.LastSeen != "2026-01-02T03:05:06.000Z" || second.Container == nil ||
+		second.Container.Path != "/data/a.mkv" {
+		t.Errorf("Unexpected second record: %+v", second)
+	}
+
+	// Migrating again leaves the file alone
+	result, err = Migrate(activityPath, testDiskOf)
+	if err != nil || result.Converted != 0 {
+		t.Errorf("Expected JSON Lines to be left alone, got %+v (%v)", result, err)
+	}
+
+	info, err := os.Stat(activityPath)
+	if err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("Expected mode 0644 after migration, got %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestMigrate_MissingFile(t *testing.T) {
+	_, err := Migrate(filepath.Join(t.TempDir(), "missing.log"), testDiskOf)
+	if err != nil {
+		t.Errorf("Expected no error for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Upper bound on the lines of a CSV record, for paths with embedded newlines
+	maxRecordLines = 16
+
+	// Longer lines are corrupt; only this much of them is quarantined
+	maxLineBytes = 1024 * 1024
+)
+
+// scannedLine is a line of the activity file, including its newline.
+type scannedLine struct {
+	data     []byte
+	offset   int64
+	complete bool
+	long     bool
+}
+
+// scannedRecord is a record of the activity file, or a line that is not one.
+type scannedRecord struct {
+	data   []byte
+	offset int64
+	valid  bool
+	torn   bool
+}
+
+// recordScanner streams the records of an activity file.
+type recordScanner struct {
+	reader  *bufio.Reader
+	format  Format
+	offset  int64
+	pending []scannedLine
+}
+
+func newRecordScanner(reader io.Reader, format Format) *recordScanner {
+	return &recordScanner{
+		reader: bufio.NewReader(reader),
+		format: format,
+	}
+}
+
+// next returns the next record, or io.EOF at the end of the file. A final line
+// without a newline is a record torn by a crash during a write.
+func (s *recordScanner) next() (scannedRecord, error) {
+	first, err := s.peek(0)
+	if err != nil {
+		return scannedRecord{}, err
+	}
+
+	if !first.complete {
+		s.consume(1)
+
+		return scannedRecord{data: first.data, offset: first.offset, torn: true}, nil
+	}
+
+	if first.long {
+		s.consume(1)
+
+		return scannedRecord{data: first.data, offset: first.offset}, nil
+	}
+
+	if s.format == FormatJSONL {
+		s.consume(1)
+
+		trimmed := bytes.TrimSpace(first.data)
+		valid := len(trimmed) == 0 || json.Valid(trimmed)
+
+		return scannedRecord{data: first.data, offset: first.offset, valid: valid}, nil
+	}
+
+	// Quoted CSV fields may span lines, so join lines until the quotes are closed
+	chunk := first.data
+	lines := 1
+
+	for openQuote(chunk) && lines < maxRecordLines {
+		line, err := s.peek(lines)
+		if errors.Is(err, io.EOF) || (err == nil && (!line.complete || line.long)) {
+			break
+		}
+
+		if err != nil {
+			return scannedRecord{}, err
+		}
+
+		chunk = append(append([]byte{}, chunk...), line.data...)
+		lines++
+	}
+
+	if !openQuote(chunk) && validCSV(chunk) {
+		s.consume(lines)
+
+		return scannedRecord{data: chunk, offset: first.offset, valid: true}, nil
+	}
+
+	// Only the first line is given up on; the next ones are scanned again
+	s.consume(1)
+
+	return scannedRecord{data: first.data, offset: first.offset}, nil
+}
+
+func (s *recordScanner) peek(index int) (scannedLine, error) {
+	for len(s.pending) <= index {
+		line, err := s.readLine()
+		if err != nil {
+			return scannedLine{}, err
+		}
+
+		s.pending = append(s.pending, line)
+	}
+
+	return s.pending[index], nil
+}
+
+func (s *recordScanner) consume(lines int) {
+	s.pending = s.pending[lines:]
+}
+
+func (s *recordScanner) readLine() (scannedLine, error) {
+	line := scannedLine{offset: s.offset}
+
+	for {
+		fragment, err := s.reader.ReadSlice('\n')
+		s.offset += int64(len(fragment))
+
+		if room := maxLineBytes - len(line.data); room < len(fragment) {
+			fragment = fragment[:max(room, 0)]
+			line.long = true
+		}
+
+		line.data = append(line.data, fragment...)
+
+		switch {
+		case err == nil:
+			line.complete = true
+
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if s.offset == line.offset {
+				return line, io.EOF
+			}
+
+			return line, nil
+		default:
+			return line, fmt.Errorf("error reading activity file: %w", err)
+		}
+	}
+}
+
+func openQuote(data []byte) bool {
+	return bytes.Count(data, []byte{'"'})%2 == 1
+}
+
+func validCSV(data []byte) bool {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	_, err := reader.Read()
+	if err != nil {
+		return errors.Is(err, io.EOF)
+	}
+
+	_, err = reader.Read()
+
+	return errors.Is(err, io.EOF)
+}
+
+// activityRepair rewrites an activity file without its corrupt lines, which are
+// moved to a ".bad" file next to it. The file is only rewritten from the first
+// corrupt line on; a torn final record is simply truncated.
+type activityRepair struct {
+	path        string
+	source      *os.File
+	output      *os.File
+	bad         *os.File
+	quarantined int
+	truncateAt  int64
+}
+
+// activityState describes the records found in an activity file.
+type activityState struct {
+	records int
+	first   []byte
+	last    []byte
+}
+
+// recoverActivityFile streams the activity file to count its records, repairing
+// it if it was left corrupt by a crash.
+func recoverActivityFile(path string, format Format) (activityState, error) {
+	state := activityState{}
+
+	source, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+
+		return state, fmt.Errorf("error opening activity file: %w", err)
+	}
+	defer source.Close()
+
+	repair := &activityRepair{path: path, source: source, truncateAt: -1}
+	defer repair.cleanup()
+
+	scanner := newRecordScanner(source, format)
+
+	for {
+		record, err := scanner.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return state, err
+		}
+
+		switch {
+		case record.torn:
+			err = repair.truncate(record)
+		case !record.valid:
+			err = repair.quarantine(record)
+		default:
+			err = repair.keep(record)
+
+			if len(bytes.TrimSpace(record.data)) > 0 {
+				state.records++
+				state.last = record.data
+
+				if state.first == nil {
+					state.first = record.data
+				}
+			}
+		}
+
+		if err != nil {
+			return state, err
+		}
+	}
+
+	return state, repair.finish()
+}
+
+func (r *activityRepair) keep(record scannedRecord) error {
+	if r.output == nil {
+		return nil
+	}
+
+	_, err := r.output.Write(record.data)
+	if err != nil {
+		return fmt.Errorf("error writing repaired activity file: %w", err)
+	}
+
+	return nil
+}
+
+func (r *activityRepair) quarantine(record scannedRecord) error {
+	if r.output == nil {
+		output, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".repair-*")
+		if err != nil {
+			return fmt.Errorf("error creating repaired activity file: %w", err)
+		}
+
+		r.output = output
+
+		// Everything up to the first corrupt line is kept as it is
+		_, err = io.Copy(output, io.NewSectionReader(r.source, 0, record.offset))
+		if err != nil {
+			return fmt.Errorf("error writing repaired activity file: %w", err)
+		}
+	}
+
+	log.Warn().
+		Str("file", r.path).
+		Int64("offset", record.offset).
+		Msg("Quarantining corrupt activity record")
+
+	return r.writeBad(record)
+}
+
+func (r *activityRepair) truncate(record scannedRecord) error {
+	log.Warn().
+		Str("file", r.path).
+		Int64("offset", record.offset).
+		Msg("Truncating torn activity record")
+
+	r.truncateAt = record.offset
+
+	return r.writeBad(record)
+}
+
+func (r *activityRepair) writeBad(record scannedRecord) error {
+	if r.bad == nil {
+		bad, err := os.OpenFile(r.path+".bad", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("error opening quarantine file: %w", err)
+		}
+
+		r.bad = bad
+	}
+
+	data := record.data
+	if !bytes.HasSuffix(data, []byte{'\n'}) {
+		data = append(append([]byte{}, data...), '\n')
+	}
+
+	_, err := r.bad.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing quarantine file: %w", err)
+	}
+
+	r.quarantined++
+
+	return nil
+}
+
+func (r *activityRepair) finish() error {
+	if r.bad != nil {
+		err := r.bad.Close()
+		r.bad = nil
+
+		if err != nil {
+			return fmt.Errorf("error closing quarantine file: %w", err)
+		}
+
+		log.Warn().
+			Str("file", r.path+".bad").
+			Int("records", r.quarantined).
+			Msg("Moved corrupt activity records to quarantine")
+	}
+
+	if r.output != nil {
+		output := r.output
+		r.output = nil
+
+		err := output.Chmod(0o644)
+		if err == nil {
+			err = output.Close()
+		}
+
+		if err == nil {
+			err = os.Rename(output.Name(), r.path)
+		}
+
+		if err != nil {
+			output.Close()
+			os.Remove(output.Name())
+
+			return fmt.Errorf("error replacing activity file: %w", err)
+		}
+
+		return nil
+	}
+
+	if r.truncateAt >= 0 {
+		err := os.Truncate(r.path, r.truncateAt)
+		if err != nil {
+			return fmt.Errorf("error truncating activity file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// cleanup removes the repaired file if the repair did not finish.
+func (r *activityRepair) cleanup() {
+	if r.bad != nil {
+		r.bad.Close()
+	}
+
+	if r.output != nil {
+		r.output.Close()
+		os.Remove(r.output.Name())
+	}
+}
@@ -0,0 +1,175 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoverActivityFile(t *testing.T) {
+	const (
+		good      = "2026-01-26T00:00:00.000Z,WRITE,/mnt/disk1/a,1\n"
+		multiline = "2026-01-26T00:00:00.000Z,WRITE,\"/mnt/disk1/line\nbreak\",2\n"
+		jsonGood  = `{"v":1,"path":"/mnt/disk1/a"}` + "\n"
+	)
+
+	tests := []struct {
+		name       string
+		format     Format
+		content    string
+		records    int
+		expected   string
+		quarantine string
+	}{
+		{
+			name:     "clean",
+			format:   FormatCSV,
+			content:  good + multiline + "\n" + good,
+			records:  3,
+			expected: good + multiline + "\n" + good,
+		},
+		{
+			name:       "torn final record",
+			format:     FormatCSV,
+			content:    good + good + "2026-01-26T00:00:00.000Z,WRI",
+			records:    2,
+			expected:   good + good,
+			quarantine: "2026-01-26T00:00:00.000Z,WRI\n",
+		},
+		{
+			name:       "torn quoted record",
+			format:     FormatCSV,
+			content:    good + "2026-01-26T00:00:00.000Z,WRITE,\"/mnt/disk1/a",
+			records:    1,
+			expected:   good,
+			quarantine: "2026-01-26T00:00:00.000Z,WRITE,\"/mnt/disk1/a\n",
+		},
+		{
+			name:       "corrupt line",
+			format:     FormatCSV,
+			content:    good + "2026-01-26T00:00:00.000Z,WRITE,/mnt/disk1/a\"b,1\n" + good,
+			records:    2,
+			expected:   good + good,
+			quarantine: "2026-01-26T00:00:00.000Z,WRITE,/mnt/disk1/a\"b,1\n",
+		},
+		{
+			name:       "unclosed quote followed by records",
+			format:     FormatCSV,
+			content:    good + "2026-01-26T00:00:00.000Z,WRITE,\"/mnt/disk1/a\n" + good + multiline,
+			records:    3,
+			expected:   good + good + multiline,
+			quarantine: "2026-01-26T00:00:00.000Z,WRITE,\"/mnt/disk1/a\n",
+		},
+		{
+			name:       "zero-filled tail",
+			format:     FormatCSV,
+			content:    good + strings.Repeat("\x00", 4096),
+			records:    1,
+			expected:   good,
+			quarantine: strings.Repeat("\x00", 4096) + "\n",
+		},
+		{
+			name:       "json lines",
+			format:     FormatJSONL,
+			content:    jsonGood + "{\"v\":1,\"pa\n" + jsonGood + `{"v":1`,
+			records:    2,
+			expected:   jsonGood + jsonGood,
+			quarantine: "{\"v\":1,\"pa\n{\"v\":1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activityPath := filepath.Join(t.TempDir(), "data.log")
+
+			err := os.WriteFile(activityPath, []byte(tt.content), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := recoverActivityFile(activityPath, tt.format)
+			if err != nil {
+				t.Fatalf("recoverActivityFile() failed: %v", err)
+			}
+
+			if state.records != tt.records {
+				t.Errorf("Expected %d records, got %d", tt.records, state.records)
+			}
+
+			data, _ := os.ReadFile(activityPath)
+			if string(data) != tt.expected {
+				t.Errorf("Activity file = %q, expected %q", data, tt.expected)
+			}
+
+			bad, _ := os.ReadFile(activityPath + ".bad")
+			if string(bad) != tt.quarantine {
+				t.Errorf("Quarantine file = %q, expected %q", bad, tt.quarantine)
+			}
+
+			entries, _ := os.ReadDir(filepath.Dir(activityPath))
+			for _, entry := range entries {
+				if strings.Contains(entry.Name(), ".repair-") {
+					t.Errorf("Temporary file %s was left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverActivityFile_LongLine(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+	good := "2026-01-26T00:00:00.000Z,WRITE,/mnt/disk1/a,1\n"
+
+	err := os.WriteFile(activityPath, []byte(strings.Repeat("x", 3*maxLineBytes)+"\n"+good), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := recoverActivityFile(activityPath, FormatCSV)
+	if err != nil || state.records != 1 {
+		t.Fatalf("Expected 1 record, got %d (%v)", state.records, err)
+	}
+
+	bad, _ := os.ReadFile(activityPath + ".bad")
+	if len(bad) != maxLineBytes+1 {
+		t.Errorf("Expected the quarantined line to be capped, got %d bytes", len(bad))
+	}
+}
+
+func TestNew_CorruptFile(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	err := os.WriteFile(activityPath, []byte("a,\"b\"c\n2026-01-26,/x,WRITE,1\ntorn"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
+	if err != nil {
+		t.Fatalf("Expected New() to recover from a corrupt file, got %v", err)
+	}
+	defer writer.Close()
+
+	if writer.currentLines != 1 {
+		t.Errorf("Expected 1 record after recovery, got %d", writer.currentLines)
+	}
+}
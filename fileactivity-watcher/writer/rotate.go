@@ -0,0 +1,336 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	compressedSuffix = ".gz"
+
+	// rangeFormat is the local time format of the range in rotated file names
+	rangeFormat = "20060102T150405"
+)
+
+// Period is a wall-clock interval the activity file is rotated on.
+type Period int
+
+const (
+	PeriodNone Period = iota
+	PeriodHourly
+	PeriodDaily
+)
+
+// ParsePeriod returns the period with the given name, "hourly" or "daily".
+// An empty name is no period.
+func ParsePeriod(name string) (Period, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return PeriodNone, nil
+	case "hourly":
+		return PeriodHourly, nil
+	case "daily":
+		return PeriodDaily, nil
+	}
+
+	return PeriodNone, fmt.Errorf("unknown rotation period %q", name)
+}
+
+// next returns the first period boundary after t, on the hour or at local
+// midnight, or the zero time if there is no period.
+func (p Period) next(t time.Time) time.Time {
+	year, month, day := t.Date()
+
+	switch p {
+	case PeriodHourly:
+		return time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+	case PeriodDaily:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+	case PeriodNone:
+	}
+
+	return time.Time{}
+}
+
+// Rotation decides when the activity file is rotated and how long the rotated
+// files are kept. Besides the record limit of the writer, the file is rotated on
+// a period boundary or once it reaches MaxFileBytes. Rotated files are named
+// like the activity file plus the local time range of their records, such as
+// "data.log.20260119T000000-20260119T235959", and ".gz" when compressed.
+type Rotation struct {
+	// Period rotates the activity file on the hour or at local midnight
+	Period Period
+	// MaxFileBytes rotates the activity file once it reaches this size; 0 or less is no limit
+	MaxFileBytes int64
+	// Generations is the number of rotated files kept; 0 or less is no limit
+	Generations int
+	// MaxAge removes rotated files whose records are older; 0 or less is no limit
+	MaxAge time.Duration
+	// Compress gzips the rotated files
+	Compress bool
+	// MaxBytes caps the total size of the rotated files; 0 or less is no cap
+	MaxBytes int64
+}
+
+// RotatedFile is a rotated activity file and the time range of its records.
+// Files rotated by older versions are only numbered, so their Start is unknown
+// and their End is the time they were last written.
+type RotatedFile struct {
+	Path  string
+	Start time.Time
+	End   time.Time
+
+	// Numbered files sort before the named ones, the highest number first
+	number int
+}
+
+// Overlaps reports whether the file may hold records between from and to.
+func (f RotatedFile) Overlaps(from, to time.Time) bool {
+	return !f.End.Before(from) && (f.Start.IsZero() || !f.Start.After(to))
+}
+
+// RotatedFiles returns the rotated files of an activity file, oldest first.
+func RotatedFiles(path string) []RotatedFile {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+
+	base := regexp.QuoteMeta(filepath.Base(path))
+	named := regexp.MustCompile(`^` + base + `\.(\d{8}T\d{6})-(\d{8}T\d{6})(?:-\d+)?(?:\.gz)?$`)
+	numbered := regexp.MustCompile(`^` + base + `\.(\d+)(?:\.gz)?$`)
+
+	files := []RotatedFile{}
+
+	for _, entry := range entries {
+		file := RotatedFile{Path: filepath.Join(filepath.Dir(path), entry.Name())}
+
+		if match := named.FindStringSubmatch(entry.Name()); match != nil {
+			file.Start, _ = time.ParseInLocation(rangeFormat, match[1], time.Local)
+			file.End, _ = time.ParseInLocation(rangeFormat, match[2], time.Local)
+		} else if match := numbered.FindStringSubmatch(entry.Name()); match != nil {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			file.number, _ = strconv.Atoi(match[1])
+			file.End = info.ModTime()
+		} else {
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	slices.SortFunc(files, func(a, b RotatedFile) int {
+		if a.number != b.number {
+			return b.number - a.number
+		}
+
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return files
+}
+
+// rotatedPath returns a name for a rotated file that does not exist yet.
+func rotatedPath(path string, start, end time.Time) string {
+	name := path + "." + start.Local().Format(rangeFormat) + "-" + end.Local().Format(rangeFormat)
+
+	candidate := name
+	for i := 2; fileExists(candidate) || fileExists(candidate+compressedSuffix); i++ {
+		candidate = name + "-" + strconv.Itoa(i)
+	}
+
+	return candidate
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
+
+// OpenGeneration opens an activity file or one of its rotated files,
+// decompressing it if needed.
+func OpenGeneration(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening activity file: %w", err)
+	}
+
+	if filepath.Ext(path) != compressedSuffix {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("error reading compressed activity file: %w", err)
+	}
+
+	return &gzipFile{Reader: reader, file: file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.file.Close())
+}
+
+// compressFile gzips a file and removes the original.
+func compressFile(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening rotated activity file: %w", err)
+	}
+	defer input.Close()
+
+	temporary := path + compressedSuffix + ".tmp"
+
+	output, err := os.OpenFile(temporary, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating compressed activity file: %w", err)
+	}
+
+	compressor := gzip.NewWriter(output)
+
+	_, err = io.Copy(compressor, input)
+	if err == nil {
+		err = compressor.Close()
+	}
+
+	if err == nil {
+		err = output.Close()
+	} else {
+		output.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temporary, path+compressedSuffix)
+	}
+
+	if err != nil {
+		os.Remove(temporary)
+
+		return fmt.Errorf("error compressing activity file: %w", err)
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("error removing compressed activity file: %w", err)
+	}
+
+	return nil
+}
+
+// applyRetention removes the rotated files that are too old, too many or over
+// the size cap, oldest first. The size cap always keeps the newest rotated file.
+func applyRetention(path string, rotation Rotation, now time.Time) error {
+	files := RotatedFiles(path)
+	keep := make([]RotatedFile, 0, len(files))
+
+	var errs []error
+
+	remove := func(file RotatedFile, reason string) {
+		log.Info().
+			Str("file", file.Path).
+			Str("reason", reason).
+			Msg("Removing rotated activity file")
+
+		err := os.Remove(file.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error removing rotated activity file: %w", err))
+		}
+	}
+
+	for i, file := range files {
+		switch {
+		case rotation.MaxAge > 0 && file.End.Before(now.Add(-rotation.MaxAge)):
+			remove(file, "age")
+		case rotation.Generations > 0 && len(files)-i > rotation.Generations:
+			remove(file, "generations")
+		default:
+			keep = append(keep, file)
+		}
+	}
+
+	if rotation.MaxBytes <= 0 {
+		return errors.Join(errs...)
+	}
+
+	sizes := make([]int64, len(keep))
+
+	var total int64
+
+	for i, file := range keep {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			continue
+		}
+
+		sizes[i] = info.Size()
+		total += sizes[i]
+	}
+
+	for i := 0; i < len(keep)-1 && total > rotation.MaxBytes; i++ {
+		remove(keep[i], "size")
+
+		total -= sizes[i]
+	}
+
+	return errors.Join(errs...)
+}
+
+// finishRotation compresses a rotated file and applies the retention settings.
+func (w *Writer) finishRotation(rotated string) {
+	if w.rotation.Compress {
+		err := compressFile(rotated)
+		if err != nil {
+			log.Error().Err(err).Msg("Error compressing rotated activity file")
+		}
+	}
+
+	err := applyRetention(w.activityPath, w.rotation, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Error removing rotated activity files")
+	}
+}
@@ -0,0 +1,438 @@
+package writer
+
+/*
+	fileactivity-watcher
+	Copyright (C) 2025-2026 Derek Kaser
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
+)
+
+func writeRecords(t *testing.T, writer *Writer, prefix string, count int, at time.Time) {
+	t.Helper()
+
+	for i := range count {
+		record := testRecord(prefix+strings.Repeat("x", i), types.OpWrite, i)
+		record.FirstSeen = at
+		record.LastSeen = at.Add(time.Duration(i) * time.Second)
+
+		err := writer.Write(record)
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+}
+
+func readGeneration(t *testing.T, path string) string {
+	t.Helper()
+
+	reader, err := OpenGeneration(path)
+	if err != nil {
+		t.Fatalf("OpenGeneration(%s) failed: %v", path, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+
+	return string(data)
+}
+
+func rangeName(path string, start, end time.Time) string {
+	return path + "." + start.Local().Format(rangeFormat) + "-" + end.Local().Format(rangeFormat)
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Period
+		valid    bool
+	}{
+		{"", PeriodNone, true},
+		{"hourly", PeriodHourly, true},
+		{" Daily ", PeriodDaily, true},
+		{"weekly", PeriodNone, false},
+	}
+
+	for _, tt := range tests {
+		period, err := ParsePeriod(tt.name)
+		if (err == nil) != tt.valid || period != tt.expected {
+			t.Errorf("ParsePeriod(%q) = %v, %v", tt.name, period, err)
+		}
+	}
+}
+
+func TestPeriod_Next(t *testing.T) {
+	// Half-hour offsets make sure boundaries follow local time
+	zone := time.FixedZone("IST", 5*3600+1800)
+	now := time.Date(2026, 1, 31, 23, 15, 30, 0, zone)
+
+	tests := []struct {
+		period   Period
+		expected time.Time
+	}{
+		{PeriodHourly, time.Date(2026, 2, 1, 0, 0, 0, 0, zone)},
+		{PeriodDaily, time.Date(2026, 2, 1, 0, 0, 0, 0, zone)},
+		{PeriodNone, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if next := tt.period.next(now); !next.Equal(tt.expected) {
+			t.Errorf("next(%v) for period %d = %v, expected %v", now, tt.period, next, tt.expected)
+		}
+	}
+
+	elevenPM := time.Date(2026, 1, 31, 23, 0, 0, 0, zone)
+	if next := PeriodHourly.next(now.Add(-time.Hour)); !next.Equal(elevenPM) {
+		t.Errorf("Expected the next hour to be 23:00, got %v", next)
+	}
+}
+
+func TestRotation_Generations(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 2, FormatCSV, Rotation{Generations: 3}, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 26, 10, 0, 0, 0, time.Local)
+
+	// Each batch of three records rolls over once
+	for i, batch := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		writeRecords(t, writer, batch, 3, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	writer.Close()
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 rotated files, got %v", files)
+	}
+
+	for i, batch := range []string{"/c", "/d", "/e"} {
+		batchStart := start.Add(time.Duration(i+2) * time.Hour)
+
+		expected := rangeName(activityPath, batchStart, batchStart.Add(2*time.Second))
+		if files[i].Path != expected {
+			t.Errorf("Expected rotated file %d to be %s, got %s", i, expected, files[i].Path)
+		}
+
+		if !files[i].Start.Equal(batchStart) {
+			t.Errorf("Expected rotated file %d to start at %v, got %v",
+				i, batchStart, files[i].Start)
+		}
+
+		if !strings.Contains(readGeneration(t, files[i].Path), ","+batch) {
+			t.Errorf("Expected rotated file %d to hold batch %s", i, batch)
+		}
+	}
+}
+
+func TestRotation_Compress(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	rotation := Rotation{Generations: 2, Compress: true}
+
+	writer, err := New(activityPath, 2, FormatCSV, rotation, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 26, 10, 0, 0, 0, time.Local)
+
+	for i, batch := range []string{"/a", "/b", "/c"} {
+		writeRecords(t, writer, batch, 3, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	writer.Close()
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %v", files)
+	}
+
+	for i, batch := range []string{"/b", "/c"} {
+		if filepath.Ext(files[i].Path) != ".gz" {
+			t.Errorf("Expected rotated file %s to be compressed", files[i].Path)
+		}
+
+		if !strings.Contains(readGeneration(t, files[i].Path), ","+batch) {
+			t.Errorf("Expected rotated file %d to hold batch %s", i, batch)
+		}
+	}
+
+	leftovers, _ := filepath.Glob(activityPath + ".*.tmp")
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no temporary files, got %v", leftovers)
+	}
+}
+
+func TestRotation_Period(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{Period: PeriodDaily}, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	writeRecords(t, writer, "/before", 2, time.Now())
+
+	if !writer.rotateAt.Equal(PeriodDaily.next(time.Now())) {
+		t.Errorf("Expected rotation at local midnight, got %v", writer.rotateAt)
+	}
+
+	if files := RotatedFiles(activityPath); len(files) != 0 {
+		t.Fatalf("Expected no rotation before midnight, got %v", files)
+	}
+
+	// Midnight passes
+	writer.rotateAt = time.Now().Add(-time.Second)
+	writeRecords(t, writer, "/after", 1, time.Now())
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 1 {
+		t.Fatalf("Expected a rotation at midnight, got %v", files)
+	}
+
+	if data := readGeneration(t, files[0].Path); strings.Contains(data, "/after") {
+		t.Errorf("Expected records after midnight in the new file, got %q", data)
+	}
+
+	if writer.currentLines != 1 {
+		t.Errorf("Expected 1 record in the new file, got %d", writer.currentLines)
+	}
+}
+
+func TestRotation_MaxFileBytes(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+
+	writer, err := New(activityPath, 1000, FormatJSONL, Rotation{MaxFileBytes: 500}, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRecords(t, writer, "/mnt/disk1/file", 10, time.Now())
+	writer.Close()
+
+	files := RotatedFiles(activityPath)
+	if len(files) == 0 {
+		t.Fatal("Expected the size limit to rotate the activity file")
+	}
+
+	for _, file := range files {
+		info, err := os.Stat(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A file is rotated with the record that reaches the limit
+		if info.Size() >= 1000 {
+			t.Errorf("Expected %s to be rotated near 500 bytes, got %d", file.Path, info.Size())
+		}
+	}
+}
+
+func TestNew_RotatesEarlierPeriod(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "data.log")
+	yesterday := time.Now().AddDate(0, 0, -1)
+
+	writer, err := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeRecords(t, writer, "/old", 2, yesterday)
+	writer.Close()
+
+	writer, err = New(activityPath, 1000, FormatCSV, Rotation{Period: PeriodDaily}, FlushPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	if !writer.start.Equal(yesterday.Truncate(time.Millisecond)) ||
+		!writer.end.Equal(yesterday.Add(time.Second).Truncate(time.Millisecond)) {
+		t.Errorf("Expected the range of the existing records, got %v - %v",
+			writer.start, writer.end)
+	}
+
+	writeRecords(t, writer, "/new", 1, time.Now())
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 1 || !strings.Contains(readGeneration(t, files[0].Path), "/old") {
+		t.Errorf("Expected yesterday's records to be rotated, got %v", files)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	directory := t.TempDir()
+	activityPath := filepath.Join(directory, "data.log")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	create := func(name string, size int, modified time.Time) {
+		t.Helper()
+
+		path := filepath.Join(directory, name)
+
+		err := os.WriteFile(path, make([]byte, size), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.Chtimes(path, modified, modified)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2026, 2, d, 0, 0, 0, 0, time.Local)
+	}
+
+	named := func(d int) string {
+		return filepath.Base(rangeName(activityPath, day(d), day(d).Add(23*time.Hour)))
+	}
+
+	create("data.log.1", 100, day(1))
+	create(named(2)+".gz", 100, day(3))
+	create(named(20), 100, day(21))
+	create(named(27)+".gz", 100, day(28))
+	create(named(28), 100, day(29))
+	create("data.log.bad", 100, day(1))
+
+	// 10 days keeps everything that ends from February 19th noon on
+	err := applyRetention(activityPath, Rotation{MaxAge: 10 * 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if files := RotatedFiles(activityPath); len(files) != 3 {
+		t.Fatalf("Expected 3 files within 10 days, got %v", files)
+	}
+
+	err = applyRetention(activityPath, Rotation{Generations: 2}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 2 || filepath.Base(files[0].Path) != named(27)+".gz" {
+		t.Fatalf("Expected the 2 newest files, got %v", files)
+	}
+
+	// The newest rotated file is kept even when it is over the cap
+	err = applyRetention(activityPath, Rotation{MaxBytes: 10}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files = RotatedFiles(activityPath)
+	if len(files) != 1 || filepath.Base(files[0].Path) != named(28) {
+		t.Errorf("Expected only the newest file, got %v", files)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "data.log.bad")); err != nil {
+		t.Error("Expected the quarantine file to be left alone")
+	}
+}
+
+func TestRotatedFiles(t *testing.T) {
+	directory := t.TempDir()
+	activityPath := filepath.Join(directory, "data.log")
+
+	for _, name := range []string{
+		"data.log",
+		"data.log.bad",
+		"data.log.20260102T000000-20260102T235959.gz",
+		"data.log.20260101T000000-20260101T235959",
+		"data.log.20260101T000000-20260101T235959-2",
+		"data.log.1",
+		"data.log.2.gz",
+		"data.log.20260103T000000-20260103T235959.gz.tmp",
+		"other.log.1",
+	} {
+		err := os.WriteFile(filepath.Join(directory, name), nil, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"data.log.2.gz",
+		"data.log.1",
+		"data.log.20260101T000000-20260101T235959",
+		"data.log.20260101T000000-20260101T235959-2",
+		"data.log.20260102T000000-20260102T235959.gz",
+	}
+
+	files := RotatedFiles(activityPath)
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %d rotated files, got %v", len(expected), files)
+	}
+
+	for i, name := range expected {
+		if filepath.Base(files[i].Path) != name {
+			t.Errorf("Rotated file %d = %s, expected %s", i, filepath.Base(files[i].Path), name)
+		}
+	}
+
+	january2 := files[4]
+	from := time.Date(2026, 1, 2, 22, 0, 0, 0, time.Local)
+
+	if !january2.Overlaps(from, from.Add(time.Hour)) {
+		t.Error("Expected the January 2nd file to cover 22:00-23:00")
+	}
+
+	if january2.Overlaps(from.Add(4*time.Hour), from.Add(5*time.Hour)) {
+		t.Error("Expected the January 2nd file not to cover January 3rd")
+	}
+}
+
+func TestMigrate_Compressed(t *testing.T) {
+	rotated := filepath.Join(t.TempDir(), "data.log.2.gz")
+
+	file, err := os.Create(rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressor := gzip.NewWriter(file)
+	compressor.Write([]byte("2026-01-02T03:04:05.006Z,WRITE,/mnt/disk1/old.txt,100,,\n"))
+	compressor.Close()
+	file.Close()
+
+	result, err := Migrate(rotated, testDiskOf)
+	if err != nil || result.Converted != 1 {
+		t.Fatalf("Expected 1 converted record, got %+v (%v)", result, err)
+	}
+
+	if data := readGeneration(t, rotated); !strings.HasPrefix(data, `{"v":1,`) {
+		t.Errorf("Expected compressed JSON Lines, got %q", data)
+	}
+}
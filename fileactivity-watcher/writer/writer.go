@@ -19,63 +19,249 @@ package writer
 */
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 )
 
+// Format is the format of the activity log.
+type Format int
+
+const (
+	// FormatCSV writes the positional columns of types.Record.Fields.
+	FormatCSV Format = iota
+	// FormatJSONL writes one types.JSONRecord per line.
+	FormatJSONL
+)
+
+// ParseFormat returns the format with the given name, "csv" or "jsonl".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "jsonl", "json":
+		return FormatJSONL, nil
+	}
+
+	return FormatCSV, fmt.Errorf("unknown output format %q", name)
+}
+
+func (f Format) String() string {
+	if f == FormatJSONL {
+		return "jsonl"
+	}
+
+	return "csv"
+}
+
 type Writer struct {
 	mu             sync.Mutex
 	currentLines   int
+	currentBytes   int64
 	maxRecords     int
+	format         Format
+	rotation       Rotation
 	activityPath   string
 	activityFile   *os.File
 	activityWriter *csv.Writer
+
+	// Records are buffered until the flush policy writes them out
+	flush      FlushPolicy
+	buffer     *bufio.Writer
+	pending    int
+	lastSync   time.Time
+	unsynced   bool
+	stopFlush  chan struct{}
+	flushGroup sync.WaitGroup
+
+	// Time range of the records in the activity file, and when it is rotated next
+	start    time.Time
+	end      time.Time
+	rotateAt time.Time
+
+	// Rotated files are compressed in the background
+	rotating sync.WaitGroup
 }
 
-func New(path string, maxRecords int) (*Writer, error) {
-	activityFile, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+func New(
+	path string,
+	maxRecords int,
+	format Format,
+	rotation Rotation,
+	flush FlushPolicy,
+) (*Writer, error) {
+	err := checkFormat(path, format)
 	if err != nil {
-		return nil, fmt.Errorf("error opening activity file: %w", err)
+		return nil, err
 	}
 
-	reader := csv.NewReader(activityFile)
-	reader.FieldsPerRecord = -1
-
-	lines, err := reader.ReadAll()
+	state, err := recoverActivityFile(path, format)
 	if err != nil {
 		return nil, fmt.Errorf("error reading activity file: %w", err)
 	}
 
-	currentLines := len(lines)
-	log.Info().Int("current_lines", currentLines).Msg("Current activity records")
+	writer := &Writer{
+		currentLines: state.records,
+		activityPath: path,
+		maxRecords:   maxRecords,
+		format:       format,
+		rotation:     rotation,
+		flush:        flush,
+		lastSync:     time.Now(),
+	}
+
+	err = writer.openActivityFile()
+	if err != nil {
+		return nil, err
+	}
 
-	activityWriter := csv.NewWriter(activityFile)
+	writer.startFlushLoop()
 
-	return &Writer{
-		currentLines:   currentLines,
-		activityPath:   path,
-		activityFile:   activityFile,
-		activityWriter: activityWriter,
-		maxRecords:     maxRecords,
-	}, nil
+	// A file left from an earlier period is rotated on the next write
+	writer.start = recordTime(state.first, format, false)
+	writer.end = recordTime(state.last, format, true)
+	writer.rotateAt = rotation.Period.next(writer.start)
+
+	log.Info().
+		Int("current_lines", writer.currentLines).
+		Int64("current_bytes", writer.currentBytes).
+		Stringer("format", format).
+		Msg("Current activity records")
+
+	return writer, nil
+}
+
+// checkFormat refuses to append to an activity log written in another format,
+// which recovery would otherwise quarantine line by line.
+func checkFormat(path string, format Format) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("error opening activity file: %w", err)
+	}
+	defer file.Close()
+
+	first := make([]byte, 1)
+
+	_, err = file.Read(first)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("error reading activity file: %w", err)
+	}
+
+	existing := FormatCSV
+	if first[0] == '{' {
+		existing = FormatJSONL
+	}
+
+	switch {
+	case existing == format:
+		return nil
+	case existing == FormatJSONL:
+		return fmt.Errorf("activity file %s is JSON Lines, set output_format to jsonl", path)
+	default:
+		return fmt.Errorf(
+			"activity file %s is CSV, migrate it with -migrate or move it away first",
+			path,
+		)
+	}
+}
+
+func (w *Writer) openActivityFile() error {
+	activityFile, err := os.OpenFile(w.activityPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening activity file: %w", err)
+	}
+
+	info, err := activityFile.Stat()
+	if err != nil {
+		activityFile.Close()
+
+		return fmt.Errorf("error reading activity file size: %w", err)
+	}
+
+	w.activityFile = activityFile
+	w.currentBytes = info.Size()
+	w.buffer = bufio.NewWriterSize(activityFile, bufferSize)
+	w.activityWriter = csv.NewWriter(&countingWriter{writer: w.buffer, count: &w.currentBytes})
+
+	return nil
+}
+
+// countingWriter counts the bytes written to the activity file, including the
+// ones that are still buffered.
+type countingWriter struct {
+	writer io.Writer
+	count  *int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.writer.Write(data)
+	*c.count += int64(n)
+
+	return n, err
+}
+
+// recordTime returns when the first or, with last set, the final event of a
+// record in the activity file was seen. It returns the zero time for anything
+// that is not a record.
+func recordTime(data []byte, format Format, last bool) time.Time {
+	var record types.Record
+
+	if format == FormatJSONL {
+		var jsonRecord types.JSONRecord
+
+		if json.Unmarshal(data, &jsonRecord) != nil {
+			return time.Time{}
+		}
+
+		record.FirstSeen, _ = time.Parse(types.TimeFormat, jsonRecord.FirstSeen)
+		record.LastSeen, _ = time.Parse(types.TimeFormat, jsonRecord.LastSeen)
+	} else {
+		fields, err := csv.NewReader(bytes.NewReader(data)).Read()
+		if err != nil {
+			return time.Time{}
+		}
+
+		record, _ = types.ParseRecord(fields)
+	}
+
+	if last {
+		return record.LastSeen
+	}
+
+	return record.FirstSeen
 }
 
 func (w *Writer) Close() {
+	w.stopFlushLoop()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.activityWriter != nil {
-		w.activityWriter.Flush()
+	w.rotating.Wait()
 
-		err := w.activityWriter.Error()
-		if err != nil {
-			log.Warn().Err(err).Msg("Error flushing activity writer")
-		}
+	err := w.flushLocked(w.flush.Sync > 0)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error flushing activity writer")
 	}
 
 	if w.activityFile != nil {
@@ -86,19 +272,79 @@ func (w *Writer) Close() {
 	}
 }
 
-func (w *Writer) Write(record []string) error {
+// Write appends a record to the activity log in the configured format.
+func (w *Writer) Write(record types.Record) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	now := time.Now()
+
+	// Records after a period boundary go to the next file
+	if w.currentLines > 0 && !w.rotateAt.IsZero() && !now.Before(w.rotateAt) {
+		err := w.rolloverActivityFile()
+		if err != nil {
+			log.Error().Err(err).Msg("Error rolling over activity file")
+		}
+	}
+
+	var err error
+
+	switch w.format {
+	case FormatJSONL:
+		err = w.writeJSON(record)
+	default:
+		err = w.writeCSV(record)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if w.currentLines == 0 {
+		w.start = record.FirstSeen
+		w.rotateAt = w.rotation.Period.next(now)
+	}
+
+	if w.start.IsZero() || record.FirstSeen.Before(w.start) {
+		w.start = record.FirstSeen
+	}
+
+	if record.LastSeen.After(w.end) {
+		w.end = record.LastSeen
+	}
+
+	w.currentLines++
+	w.pending++
+
+	if w.pending >= w.flush.Records {
+		err := w.flushLocked(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.currentLines > w.maxRecords ||
+		(w.rotation.MaxFileBytes > 0 && w.currentBytes >= w.rotation.MaxFileBytes) {
+		err := w.rolloverActivityFile()
+		if err != nil {
+			log.Error().Err(err).Msg("Error rolling over activity file")
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeCSV(record types.Record) error {
 	if w.activityWriter == nil {
 		return errors.New("activity writer is not initialized")
 	}
 
-	err := w.activityWriter.Write(record)
+	err := w.activityWriter.Write(record.Fields())
 	if err != nil {
 		return fmt.Errorf("error writing activity record: %w", err)
 	}
 
+	// Only moves the record to the buffer; the flush policy decides when it is written out
 	w.activityWriter.Flush()
 
 	err = w.activityWriter.Error()
@@ -106,55 +352,91 @@ func (w *Writer) Write(record []string) error {
 		return fmt.Errorf("error flushing activity writer: %w", err)
 	}
 
-	w.currentLines++
-	if w.currentLines > w.maxRecords {
-		err := w.rolloverActivityFile()
-		if err != nil {
-			log.Error().Err(err).Msg("Error rolling over activity file")
-		}
+	return nil
+}
+
+func (w *Writer) writeJSON(record types.Record) error {
+	if w.buffer == nil {
+		return errors.New("activity writer is not initialized")
+	}
+
+	line, err := MarshalJSON(record)
+	if err != nil {
+		return err
+	}
+
+	n, err := w.buffer.Write(line)
+	w.currentBytes += int64(n)
+
+	if err != nil {
+		return fmt.Errorf("error writing activity record: %w", err)
 	}
 
 	return nil
 }
 
+// MarshalJSON returns a record as a line of the JSON Lines activity log,
+// including the trailing newline.
+func MarshalJSON(record types.Record) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(record.JSON())
+	if err != nil {
+		return nil, fmt.Errorf("error encoding activity record: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
 func (w *Writer) rolloverActivityFile() error {
-	err := w.activityFile.Close()
+	err := w.flushLocked(w.flush.Sync > 0)
 	if err != nil {
-		return fmt.Errorf("error closing activity file: %w", err)
+		return err
 	}
 
-	rolloverPath := w.activityPath + ".1"
+	err = w.activityFile.Close()
+	if err != nil {
+		return fmt.Errorf("error closing activity file: %w", err)
+	}
 
-	_, err = os.Stat(rolloverPath)
-	if err == nil {
-		log.Info().Str("rollover_path", rolloverPath).Msg("Removing existing rollover file")
+	// The previous rotation has to finish before its retention settings are applied
+	w.rotating.Wait()
 
-		err = os.Remove(rolloverPath)
-		if err != nil {
-			return fmt.Errorf("error removing existing rollover file: %w", err)
-		}
+	start, end := w.start, w.end
+	if start.IsZero() || end.IsZero() {
+		start, end = time.Now(), time.Now()
 	}
 
+	rolloverPath := rotatedPath(w.activityPath, start, end)
+
 	err = os.Rename(w.activityPath, rolloverPath)
 	if err != nil {
 		return fmt.Errorf("error renaming activity file: %w", err)
 	}
 
 	// Reopen the activity file for writing
-	w.activityFile, err = os.OpenFile(
-		w.activityPath,
-		os.O_APPEND|os.O_CREATE|os.O_RDWR,
-		0o644,
-	)
+	err = w.openActivityFile()
 	if err != nil {
 		return fmt.Errorf("error reopening activity file: %w", err)
 	}
 
-	w.activityWriter = csv.NewWriter(w.activityFile)
 	// Reset the current lines count
 	w.currentLines = 0
+	w.start = time.Time{}
+	w.end = time.Time{}
+
+	log.Info().Str("rollover_path", rolloverPath).Msg("Activity file rolled over")
+
+	w.rotating.Add(1)
+
+	go func() {
+		defer w.rotating.Done()
 
-	log.Info().Msg("Activity file rolled over")
+		w.finishRotation(rolloverPath)
+	}()
 
 	return nil
 }
@@ -20,16 +20,34 @@ package writer
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dkaser/unraid-fileactivity/fileactivity-watcher/internal/types"
 )
 
+func testRecord(file string, op types.Op, pid int) types.Record {
+	timestamp := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+
+	return types.Record{
+		Op:        op,
+		File:      file,
+		PID:       pid,
+		Count:     1,
+		FirstSeen: timestamp,
+		LastSeen:  timestamp,
+	}
+}
+
 func TestNew(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 
 	if writer == nil {
 		t.Fatal("Expected New() to return a non-nil writer")
@@ -77,7 +95,7 @@ func TestNew_WithExistingFile(t *testing.T) {
 	file.Close()
 
 	// Open with New()
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 
 	// Should count existing lines
 	if writer.currentLines != 3 {
@@ -91,11 +109,11 @@ func TestWrite(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 	defer writer.Close()
 
 	// Write a record
-	record := []string{"2026-01-26", "/test.txt", "WRITE", "1234"}
+	record := testRecord("/test.txt", types.OpWrite, 1234)
 
 	err := writer.Write(record)
 	if err != nil {
@@ -108,7 +126,7 @@ func TestWrite(t *testing.T) {
 	}
 
 	// Write another record
-	record2 := []string{"2026-01-26", "/test2.txt", "READ", "5678"}
+	record2 := testRecord("/test2.txt", types.OpRead, 5678)
 
 	err = writer.Write(record2)
 	if err != nil {
@@ -125,7 +143,7 @@ func TestWrite_NilWriter(t *testing.T) {
 		activityWriter: nil,
 	}
 
-	record := []string{"test", "data"}
+	record := types.Record{File: "test"}
 
 	err := writer.Write(record)
 	if err == nil {
@@ -141,13 +159,13 @@ func TestWrite_VerifyFileContents(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 
 	// Write some records
-	records := [][]string{
-		{"2026-01-26", "/file1.txt", "WRITE", "100"},
-		{"2026-01-26", "/file2.txt", "READ", "200"},
-		{"2026-01-26", "/file3.txt", "CREATE", "300"},
+	records := []types.Record{
+		testRecord("/file1.txt", types.OpWrite, 100),
+		testRecord("/file2.txt", types.OpRead, 200),
+		testRecord("/file3.txt", types.OpCreate, 300),
 	}
 
 	for _, record := range records {
@@ -179,8 +197,8 @@ func TestWrite_VerifyFileContents(t *testing.T) {
 
 	// Verify first record
 	if len(lines) > 0 {
-		if lines[0][0] != "2026-01-26" {
-			t.Errorf("Expected first field to be '2026-01-26', got %s", lines[0][0])
+		if lines[0][0] != "2026-01-26T00:00:00.000Z" {
+			t.Errorf("Expected first field to be '2026-01-26T00:00:00.000Z', got %s", lines[0][0])
 		}
 	}
 }
@@ -190,11 +208,11 @@ func TestRolloverActivityFile(t *testing.T) {
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
 	// Create writer with very low max records to trigger rollover
-	writer, _ := New(activityPath, 3)
+	writer, _ := New(activityPath, 3, FormatCSV, Rotation{}, FlushPolicy{})
 
 	// Write records to exceed max
 	for range 5 {
-		record := []string{"2026-01-26", "/test.txt", "WRITE", "1234"}
+		record := testRecord("/test.txt", types.OpWrite, 1234)
 
 		err := writer.Write(record)
 		if err != nil {
@@ -204,8 +222,10 @@ func TestRolloverActivityFile(t *testing.T) {
 
 	writer.Close()
 
-	// Check if rollover file exists
-	rolloverPath := activityPath + ".1"
+	// Check if rollover file exists, named after the time range of its records
+	timestamp := testRecord("", 0, 0).FirstSeen.Local().Format("20060102T150405")
+
+	rolloverPath := activityPath + "." + timestamp + "-" + timestamp
 	if _, err := os.Stat(rolloverPath); os.IsNotExist(err) {
 		t.Error("Expected rollover file to exist")
 	}
@@ -237,10 +257,10 @@ func TestClose(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 
 	// Write a record
-	record := []string{"2026-01-26", "/test.txt", "WRITE", "1234"}
+	record := testRecord("/test.txt", types.OpWrite, 1234)
 
 	err := writer.Write(record)
 	if err != nil {
@@ -273,7 +293,7 @@ func TestClose_Multiple(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 
 	// Close multiple times should not panic
 	writer.Close()
@@ -295,7 +315,7 @@ func TestWriter_InitialState(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 5000)
+	writer, _ := New(activityPath, 5000, FormatCSV, Rotation{}, FlushPolicy{})
 	defer writer.Close()
 
 	if writer.currentLines != 0 {
@@ -315,7 +335,7 @@ func TestWriter_ConcurrentWrites(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 10000)
+	writer, _ := New(activityPath, 10000, FormatCSV, Rotation{}, FlushPolicy{})
 	defer writer.Close()
 
 	done := make(chan bool)
@@ -324,7 +344,7 @@ func TestWriter_ConcurrentWrites(t *testing.T) {
 	for i := range 5 {
 		go func(id int) {
 			for range 10 {
-				record := []string{"2026-01-26", "/test.txt", "WRITE", "1234"}
+				record := testRecord("/test.txt", types.OpWrite, 1234)
 				_ = writer.Write(record)
 			}
 
@@ -361,11 +381,11 @@ func TestRolloverActivityFile_RemovesExisting(t *testing.T) {
 	existingRollover.Close()
 
 	// Create writer with low max to trigger rollover
-	writer, _ := New(activityPath, 2)
+	writer, _ := New(activityPath, 2, FormatCSV, Rotation{Generations: 1}, FlushPolicy{})
 
 	// Write enough to trigger rollover
 	for range 4 {
-		record := []string{"2026-01-26", "/test.txt", "WRITE", "1234"}
+		record := testRecord("/test.txt", types.OpWrite, 1234)
 
 		err := writer.Write(record)
 		if err != nil {
@@ -375,8 +395,17 @@ func TestRolloverActivityFile_RemovesExisting(t *testing.T) {
 
 	writer.Close()
 
-	// Verify new rollover file exists and doesn't contain old data
-	data, err := os.ReadFile(rolloverPath)
+	// Verify the old rollover file was replaced by the new one
+	if _, err := os.Stat(rolloverPath); err == nil {
+		t.Error("Expected the existing rollover file to be removed")
+	}
+
+	files := RotatedFiles(activityPath)
+	if len(files) != 1 {
+		t.Fatalf("Expected a single rollover file, got %v", files)
+	}
+
+	data, err := os.ReadFile(files[0].Path)
 	if err != nil {
 		t.Fatalf("Failed to read rollover file: %v", err)
 	}
@@ -391,11 +420,11 @@ func TestWriter_EmptyRecords(t *testing.T) {
 	tmpDir := t.TempDir()
 	activityPath := filepath.Join(tmpDir, "activity.log")
 
-	writer, _ := New(activityPath, 1000)
+	writer, _ := New(activityPath, 1000, FormatCSV, Rotation{}, FlushPolicy{})
 	defer writer.Close()
 
 	// Write empty record
-	record := []string{}
+	record := types.Record{}
 
 	err := writer.Write(record)
 	if err != nil {
@@ -409,3 +438,124 @@ func TestWriter_EmptyRecords(t *testing.T) {
 		)
 	}
 }
+
+func TestWrite_JSONL(t *testing.T) {
+	activityPath := filepath.Join(t.TempDir(), "activity.log")
+
+	writer, err := New(activityPath, 1000, FormatJSONL, Rotation{}, FlushPolicy{})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	record := testRecord("/mnt/disk1/a \"quoted\",\nname.txt", types.OpOpen|types.OpRead, 42)
+	record.Disk = "disk1"
+	record.Container = "plex"
+
+	for range 2 {
+		err = writer.Write(record)
+		if err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	writer.Close()
+
+	data, err := os.ReadFile(activityPath)
+	if err != nil {
+		t.Fatalf("Failed to read activity file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), data)
+	}
+
+	var decoded types.JSONRecord
+
+	err = json.Unmarshal([]byte(lines[0]), &decoded)
+	if err != nil {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+
+	if decoded.Version != types.SchemaVersion || decoded.Path != record.File ||
+		decoded.Disk != "disk1" || decoded.PID != 42 || decoded.Container.Name != "plex" {
+		t.Errorf("Unexpected record: %+v", decoded)
+	}
+
+	if strings.Join(decoded.Ops, ",") != "open,read" {
+		t.Errorf("Expected ops open,read, got %v", decoded.Ops)
+	}
+
+	// Reopening counts the existing records
+	writer, err = New(activityPath, 1000, FormatJSONL, Rotation{}, FlushPolicy{})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	defer writer.Close()
+
+	if writer.currentLines != 2 {
+		t.Errorf("Expected currentLines to be 2, got %d", writer.currentLines)
+	}
+}
+
+func TestNew_RefusesOtherFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		format   Format
+		refusing bool
+	}{
+		{"csv into csv", "2026-01-01T00:00:00.000Z,WRITE,/a,1\n", FormatCSV, false},
+		{"csv into jsonl", "2026-01-01T00:00:00.000Z,WRITE,/a,1\n", FormatJSONL, true},
+		{"jsonl into jsonl", `{"v":1,"path":"/a"}` + "\n", FormatJSONL, false},
+		{"jsonl into csv", `{"v":1,"path":"/a"}` + "\n", FormatCSV, true},
+		{"empty file", "", FormatCSV, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activityPath := filepath.Join(t.TempDir(), "activity.log")
+
+			err := os.WriteFile(activityPath, []byte(tt.data), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer, err := New(activityPath, 1000, tt.format, Rotation{}, FlushPolicy{})
+			if (err != nil) != tt.refusing {
+				t.Fatalf("New() error = %v, expected refusal %v", err, tt.refusing)
+			}
+
+			if writer != nil {
+				writer.Close()
+			}
+
+			// A refused file is left as it was
+			data, err := os.ReadFile(activityPath)
+			if err != nil || (tt.refusing && string(data) != tt.data) {
+				t.Errorf("Expected the activity file to be unchanged, got %q (%v)", data, err)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Format
+		valid    bool
+	}{
+		{"", FormatCSV, true},
+		{"csv", FormatCSV, true},
+		{" JSONL ", FormatJSONL, true},
+		{"json", FormatJSONL, true},
+		{"xml", FormatCSV, false},
+	}
+
+	for _, tt := range tests {
+		format, err := ParseFormat(tt.name)
+		if (err == nil) != tt.valid || format != tt.expected {
+			t.Errorf("ParseFormat(%q) = %v, %v", tt.name, format, err)
+		}
+	}
+}
@@ -121,7 +121,9 @@ class Activity
      */
     private function getActivityEntries(string $disk, int $display_events): array
     {
-        $files      = shell_exec("cat /var/log/file.activity/data.log.1 /var/log/file.activity/data.log  2>/dev/null | grep -P " . escapeshellarg($disk) . " | tail -n " . strval($display_events));
+        // The newest rotated file may be compressed; zcat -f reads both forms
+        $logFiles   = implode(" ", array_map('escapeshellarg', Utils::getActivityFiles(2)));
+        $files      = shell_exec("zcat -f {$logFiles} 2>/dev/null | grep -P " . escapeshellarg($disk) . " | tail -n " . strval($display_events));
         $filesArray = array();
 
         if ($files) {
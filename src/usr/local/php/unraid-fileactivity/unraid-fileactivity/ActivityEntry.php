@@ -30,6 +30,11 @@ class ActivityEntry
 
     public function __construct(string $line)
     {
+        if (str_starts_with($line, "{")) {
+            $this->fromJson($line);
+            return;
+        }
+
         $data = str_getcsv($line, ",", "\"", "");
 
         $this->timestamp     = $data[0] ?? "";
@@ -40,6 +45,23 @@ class ActivityEntry
         $this->containerName = $data[5] ?? "";
     }
 
+    private function fromJson(string $line): void
+    {
+        $data = json_decode($line, true);
+        if ( ! is_array($data)) {
+            $data = array();
+        }
+
+        $ops = is_array($data['ops'] ?? null) ? $data['ops'] : array();
+
+        $this->timestamp     = strval($data['time'] ?? "");
+        $this->action        = strtoupper(implode("|", array_map('strval', $ops)));
+        $this->filePath      = strval($data['path'] ?? "");
+        $this->pid           = strval($data['pid'] ?? "");
+        $this->processPath   = strval($data['process'] ?? "");
+        $this->containerName = strval($data['container']['name'] ?? "");
+    }
+
     public function getTimestamp(): string
     {
         return $this->timestamp;
@@ -21,21 +21,52 @@ namespace EDACerton\FileActivity;
 
 class Utils extends \EDACerton\PluginUtils\Utils
 {
-    public static function getActivitySize(): int
+    /**
+     * Returns the activity log and its rotated files, oldest first.
+     * Rotated files are named after the time range of their records, or numbered
+     * by older versions, and may be gzip compressed.
+     *
+     * @param int $limit maximum number of files, 0 for all of them
+     * @return list<string>
+     */
+    public static function getActivityFiles(int $limit = 0): array
     {
-        $size = 0;
+        $logFile = "/var/log/file.activity/data.log";
+        $rotated = array();
 
-        $logFiles = [
-            "/var/log/file.activity/data.log",
-            "/var/log/file.activity/data.log.1"
-        ];
+        foreach (glob("{$logFile}.*") ?: array() as $file) {
+            $suffix = substr($file, strlen($logFile) + 1);
 
-        foreach ($logFiles as $file) {
-            if (file_exists($file)) {
-                $size += filesize($file);
+            if (preg_match('/^(\d+)(\.gz)?$/', $suffix, $matches)) {
+                // Numbered files are older than the named ones, the highest number first
+                $rotated[$file] = sprintf("0-%020d", PHP_INT_MAX - intval($matches[1]));
+            } elseif (preg_match('/^\d{8}T\d{6}-\d{8}T\d{6}(-\d+)?(\.gz)?$/', $suffix)) {
+                $rotated[$file] = "1-{$suffix}";
             }
         }
 
+        asort($rotated, SORT_STRING);
+
+        $files = array_keys($rotated);
+        if (file_exists($logFile)) {
+            $files[] = $logFile;
+        }
+
+        if ($limit > 0) {
+            $files = array_slice($files, -$limit);
+        }
+
+        return array_values($files);
+    }
+
+    public static function getActivitySize(): int
+    {
+        $size = 0;
+
+        foreach (self::getActivityFiles() as $file) {
+            $size += filesize($file) ?: 0;
+        }
+
         return $size;
     }
 